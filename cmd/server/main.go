@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/admin"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/auth"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/blob"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/db"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/feed"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/health"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/messaging"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/outbox"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
-	_ "gitlab.com/kevinmorales/nectar-rest-api/internal/serialize"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/reaper"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/reminder"
 	transportHttp "gitlab.com/kevinmorales/nectar-rest-api/internal/transport/http"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"os"
 )
 
 func Run() error {
@@ -31,13 +41,14 @@ func Run() error {
 	if err != nil {
 		return fmt.Errorf("FAILED to connect to cache: %v", err)
 	}
-	log.Info("attempting to get s3 connection")
-	blobStoreSession, err := blob.NewService()
+	log.Info("attempting to set up blob store")
+	blobBackend, err := newBlobBackend()
 	if err != nil {
 		return fmt.Errorf("FAILED to connect to the blob store %v", err)
 	}
+	blobStoreSession := blob.NewService(blobBackend)
 	log.Info("attempting to set up auth client")
-	authClient, err := auth.NewAuthClient()
+	authClient, err := newAuthClient(database)
 	if err != nil {
 		return fmt.Errorf("FAILED to setup the authentication client %v", err)
 	}
@@ -46,12 +57,44 @@ func Run() error {
 	if err != nil {
 		return fmt.Errorf("FAILED to connect to the messaging queue %v", err)
 	}
-	plantService := plant.NewService(database, blobStoreSession, messageQueue)
-	userService := user.NewService(database, authClient, blobStoreSession, messageQueue)
+	defer messageQueue.Close()
+	imageProcessor := imaging.NewProcessor()
+	plantStore := plant.NewCachedStore(database, cacheClient)
+	userStore := user.NewCachedStore(database, cacheClient, plantStore)
+	plantService := plant.NewService(plantStore, blobStoreSession, imageProcessor, messageQueue)
+	userService := user.NewService(userStore, authClient, blobStoreSession, imageProcessor, messageQueue)
 	authService := auth.NewService(database, authClient, cacheClient)
 	careService := care.NewService(database)
+	authzService := authz.NewService(authz.NewOwnershipPolicy(database))
+	blobReaper := reaper.NewService(database, blobStoreSession)
+	adminService := admin.NewService(database, authService, blobReaper, userStore, plantStore)
+	feedService := feed.NewService(database)
+	uploadService := upload.NewService(database, blobStoreSession, upload.NewValidator(), plantService, userService)
 	healthService := health.NewService(database, cacheClient)
-	httpHandler := transportHttp.NewHandler(plantService, userService, careService, authService, healthService)
+	httpHandler := transportHttp.NewHandler(plantService, userService, careService, authService, authzService, adminService, feedService, blobStoreSession, uploadService, healthService)
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	if os.Getenv("CARE_REMINDERS_ACTIVE") != "" {
+		reminderService := reminder.NewService(database, careService, messageQueue)
+		go reminderService.Start(workerCtx)
+	}
+	if os.Getenv("BLOB_REAPER_ACTIVE") != "" {
+		go blobReaper.Start(workerCtx)
+	}
+	if os.Getenv("OUTBOX_RELAY_ACTIVE") != "" {
+		outboxRelay := outbox.NewRelay(database, messageQueue)
+		go outboxRelay.Start(workerCtx)
+	}
+
+	if os.Getenv("KAFKA_CONSUMER_ACTIVE") != "" {
+		consumer := messaging.NewConsumer(messageQueue)
+		consumer.Handle(events.TopicUsers, authService.HandleUserEvent)
+		if err := consumer.Start(workerCtx); err != nil {
+			return fmt.Errorf("FAILED to start the message consumer %v", err)
+		}
+		httpHandler.OnShutdown(consumer.Close)
+	}
 
 	printBanner()
 	log.Info("service is ready to start :)")
@@ -62,6 +105,28 @@ func Run() error {
 	return nil
 }
 
+// newAuthClient - AUTH_PROVIDER=local uses first-party email/password auth backed
+// by the user_credentials table, anything else uses firebase
+func newAuthClient(database *db.Database) (auth.Client, error) {
+	if os.Getenv("AUTH_PROVIDER") == "local" {
+		log.Info("using local authentication provider")
+		return auth.NewLocalAuthClient(database)
+	}
+	log.Info("using firebase authentication provider")
+	return auth.NewAuthClient()
+}
+
+// newBlobBackend - BLOB_BACKEND=local keeps uploads on local disk and serves them
+// from /media/, anything else uses S3
+func newBlobBackend() (blob.Backend, error) {
+	if os.Getenv("BLOB_BACKEND") == "local" {
+		log.Info("using local disk blob backend")
+		return blob.NewLocalBackend()
+	}
+	log.Info("using S3 blob backend")
+	return blob.NewS3Backend()
+}
+
 func printBanner() {
 	fmt.Println(",--.  ,--.                  ,--.                    ")
 	fmt.Println("|  ,'.|  |  ,---.   ,---. ,-'  '-.  ,--,--. ,--.--. ")
@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/reaper"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"time"
+)
+
+// Account - a user as seen by moderators, including suspended and deleted accounts
+type Account struct {
+	user.User
+	CreatedAt     time.Time  `json:"createdAt"`
+	DeactivatedAt *time.Time `json:"deactivatedAt"`
+}
+
+type Store interface {
+	ListAccounts(ctx context.Context) ([]Account, error)
+	GetAccount(ctx context.Context, userId string) (*Account, error)
+	SetUserRole(ctx context.Context, userId string, role string) error
+	DeactivateUser(ctx context.Context, userId string) error
+	ReactivateUser(ctx context.Context, userId string) error
+	ForceDeletePlant(ctx context.Context, plantId string) error
+	DeletePlantImage(ctx context.Context, plantId string, uri string) error
+}
+
+// SessionRevoker - used to sign a suspended user out of every session immediately
+type SessionRevoker interface {
+	LogoutEverywhere(ctx context.Context, userId string) error
+}
+
+// BlobReaper - removes blobs that soft deleted or never attached images left behind
+type BlobReaper interface {
+	Reap(ctx context.Context, dryRun bool) (*reaper.Report, error)
+}
+
+// UserCache - the Store writes users directly, so the cached user is forgotten
+// after each write
+type UserCache interface {
+	Invalidate(userId string)
+}
+
+// PlantCache - the Store writes plants directly, so the cached plant is
+// forgotten after each write
+type PlantCache interface {
+	Invalidate(plantIds ...string)
+}
+
+type Service struct {
+	Store          Store
+	SessionRevoker SessionRevoker
+	BlobReaper     BlobReaper
+	UserCache      UserCache
+	PlantCache     PlantCache
+}
+
+// NewService - returns a pointer to a new admin service
+func NewService(store Store, sessionRevoker SessionRevoker, blobReaper BlobReaper, userCache UserCache, plantCache PlantCache) *Service {
+	return &Service{
+		Store:          store,
+		SessionRevoker: sessionRevoker,
+		BlobReaper:     blobReaper,
+		UserCache:      userCache,
+		PlantCache:     plantCache,
+	}
+}
+
+// roleRank - higher ranked roles may moderate lower ranked ones
+var roleRank = map[string]int{
+	user.RoleUser:      0,
+	user.RoleModerator: 1,
+	user.RoleAdmin:     2,
+}
+
+func (s *Service) ListAccounts(ctx context.Context) ([]Account, error) {
+	return s.Store.ListAccounts(ctx)
+}
+
+// SuspendUser - deactivates the account and revokes its sessions. A caller may
+// only suspend accounts with a lower role than their own
+func (s *Service) SuspendUser(ctx context.Context, callerRole string, userId string) error {
+	tag := "admin.SuspendUser"
+	if err := s.checkOutranks(ctx, callerRole, userId); err != nil {
+		return err
+	}
+	defer s.UserCache.Invalidate(userId)
+	if err := s.Store.DeactivateUser(ctx, userId); err != nil {
+		return fmt.Errorf("Store.DeactivateUser in %s failed for %v", tag, err)
+	}
+	if err := s.SessionRevoker.LogoutEverywhere(ctx, userId); err != nil {
+		return fmt.Errorf("SessionRevoker.LogoutEverywhere in %s failed for %v", tag, err)
+	}
+	log.Infof("suspended user %s", userId)
+	return nil
+}
+
+func (s *Service) ReactivateUser(ctx context.Context, callerRole string, userId string) error {
+	tag := "admin.ReactivateUser"
+	if err := s.checkOutranks(ctx, callerRole, userId); err != nil {
+		return err
+	}
+	defer s.UserCache.Invalidate(userId)
+	if err := s.Store.ReactivateUser(ctx, userId); err != nil {
+		return fmt.Errorf("Store.ReactivateUser in %s failed for %v", tag, err)
+	}
+	log.Infof("reactivated user %s", userId)
+	return nil
+}
+
+func (s *Service) SetUserRole(ctx context.Context, userId string, role string) error {
+	if !user.IsValidRole(role) {
+		return errs.BadRequestError{Message: fmt.Sprintf("invalid role: %s", role)}
+	}
+	defer s.UserCache.Invalidate(userId)
+	return s.Store.SetUserRole(ctx, userId, role)
+}
+
+func (s *Service) RemovePlant(ctx context.Context, plantId string) error {
+	log.Infof("force removing plant %s", plantId)
+	defer s.PlantCache.Invalidate(plantId)
+	return s.Store.ForceDeletePlant(ctx, plantId)
+}
+
+func (s *Service) RemovePlantImage(ctx context.Context, plantId string, uri string) error {
+	log.Infof("force removing image %s from plant %s", uri, plantId)
+	defer s.PlantCache.Invalidate(plantId)
+	return s.Store.DeletePlantImage(ctx, plantId, uri)
+}
+
+// ReapBlobs - runs the blob reaper now rather than waiting for its next tick
+func (s *Service) ReapBlobs(ctx context.Context, dryRun bool) (*reaper.Report, error) {
+	log.Infof("reaping blobs on request, dry run: %t", dryRun)
+	return s.BlobReaper.Reap(ctx, dryRun)
+}
+
+func (s *Service) checkOutranks(ctx context.Context, callerRole string, userId string) error {
+	account, err := s.Store.GetAccount(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if roleRank[callerRole] <= roleRank[account.Role] {
+		return errs.ForbiddenError{Message: fmt.Sprintf("a %s may not moderate a %s", callerRole, account.Role)}
+	}
+	return nil
+}
@@ -4,83 +4,160 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"gitlab.com/kevinmorales/nectar-rest-api/internal/serialize"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
+	"os"
+	"time"
 )
 
+// RoleClaim - the claim VerifyIDToken sets to the user's role from nectar_users
+const RoleClaim = "role"
+
+var LoginNotSupportedError = errors.New("the configured authentication provider does not support password login")
+
+// verifiedTokenTTL - how long a verified token is trusted without asking the
+// provider again
+const verifiedTokenTTL = 5 * time.Minute
+
 type Cache interface {
 	Get(key string) (value string, found bool, err error)
-	Set(key, value string) error
+	SetWithTTL(key, value string, ttl time.Duration) error
+}
+
+// SessionState - what authenticating a request needs to know beyond the token
+// itself. SessionsRevokedAt is nil when the user never logged out everywhere
+type SessionState struct {
+	Role              string
+	SessionRevoked    bool
+	SessionsRevokedAt *time.Time
 }
 
-type Store interface{}
+// Store - persistence for refresh tokens and session revocations, only digests
+// of the tokens are stored, and for the role that is added to every verified
+// token's claims. Revocations are kept here rather than in the cache so an
+// eviction or another instance can never bring a logged out session back
+type Store interface {
+	GetSessionState(ctx context.Context, userId string, tokenDigest string) (*SessionState, error)
+	AddRefreshToken(ctx context.Context, token RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenDigest string) (*RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenDigest string) (wasRevoked bool, err error)
+	RevokeAllRefreshTokens(ctx context.Context, userId string) error
+	RevokeSession(ctx context.Context, userId string, tokenDigest string, expiresAt time.Time) error
+	RevokeAllSessions(ctx context.Context, userId string, revokedAt time.Time) error
+}
 
 type AuthenticationClient interface {
 	VerifyIDToken(ctx context.Context, token string) (*AuthToken, error)
 }
 
+// Client - the full set of methods an authentication provider must implement,
+// satisfied by both the firebase AuthClient and the LocalAuthClient
+type Client interface {
+	AuthenticationClient
+	CreateUser(ctx context.Context, newUserId string, email string, password string) error
+}
+
+// PasswordAuthenticator - implemented by providers that can exchange an email
+// and password for a session themselves
+type PasswordAuthenticator interface {
+	Login(ctx context.Context, email string, password string) (*Session, error)
+}
+
+// TokenIssuer - implemented by providers that can mint a new access token for a
+// user, which is required to exchange a refresh token
+type TokenIssuer interface {
+	IssueToken(ctx context.Context, userId string) (*Session, error)
+}
+
+type Session struct {
+	UserId                string    `json:"userId"`
+	AccessToken           string    `json:"accessToken"`
+	ExpiresAt             time.Time `json:"expiresAt"`
+	RefreshToken          string    `json:"refreshToken,omitempty"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt,omitempty"`
+}
+
 type Service struct {
-	Store      Store
-	AuthClient AuthenticationClient
-	Cache      Cache
+	Store                Store
+	AuthClient           AuthenticationClient
+	Cache                Cache
+	RefreshTokenTTL      time.Duration
+	SessionRevocationTTL time.Duration
 }
 
-// NewService - returns a pointer to a new user service
+// NewService - returns a pointer to a new auth service. Refresh tokens expire
+// after AUTH_REFRESH_TOKEN_TTL (30 days by default)
 func NewService(store Store, authClient AuthenticationClient, cache Cache) *Service {
+	refreshTokenTTL, err := time.ParseDuration(os.Getenv("AUTH_REFRESH_TOKEN_TTL"))
+	if err != nil || refreshTokenTTL <= 0 {
+		refreshTokenTTL = defaultRefreshTokenTTL
+	}
 	return &Service{
-		Store:      store,
-		AuthClient: authClient,
-		Cache:      cache,
+		Store:                store,
+		AuthClient:           authClient,
+		Cache:                cache,
+		RefreshTokenTTL:      refreshTokenTTL,
+		SessionRevocationTTL: defaultSessionRevocationTTL,
 	}
 }
 
+// VerifyIDToken - the verified token is cached, its session state is read on
+// every call so a changed role, a suspension or a logout applies to the next
+// request. Returns RevokedTokenError if the token was logged out, or was issued
+// before the user last logged out everywhere
 func (s *Service) VerifyIDToken(ctx context.Context, sessionToken string) (*AuthToken, error) {
-	serializedToken, found, err := s.Cache.Get(sessionToken)
+	authToken, err := s.verifyIDToken(ctx, sessionToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from cache, this is not a key-not-found error: %v", err)
+		return nil, err
 	}
-	if !found {
-		authToken, err := s.AuthClient.VerifyIDToken(ctx, sessionToken)
-		if err != nil {
-			return nil, fmt.Errorf("an error occurred verifying the auth token: %v", err)
-		}
-		serializedAuthToken, err := serializeToken(*authToken)
-		if err != nil {
-			return nil, fmt.Errorf("an error occurred serializing the auth token: %v", err)
-		}
-		if err := s.Cache.Set(sessionToken, serializedAuthToken); err != nil {
-			return nil, fmt.Errorf("an error occurred setting the auth token in the cache: %v", err)
-		}
-	}
-	authToken, err := getTokenFromSerializedForm(serializedToken)
+	state, err := s.Store.GetSessionState(ctx, authToken.UID, digestToken(sessionToken))
 	if err != nil {
-		return nil, fmt.Errorf("invalid format of serialized token: %v", err)
+		return nil, fmt.Errorf("an error occurred looking up the session of the token's user: %v", err)
 	}
-	return &authToken, nil
-}
-
-func serializeToken(authToken AuthToken) (string, error) {
-	sx := serialize.SX{}
-	sx["UID"] = authToken.UID
-	sx["Claims"] = authToken.Claims
-	return serialize.ToGOB64(sx)
+	if state.SessionRevoked {
+		return nil, RevokedTokenError
+	}
+	if state.SessionsRevokedAt != nil && authToken.IssuedAt < state.SessionsRevokedAt.Unix() {
+		return nil, RevokedTokenError
+	}
+	if authToken.Claims == nil {
+		authToken.Claims = map[string]interface{}{}
+	}
+	authToken.Claims[RoleClaim] = state.Role
+	return authToken, nil
 }
 
-func getTokenFromSerializedForm(serializedToken string) (AuthToken, error) {
-	valMap, err := serialize.FromGOB64(serializedToken)
+// verifyIDToken - a cache that can not be reached, or holds a token it can not
+// decode, is treated as a miss and the token is verified with the provider
+func (s *Service) verifyIDToken(ctx context.Context, sessionToken string) (*AuthToken, error) {
+	cachedToken, found, err := cache.GetGob[AuthToken](s.Cache, sessionToken)
 	if err != nil {
-		return AuthToken{}, fmt.Errorf("failed to deserialize the authentication token: %v", err)
+		log.Warnf("reading a verified token from the cache failed: %v", err)
 	}
-	uid := valMap["UID"].(string)
-	if uid == "" {
-		return AuthToken{}, errors.New("no uid found in serialized token")
+	if found && cachedToken.UID != "" {
+		return &cachedToken, nil
 	}
-	claims := valMap["Claims"].(map[string]interface{})
-	if claims == nil {
-		return AuthToken{}, errors.New("no claims found in serialized token")
+	authToken, err := s.AuthClient.VerifyIDToken(ctx, sessionToken)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred verifying the auth token: %v", err)
 	}
-	authToken := AuthToken{
-		UID:    uid,
-		Claims: claims,
+	if err := cache.SetGob(s.Cache, sessionToken, *authToken, verifiedTokenTTL); err != nil {
+		log.Warnf("caching a verified token failed: %v", err)
 	}
 	return authToken, nil
 }
+
+func (s *Service) Login(ctx context.Context, email string, password string) (*Session, error) {
+	authenticator, ok := s.AuthClient.(PasswordAuthenticator)
+	if !ok {
+		return nil, LoginNotSupportedError
+	}
+	session, err := authenticator.Login(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachRefreshToken(ctx, session); err != nil {
+		return nil, fmt.Errorf("attachRefreshToken in auth.Login failed for %v", err)
+	}
+	return session, nil
+}
@@ -53,8 +53,10 @@ type AuthClient struct {
 }
 
 type AuthToken struct {
-	UID    string                 `json:"uid,omitempty"`
-	Claims map[string]interface{} `json:"-"`
+	UID      string                 `json:"uid,omitempty"`
+	Claims   map[string]interface{} `json:"-"`
+	IssuedAt int64                  `json:"-"`
+	Expires  int64                  `json:"-"`
 }
 
 func NewAuthClient() (*AuthClient, error) {
@@ -105,8 +107,10 @@ func (ac *AuthClient) VerifyIDToken(ctx context.Context, token string) (*AuthTok
 		return nil, err
 	}
 	authToken := &AuthToken{
-		UID:    t.UID,
-		Claims: t.Claims,
+		UID:      t.UID,
+		Claims:   t.Claims,
+		IssuedAt: t.IssuedAt,
+		Expires:  t.Expires,
 	}
 	return authToken, nil
 }
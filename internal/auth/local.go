@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"time"
+)
+
+const (
+	localTokenIssuer     = "nectar"
+	defaultLocalTokenTTL = time.Hour
+)
+
+var InvalidCredentialsError = errors.New("invalid email or password")
+
+// Credentials - a row of the user_credentials table joined with the user's email
+type Credentials struct {
+	UserId         string
+	Email          string
+	PasswordDigest string
+}
+
+// CredentialStore - persistence needed by the local auth client
+type CredentialStore interface {
+	AddUserCredentials(ctx context.Context, userId string, passwordDigest string) error
+	GetUserCredentialsByEmail(ctx context.Context, email string) (*Credentials, error)
+}
+
+// LocalAuthClient - a first-party alternative to the firebase AuthClient. Passwords
+// are stored as bcrypt digests and sessions are HMAC signed JWTs
+type LocalAuthClient struct {
+	Store      CredentialStore
+	SigningKey []byte
+	TokenTTL   time.Duration
+}
+
+// NewLocalAuthClient - returns a pointer to a local auth client. Tokens are signed
+// with TOKEN_SECRET and expire after AUTH_TOKEN_TTL (one hour by default)
+func NewLocalAuthClient(store CredentialStore) (*LocalAuthClient, error) {
+	secret := os.Getenv("TOKEN_SECRET")
+	if secret == "" {
+		return nil, errors.New("TOKEN_SECRET must be set to use local authentication")
+	}
+	ttl, err := time.ParseDuration(os.Getenv("AUTH_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultLocalTokenTTL
+	}
+	return &LocalAuthClient{
+		Store:      store,
+		SigningKey: []byte(secret),
+		TokenTTL:   ttl,
+	}, nil
+}
+
+func (lc *LocalAuthClient) CreateUser(ctx context.Context, newUserId string, email string, password string) error {
+	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("bcrypt.GenerateFromPassword in auth.LocalAuthClient.CreateUser failed for %v", err)
+	}
+	if err := lc.Store.AddUserCredentials(ctx, newUserId, string(digest)); err != nil {
+		return fmt.Errorf("Store.AddUserCredentials in auth.LocalAuthClient.CreateUser failed for %v", err)
+	}
+	return nil
+}
+
+// Login - checks the password against the stored digest and issues a signed token
+func (lc *LocalAuthClient) Login(ctx context.Context, email string, password string) (*Session, error) {
+	creds, err := lc.Store.GetUserCredentialsByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetUserCredentialsByEmail in auth.LocalAuthClient.Login failed for %v", err)
+	}
+	if creds == nil {
+		return nil, InvalidCredentialsError
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(creds.PasswordDigest), []byte(password)); err != nil {
+		return nil, InvalidCredentialsError
+	}
+	return lc.IssueToken(ctx, creds.UserId)
+}
+
+func (lc *LocalAuthClient) IssueToken(ctx context.Context, userId string) (*Session, error) {
+	now := time.Now()
+	expiresAt := now.Add(lc.TokenTTL)
+	claims := jwt.MapClaims{
+		"iss":     localTokenIssuer,
+		"sub":     userId,
+		"user_id": userId,
+		"iat":     now.Unix(),
+		"exp":     expiresAt.Unix(),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(lc.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %v", err)
+	}
+	return &Session{
+		UserId:      userId,
+		AccessToken: signed,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+func (lc *LocalAuthClient) VerifyIDToken(ctx context.Context, token string) (*AuthToken, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return lc.SigningKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if !claims.VerifyIssuer(localTokenIssuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	uid, _ := claims["sub"].(string)
+	if uid == "" {
+		return nil, errors.New("no subject found in token")
+	}
+	issuedAt, _ := claims["iat"].(float64)
+	expires, _ := claims["exp"].(float64)
+	return &AuthToken{
+		UID:      uid,
+		Claims:   claims,
+		IssuedAt: int64(issuedAt),
+		Expires:  int64(expires),
+	}, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	"time"
+)
+
+const (
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// defaultSessionRevocationTTL - how long a logged out token is remembered when
+	// it carries no expiry of its own
+	defaultSessionRevocationTTL = 24 * time.Hour
+)
+
+var (
+	InvalidRefreshTokenError = errors.New("refresh token is invalid, expired or revoked")
+	RevokedTokenError        = errors.New("session token has been revoked")
+	RefreshNotSupportedError = errors.New("the configured authentication provider does not support refresh tokens")
+)
+
+type RefreshToken struct {
+	UserId      string
+	TokenDigest string
+	ExpiresAt   time.Time
+	RevokedAt   *time.Time
+}
+
+// Refresh - exchanges a refresh token for a new access token and a new refresh
+// token. The presented token is revoked, and presenting an already revoked
+// token is treated as theft, revoking every session the user has
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	tag := "auth.Refresh"
+	issuer, ok := s.AuthClient.(TokenIssuer)
+	if !ok {
+		return nil, RefreshNotSupportedError
+	}
+	digest := digestToken(refreshToken)
+	rt, err := s.Store.GetRefreshToken(ctx, digest)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetRefreshToken in %s failed for %v", tag, err)
+	}
+	if rt == nil || time.Now().After(rt.ExpiresAt) {
+		return nil, InvalidRefreshTokenError
+	}
+	if rt.RevokedAt != nil {
+		log.Warnf("revoked refresh token presented for user %s, revoking all sessions", rt.UserId)
+		if err := s.LogoutEverywhere(ctx, rt.UserId); err != nil {
+			return nil, fmt.Errorf("LogoutEverywhere in %s failed for %v", tag, err)
+		}
+		return nil, InvalidRefreshTokenError
+	}
+	wasRevoked, err := s.Store.RevokeRefreshToken(ctx, digest)
+	if err != nil {
+		return nil, fmt.Errorf("Store.RevokeRefreshToken in %s failed for %v", tag, err)
+	}
+	if !wasRevoked {
+		// another request rotated this token first
+		return nil, InvalidRefreshTokenError
+	}
+	session, err := issuer.IssueToken(ctx, rt.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("IssueToken in %s failed for %v", tag, err)
+	}
+	if err := s.attachRefreshToken(ctx, session); err != nil {
+		return nil, fmt.Errorf("attachRefreshToken in %s failed for %v", tag, err)
+	}
+	return session, nil
+}
+
+// Logout - revokes the given session token until it expires, along with the
+// refresh token if one is provided and it belongs to the same user
+func (s *Service) Logout(ctx context.Context, sessionToken string, refreshToken string) error {
+	tag := "auth.Logout"
+	authToken, err := s.VerifyIDToken(ctx, sessionToken)
+	if err != nil {
+		return fmt.Errorf("VerifyIDToken in %s failed for %v", tag, err)
+	}
+	expiresAt := time.Now().Add(s.SessionRevocationTTL)
+	if authToken.Expires > 0 {
+		expiresAt = time.Unix(authToken.Expires, 0)
+	}
+	if time.Now().Before(expiresAt) {
+		if err := s.Store.RevokeSession(ctx, authToken.UID, digestToken(sessionToken), expiresAt); err != nil {
+			return fmt.Errorf("Store.RevokeSession in %s failed for %v", tag, err)
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	digest := digestToken(refreshToken)
+	rt, err := s.Store.GetRefreshToken(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("Store.GetRefreshToken in %s failed for %v", tag, err)
+	}
+	if rt == nil || rt.UserId != authToken.UID {
+		return nil
+	}
+	if _, err := s.Store.RevokeRefreshToken(ctx, digest); err != nil {
+		return fmt.Errorf("Store.RevokeRefreshToken in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// LogoutEverywhere - revokes every refresh token the user holds and rejects any
+// access token issued before now
+func (s *Service) LogoutEverywhere(ctx context.Context, userId string) error {
+	tag := "auth.LogoutEverywhere"
+	if err := s.Store.RevokeAllRefreshTokens(ctx, userId); err != nil {
+		return fmt.Errorf("Store.RevokeAllRefreshTokens in %s failed for %v", tag, err)
+	}
+	if err := s.Store.RevokeAllSessions(ctx, userId, time.Now()); err != nil {
+		return fmt.Errorf("Store.RevokeAllSessions in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// HandleUserEvent - signs a deleted user out of every session, whichever
+// service deleted them. Other events on the users topic are ignored
+func (s *Service) HandleUserEvent(ctx context.Context, message []byte) error {
+	_, payload, err := events.Decode(message)
+	var unknownEventError events.UnknownEventError
+	if errors.As(err, &unknownEventError) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("events.Decode in auth.HandleUserEvent failed for %v", err)
+	}
+	deleted, ok := payload.(*events.UserDeleted)
+	if !ok {
+		return nil
+	}
+	log.Infof("signing deleted user %s out of every session", deleted.UserId)
+	return s.LogoutEverywhere(ctx, deleted.UserId)
+}
+
+func (s *Service) attachRefreshToken(ctx context.Context, session *Session) error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(buf)
+	expiresAt := time.Now().Add(s.RefreshTokenTTL)
+	rt := RefreshToken{
+		UserId:      session.UserId,
+		TokenDigest: digestToken(refreshToken),
+		ExpiresAt:   expiresAt,
+	}
+	if err := s.Store.AddRefreshToken(ctx, rt); err != nil {
+		return fmt.Errorf("Store.AddRefreshToken failed for %v", err)
+	}
+	session.RefreshToken = refreshToken
+	session.RefreshTokenExpiresAt = expiresAt
+	return nil
+}
+
+func digestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+)
+
+type Kind string
+
+const (
+	KindPlant        Kind = "plant"
+	KindCareLogEntry Kind = "care-log-entry"
+	KindUser         Kind = "user"
+)
+
+type Action string
+
+const (
+	// ActionRead - viewing data any signed-in user may see, e.g. a plant or a profile
+	ActionRead Action = "read"
+	// ActionReadPrivate - viewing data only the owner may see, e.g. care logs
+	ActionReadPrivate Action = "read-private"
+	// ActionWrite - creating, updating or deleting data, owner only
+	ActionWrite Action = "write"
+)
+
+type Resource struct {
+	Kind Kind
+	Id   string
+}
+
+// Policy - decides whether a user may perform an action on a resource. Returns a
+// nectar_errors.ForbiddenError when they may not, or a *nectar_errors.NoEntityError
+// when the resource does not exist
+type Policy interface {
+	Authorize(ctx context.Context, userId string, resource Resource, action Action) error
+}
+
+// Store - looks up who owns a resource. Each method returns a
+// *nectar_errors.NoEntityError if the resource does not exist or was deleted
+type Store interface {
+	GetPlantOwnerId(ctx context.Context, plantId string) (string, error)
+	GetCareLogEntryOwnerId(ctx context.Context, logEntryId string) (string, error)
+	GetActiveUserId(ctx context.Context, userId string) (string, error)
+}
+
+// OwnershipPolicy - plants and profiles are visible to every signed-in user,
+// everything else, and every write, is restricted to the owner
+type OwnershipPolicy struct {
+	Store Store
+}
+
+func NewOwnershipPolicy(store Store) *OwnershipPolicy {
+	return &OwnershipPolicy{Store: store}
+}
+
+func (p *OwnershipPolicy) Authorize(ctx context.Context, userId string, resource Resource, action Action) error {
+	if userId == "" {
+		return errs.ForbiddenError{Message: "no user associated with this request"}
+	}
+	ownerId, err := p.ownerOf(ctx, resource)
+	if err != nil {
+		return err
+	}
+	if action == ActionRead {
+		return nil
+	}
+	if ownerId != userId {
+		return errs.ForbiddenError{Message: fmt.Sprintf("user %s may not %s %s %s", userId, action, resource.Kind, resource.Id)}
+	}
+	return nil
+}
+
+func (p *OwnershipPolicy) ownerOf(ctx context.Context, resource Resource) (string, error) {
+	switch resource.Kind {
+	case KindPlant:
+		return p.Store.GetPlantOwnerId(ctx, resource.Id)
+	case KindCareLogEntry:
+		return p.Store.GetCareLogEntryOwnerId(ctx, resource.Id)
+	case KindUser:
+		return p.Store.GetActiveUserId(ctx, resource.Id)
+	default:
+		return "", fmt.Errorf("unknown resource kind: %s", resource.Kind)
+	}
+}
+
+type Service struct {
+	Policy Policy
+}
+
+// NewService - returns a pointer to a new authorization service
+func NewService(policy Policy) *Service {
+	return &Service{
+		Policy: policy,
+	}
+}
+
+func (s *Service) Authorize(ctx context.Context, userId string, resource Resource, action Action) error {
+	if err := s.Policy.Authorize(ctx, userId, resource, action); err != nil {
+		log.Infof("authorization denied for user %s on %s %s (%s): %v", userId, resource.Kind, resource.Id, action, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package authz
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"testing"
+)
+
+type fakeStore struct {
+	plantOwners   map[string]string
+	careLogOwners map[string]string
+	activeUsers   map[string]bool
+}
+
+func (f fakeStore) lookup(owners map[string]string, id string) (string, error) {
+	ownerId, ok := owners[id]
+	if !ok {
+		return "", &errs.NoEntityError{Message: id}
+	}
+	return ownerId, nil
+}
+
+func (f fakeStore) GetPlantOwnerId(ctx context.Context, plantId string) (string, error) {
+	return f.lookup(f.plantOwners, plantId)
+}
+
+func (f fakeStore) GetCareLogEntryOwnerId(ctx context.Context, logEntryId string) (string, error) {
+	return f.lookup(f.careLogOwners, logEntryId)
+}
+
+func (f fakeStore) GetActiveUserId(ctx context.Context, userId string) (string, error) {
+	if !f.activeUsers[userId] {
+		return "", &errs.NoEntityError{Message: userId}
+	}
+	return userId, nil
+}
+
+func TestOwnershipPolicy(t *testing.T) {
+	owner, stranger := "owner-id", "stranger-id"
+	store := fakeStore{
+		plantOwners:   map[string]string{"plant-id": owner},
+		careLogOwners: map[string]string{"entry-id": owner},
+		activeUsers:   map[string]bool{owner: true, stranger: true},
+	}
+	policy := NewOwnershipPolicy(store)
+
+	tests := []struct {
+		name      string
+		userId    string
+		resource  Resource
+		action    Action
+		forbidden bool
+		notFound  bool
+	}{
+		{name: "owner reads plant", userId: owner, resource: Resource{KindPlant, "plant-id"}, action: ActionRead},
+		{name: "stranger reads plant", userId: stranger, resource: Resource{KindPlant, "plant-id"}, action: ActionRead},
+		{name: "owner writes plant", userId: owner, resource: Resource{KindPlant, "plant-id"}, action: ActionWrite},
+		{name: "stranger writes plant", userId: stranger, resource: Resource{KindPlant, "plant-id"}, action: ActionWrite, forbidden: true},
+		{name: "owner reads plant care logs", userId: owner, resource: Resource{KindPlant, "plant-id"}, action: ActionReadPrivate},
+		{name: "stranger reads plant care logs", userId: stranger, resource: Resource{KindPlant, "plant-id"}, action: ActionReadPrivate, forbidden: true},
+		{name: "read missing plant", userId: owner, resource: Resource{KindPlant, "missing"}, action: ActionRead, notFound: true},
+		{name: "owner updates care log entry", userId: owner, resource: Resource{KindCareLogEntry, "entry-id"}, action: ActionWrite},
+		{name: "stranger deletes care log entry", userId: stranger, resource: Resource{KindCareLogEntry, "entry-id"}, action: ActionWrite, forbidden: true},
+		{name: "stranger reads profile", userId: stranger, resource: Resource{KindUser, owner}, action: ActionRead},
+		{name: "user updates self", userId: owner, resource: Resource{KindUser, owner}, action: ActionWrite},
+		{name: "stranger deletes user", userId: stranger, resource: Resource{KindUser, owner}, action: ActionWrite, forbidden: true},
+		{name: "stranger reads user care logs", userId: stranger, resource: Resource{KindUser, owner}, action: ActionReadPrivate, forbidden: true},
+		{name: "read deleted user", userId: owner, resource: Resource{KindUser, "deleted-id"}, action: ActionRead, notFound: true},
+		{name: "anonymous reads plant", userId: "", resource: Resource{KindPlant, "plant-id"}, action: ActionRead, forbidden: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Authorize(context.Background(), tc.userId, tc.resource, tc.action)
+			switch {
+			case tc.forbidden:
+				assert.ErrorAs(t, err, &errs.ForbiddenError{})
+			case tc.notFound:
+				var noEntityError *errs.NoEntityError
+				assert.ErrorAs(t, err, &noEntityError)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
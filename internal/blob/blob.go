@@ -3,15 +3,15 @@ package blob
 import (
 	"context"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"io"
+	"mime"
+	"net/url"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 type S3Response struct {
@@ -19,33 +19,54 @@ type S3Response struct {
 	FileName string `json:"fileName"`
 }
 
+// Backend - where blobs are kept. Put returns the public url of the stored blob
+type Backend interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) (url string, err error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Opener - implemented by backends whose blobs are served by this api rather
+// than by the backend itself
+type Opener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error)
+}
+
+// DirectUploader - implemented by backends clients can upload to directly, with
+// a presigned url, instead of sending the bytes through this api
+type DirectUploader interface {
+	PresignPut(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+}
+
+// PresignedPutAcceptor - implemented by backends whose presigned urls point back
+// at this api
+type PresignedPutAcceptor interface {
+	AcceptPresignedPut(ctx context.Context, key string, query url.Values, contentType string, body io.Reader) error
+}
+
+// KeyResolver - implemented by backends that can tell which of their keys a
+// public url points at, and so which urls they own
+type KeyResolver interface {
+	KeyForUrl(rawUrl string) (string, bool)
+}
+
+// DirectUploadNotSupportedError - the configured backend cannot hand out
+// presigned urls
+type DirectUploadNotSupportedError struct{}
+
+func (e DirectUploadNotSupportedError) Error() string {
+	return "the blob backend does not support direct uploads"
+}
+
 type Service struct {
-	Bucket      string
-	Acl         string
-	BlobSession *session.Session
-}
-
-func NewService() (*Service, error) {
-	log.Info("initializing S3 Connection")
-	accessKey := os.Getenv("ACCESS_KEY")
-	secretKey := os.Getenv("SECRET_KEY")
-	region := os.Getenv("AWS_REGION")
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(region),
-		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, ""),
-	})
-	if err != nil {
-		log.Errorf("FAILED to create S3 session: %s", err.Error())
-		return nil, err
-	}
-	log.Info("successfully created S3 session")
+	Backend Backend
+}
 
-	s := &Service{
-		BlobSession: sess,
-		Bucket:      os.Getenv("S3_BUCKET"),
-		Acl:         os.Getenv("AWS_ACL"),
+// NewService - returns a pointer to a new blob service storing into backend
+func NewService(backend Backend) *Service {
+	return &Service{
+		Backend: backend,
 	}
-	return s, nil
 }
 
 func (s *Service) UploadToBlobStore(fileList []string, ctx context.Context) ([]string, error) {
@@ -53,14 +74,14 @@ func (s *Service) UploadToBlobStore(fileList []string, ctx context.Context) ([]s
 	//Set up the concurrency
 	var wg sync.WaitGroup
 
-	// The resulting s3 urls to the files
-	s3Urls := make([]string, len(fileList))
-	s3Errors := make([]error, len(fileList))
+	// The resulting urls to the files
+	blobUrls := make([]string, len(fileList))
+	blobErrors := make([]error, len(fileList))
 
 	handleClose := func(file *os.File, index int) {
 		if err := file.Close(); err != nil {
 			log.Error(err)
-			s3Errors[index] = err
+			blobErrors[index] = err
 		}
 	}
 	uploadImage := func(pathOfFile string, index int, wg *sync.WaitGroup) {
@@ -68,59 +89,93 @@ func (s *Service) UploadToBlobStore(fileList []string, ctx context.Context) ([]s
 
 		file, err := os.Open(pathOfFile)
 		if err != nil {
-			s3Errors[index] = err
+			blobErrors[index] = err
 			return
 		}
 		defer handleClose(file, index)
 
-		uploader := s3manager.NewUploader(s.BlobSession)
-		input := &s3manager.UploadInput{
-			ACL:    aws.String(s.Acl),
-			Bucket: aws.String(s.Bucket),
-			Key:    aws.String(file.Name()),
-			Body:   file,
-		}
-		result, err := uploader.UploadWithContext(ctx, input)
+		key := filepath.Base(file.Name())
+		url, err := s.Backend.Put(ctx, key, file, mime.TypeByExtension(filepath.Ext(key)))
 		if err != nil {
-			s3Errors[index] = err
+			blobErrors[index] = err
 			return
 		}
-		s3Urls[index] = result.Location
-		log.Info(fmt.Sprintf("Upload result: %+v\n", result))
+		blobUrls[index] = url
+		log.Info(fmt.Sprintf("Upload result: %s", url))
 	}
 
 	// Iterate over the local files that need to be updated
 	for i, pathOfFile := range fileList {
-		// Kick off goroutine with thread-safe function to upload to s3
+		// Kick off goroutine with thread-safe function to upload to the backend
 		wg.Add(1)
 		go uploadImage(pathOfFile, i, &wg)
 	}
-	// Block until WaitGroup counter is zero, then return the s3 urls
+	// Block until WaitGroup counter is zero, then return the urls
 	wg.Wait()
 
 	//Check if any errors occurred
-	for _, err := range s3Errors {
+	for _, err := range blobErrors {
 		if err != nil {
 			log.Error(err)
 			return nil, err
 		}
 	}
-	return s3Urls, nil
+	return blobUrls, nil
 }
 
 func (s *Service) DeleteFromBlobStore(fileName string) error {
-	service := s3.New(s.BlobSession)
-	input := &s3.DeleteObjectInput{
-		Bucket: aws.String(s.Bucket),
-		Key:    aws.String(fileName),
+	return s.Backend.Delete(context.Background(), fileName)
+}
+
+// Open - reads a blob for serving over http. Returns a NoEntityError when the
+// blob does not exist or the backend serves its own blobs
+func (s *Service) Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	opener, ok := s.Backend.(Opener)
+	if !ok {
+		return nil, time.Time{}, &nectar_errors.NoEntityError{Message: "blobs are not served by this api"}
 	}
-	result, err := service.DeleteObject(input)
-	if err != nil {
-		if awsError, ok := err.(awserr.Error); ok {
-			log.Errorf("error occurred on AWS side of transaction: %v", awsError)
-		}
-		return err
+	return opener.Open(ctx, key)
+}
+
+// PresignPut - a url the client can PUT the blob to within ttl
+func (s *Service) PresignPut(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error) {
+	uploader, ok := s.Backend.(DirectUploader)
+	if !ok {
+		return "", DirectUploadNotSupportedError{}
+	}
+	return uploader.PresignPut(ctx, key, contentType, ttl)
+}
+
+// Get - reads a blob along with its size. Returns a NoEntityError when it does
+// not exist
+func (s *Service) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	uploader, ok := s.Backend.(DirectUploader)
+	if !ok {
+		return nil, 0, DirectUploadNotSupportedError{}
+	}
+	return uploader.Get(ctx, key)
+}
+
+func (s *Service) Delete(ctx context.Context, key string) error {
+	return s.Backend.Delete(ctx, key)
+}
+
+// AcceptPresignedPut - stores a blob sent to a presigned url handed out by this
+// api. Returns a NoEntityError when the backend does not serve its own urls
+func (s *Service) AcceptPresignedPut(ctx context.Context, key string, query url.Values, contentType string, body io.Reader) error {
+	acceptor, ok := s.Backend.(PresignedPutAcceptor)
+	if !ok {
+		return &nectar_errors.NoEntityError{Message: "blobs are not uploaded through this api"}
+	}
+	return acceptor.AcceptPresignedPut(ctx, key, query, contentType, body)
+}
+
+// KeyForUrl - the key of the blob behind a url returned by Put. False for urls
+// the backend does not own, which must never be deleted
+func (s *Service) KeyForUrl(rawUrl string) (string, bool) {
+	resolver, ok := s.Backend.(KeyResolver)
+	if !ok {
+		return "", false
 	}
-	log.Info("Successfully deleted item from S3", result.String())
-	return nil
+	return resolver.KeyForUrl(rawUrl)
 }
@@ -0,0 +1,188 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaRoutePrefix - the route the http handler serves local blobs from
+const MediaRoutePrefix = "/media/"
+
+// LocalBackend - keeps blobs as files under Root so the api works without AWS.
+// The files are served back through the /media/ route
+type LocalBackend struct {
+	Root       string
+	BaseUrl    string
+	SigningKey []byte
+}
+
+// NewLocalBackend - BLOB_LOCAL_DIR is where files are written (default
+// ./media) and BLOB_PUBLIC_URL is the address clients reach this api on
+// (default http://localhost:$PORT). BLOB_SIGNING_KEY signs presigned urls, a
+// random key is used when unset so urls do not survive a restart
+func NewLocalBackend() (*LocalBackend, error) {
+	root := os.Getenv("BLOB_LOCAL_DIR")
+	if root == "" {
+		root = "media"
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll in blob.NewLocalBackend failed for %v", err)
+	}
+	baseUrl := os.Getenv("BLOB_PUBLIC_URL")
+	if baseUrl == "" {
+		baseUrl = fmt.Sprintf("http://localhost:%s", os.Getenv("PORT"))
+	}
+	signingKey := []byte(os.Getenv("BLOB_SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("rand.Read in blob.NewLocalBackend failed for %v", err)
+		}
+	}
+	log.Infof("storing blobs on local disk in %s", root)
+	return &LocalBackend{
+		Root:       root,
+		BaseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		SigningKey: signingKey,
+	}, nil
+}
+
+// path - keys are flat file names. Anything that could escape Root is rejected,
+// as are dot files so in-progress uploads are never served
+func (b *LocalBackend) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") || strings.ContainsRune(key, '\\') {
+		return "", nectar_errors.BadRequestError{Message: fmt.Sprintf("invalid blob key: %q", key)}
+	}
+	return filepath.Join(b.Root, key), nil
+}
+
+// Put - writes to a temporary file first so readers never see a partial blob
+func (b *LocalBackend) Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	tag := "blob.LocalBackend.Put"
+	path, err := b.path(key)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(b.Root, ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("os.CreateTemp in %s failed for %v", tag, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("io.Copy in %s failed for %v", tag, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("file.Close in %s failed for %v", tag, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("os.Rename in %s failed for %v", tag, err)
+	}
+	return b.BaseUrl + MediaRoutePrefix + url.PathEscape(key), nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove in blob.LocalBackend.Delete failed for %v", err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, time.Time{}, &nectar_errors.NoEntityError{Message: fmt.Sprintf("no blob with key: %s", key)}
+		}
+		return nil, time.Time{}, fmt.Errorf("os.Open in blob.LocalBackend.Open failed for %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, time.Time{}, fmt.Errorf("file.Stat in blob.LocalBackend.Open failed for %v", err)
+	}
+	return file, info.ModTime(), nil
+}
+
+// PresignPut - a /media/ url carrying an expiry and a signature over the key,
+// expiry and content type, checked by AcceptPresignedPut
+func (b *LocalBackend) PresignPut(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error) {
+	if _, err := b.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", b.sign(key, expires, contentType))
+	return b.BaseUrl + MediaRoutePrefix + url.PathEscape(key) + "?" + query.Encode(), nil
+}
+
+func (b *LocalBackend) AcceptPresignedPut(ctx context.Context, key string, query url.Values, contentType string, body io.Reader) error {
+	expires := query.Get("expires")
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nectar_errors.ForbiddenError{Message: "upload url has expired"}
+	}
+	signature, err := hex.DecodeString(query.Get("signature"))
+	expected, _ := hex.DecodeString(b.sign(key, expires, contentType))
+	if err != nil || !hmac.Equal(signature, expected) {
+		return nectar_errors.ForbiddenError{Message: "upload url signature is invalid"}
+	}
+	_, err = b.Put(ctx, key, body, contentType)
+	return err
+}
+
+func (b *LocalBackend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	file, _, err := b.Open(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.(*os.File).Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("file.Stat in blob.LocalBackend.Get failed for %v", err)
+	}
+	return file, info.Size(), nil
+}
+
+func (b *LocalBackend) sign(key string, expires string, contentType string) string {
+	mac := hmac.New(sha256.New, b.SigningKey)
+	mac.Write([]byte(key + "\n" + expires + "\n" + contentType))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (b *LocalBackend) KeyForUrl(rawUrl string) (string, bool) {
+	escapedKey := strings.TrimPrefix(rawUrl, b.BaseUrl+MediaRoutePrefix)
+	if escapedKey == rawUrl {
+		return "", false
+	}
+	key, err := url.PathUnescape(escapedKey)
+	if err != nil {
+		return "", false
+	}
+	if _, err := b.path(key); err != nil {
+		return "", false
+	}
+	return key, true
+}
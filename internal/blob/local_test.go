@@ -0,0 +1,100 @@
+//go:build integration
+
+package blob
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalBackend(t *testing.T) {
+	t.Run("test upload, open and delete a blob", func(t *testing.T) {
+		backend := &LocalBackend{Root: t.TempDir(), BaseUrl: "http://localhost:8080"}
+		service := NewService(backend)
+
+		source := filepath.Join(t.TempDir(), "leaf.jpg")
+		assert.NoError(t, os.WriteFile(source, []byte("not really a jpeg"), 0o644))
+
+		urls, err := service.UploadToBlobStore([]string{source}, context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"http://localhost:8080/media/leaf.jpg"}, urls)
+
+		file, _, err := service.Open(context.Background(), "leaf.jpg")
+		assert.NoError(t, err)
+		contents, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "not really a jpeg", string(contents))
+		file.Close()
+
+		assert.NoError(t, service.DeleteFromBlobStore("leaf.jpg"))
+		_, _, err = service.Open(context.Background(), "leaf.jpg")
+		var noEntityError *nectar_errors.NoEntityError
+		assert.ErrorAs(t, err, &noEntityError)
+	})
+
+	t.Run("test keys cannot escape the root directory", func(t *testing.T) {
+		backend := &LocalBackend{Root: t.TempDir()}
+		for _, key := range []string{"../secret", "a/b", ".upload-123", ""} {
+			_, _, err := backend.Open(context.Background(), key)
+			assert.ErrorAs(t, err, &nectar_errors.BadRequestError{}, key)
+		}
+	})
+
+	t.Run("test presigned puts are checked against their signature", func(t *testing.T) {
+		backend := &LocalBackend{Root: t.TempDir(), BaseUrl: "http://localhost:8080", SigningKey: []byte("secret")}
+		service := NewService(backend)
+
+		signedUrl, err := service.PresignPut(context.Background(), "upload-1.jpg", "image/jpeg", time.Minute)
+		assert.NoError(t, err)
+		parsed, err := url.Parse(signedUrl)
+		assert.NoError(t, err)
+		assert.Equal(t, "/media/upload-1.jpg", parsed.Path)
+
+		err = service.AcceptPresignedPut(context.Background(), "upload-1.jpg", parsed.Query(), "image/png", strings.NewReader("bytes"))
+		assert.ErrorAs(t, err, &nectar_errors.ForbiddenError{})
+		err = service.AcceptPresignedPut(context.Background(), "upload-2.jpg", parsed.Query(), "image/jpeg", strings.NewReader("bytes"))
+		assert.ErrorAs(t, err, &nectar_errors.ForbiddenError{})
+
+		err = service.AcceptPresignedPut(context.Background(), "upload-1.jpg", parsed.Query(), "image/jpeg", strings.NewReader("bytes"))
+		assert.NoError(t, err)
+		object, size, err := service.Get(context.Background(), "upload-1.jpg")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), size)
+		object.Close()
+
+		expired, err := backend.PresignPut(context.Background(), "upload-1.jpg", "image/jpeg", -time.Minute)
+		assert.NoError(t, err)
+		parsed, _ = url.Parse(expired)
+		err = service.AcceptPresignedPut(context.Background(), "upload-1.jpg", parsed.Query(), "image/jpeg", strings.NewReader("bytes"))
+		assert.ErrorAs(t, err, &nectar_errors.ForbiddenError{})
+	})
+
+	t.Run("test only urls the backend owns resolve to keys", func(t *testing.T) {
+		local := &LocalBackend{Root: t.TempDir(), BaseUrl: "http://localhost:8080"}
+		key, ok := local.KeyForUrl("http://localhost:8080/media/leaf-thumbnail.jpg")
+		assert.True(t, ok)
+		assert.Equal(t, "leaf-thumbnail.jpg", key)
+		for _, foreign := range []string{"https://example.com/media/leaf.jpg", "http://localhost:8080/media/..%2Fsecret", "http://localhost:8080/media/"} {
+			_, ok := local.KeyForUrl(foreign)
+			assert.False(t, ok, foreign)
+		}
+
+		s3 := &S3Backend{Bucket: "nectar"}
+		key, ok = s3.KeyForUrl("https://nectar.s3.us-east-1.amazonaws.com/leaf.jpg")
+		assert.True(t, ok)
+		assert.Equal(t, "leaf.jpg", key)
+		key, ok = s3.KeyForUrl("https://s3.us-east-1.amazonaws.com/nectar/leaf.jpg")
+		assert.True(t, ok)
+		assert.Equal(t, "leaf.jpg", key)
+		_, ok = s3.KeyForUrl("https://other.s3.us-east-1.amazonaws.com/leaf.jpg")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,128 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type S3Backend struct {
+	Bucket      string
+	Acl         string
+	BlobSession *session.Session
+}
+
+func NewS3Backend() (*S3Backend, error) {
+	log.Info("initializing S3 Connection")
+	accessKey := os.Getenv("ACCESS_KEY")
+	secretKey := os.Getenv("SECRET_KEY")
+	region := os.Getenv("AWS_REGION")
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, ""),
+	})
+	if err != nil {
+		log.Errorf("FAILED to create S3 session: %s", err.Error())
+		return nil, err
+	}
+	log.Info("successfully created S3 session")
+
+	return &S3Backend{
+		BlobSession: sess,
+		Bucket:      os.Getenv("S3_BUCKET"),
+		Acl:         os.Getenv("AWS_ACL"),
+	}, nil
+}
+
+func (b *S3Backend) Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	uploader := s3manager.NewUploader(b.BlobSession)
+	input := &s3manager.UploadInput{
+		ACL:    aws.String(b.Acl),
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	result, err := uploader.UploadWithContext(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("s3manager.UploadWithContext in blob.S3Backend.Put failed for %v", err)
+	}
+	return result.Location, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	service := s3.New(b.BlobSession)
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(key),
+	}
+	result, err := service.DeleteObjectWithContext(ctx, input)
+	if err != nil {
+		if awsError, ok := err.(awserr.Error); ok {
+			log.Errorf("error occurred on AWS side of transaction: %v", awsError)
+		}
+		return err
+	}
+	log.Info("Successfully deleted item from S3", result.String())
+	return nil
+}
+
+func (b *S3Backend) PresignPut(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error) {
+	request, _ := s3.New(b.BlobSession).PutObjectRequest(&s3.PutObjectInput{
+		ACL:         aws.String(b.Acl),
+		Bucket:      aws.String(b.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	request.SetContext(ctx)
+	signedUrl, err := request.Presign(ttl)
+	if err != nil {
+		return "", fmt.Errorf("request.Presign in blob.S3Backend.PresignPut failed for %v", err)
+	}
+	return signedUrl, nil
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	output, err := s3.New(b.BlobSession).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if awsError, ok := err.(awserr.Error); ok && awsError.Code() == s3.ErrCodeNoSuchKey {
+			return nil, 0, &nectar_errors.NoEntityError{Message: fmt.Sprintf("no blob with key: %s", key)}
+		}
+		return nil, 0, fmt.Errorf("s3.GetObjectWithContext in blob.S3Backend.Get failed for %v", err)
+	}
+	return output.Body, aws.Int64Value(output.ContentLength), nil
+}
+
+// KeyForUrl - accepts both the virtual hosted (bucket.s3.region.amazonaws.com/key)
+// and path style (s3.region.amazonaws.com/bucket/key) urls S3 returns
+func (b *S3Backend) KeyForUrl(rawUrl string) (string, bool) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || b.Bucket == "" {
+		return "", false
+	}
+	var key string
+	switch {
+	case strings.HasPrefix(parsed.Host, b.Bucket+".s3"):
+		key = strings.TrimPrefix(parsed.Path, "/")
+	case strings.HasPrefix(parsed.Host, "s3") && strings.HasPrefix(parsed.Path, "/"+b.Bucket+"/"):
+		key = strings.TrimPrefix(parsed.Path, "/"+b.Bucket+"/")
+	}
+	return key, key != ""
+}
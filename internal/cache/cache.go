@@ -2,30 +2,75 @@ package cache
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+
+	defaultTTL        = 5 * time.Minute
+	defaultMaxEntries = 10000
+)
+
+// Driver - where cached values are kept. A ttl of zero keeps the value until it
+// is deleted or evicted
+type Driver interface {
+	Get(key string) (value string, found bool, err error)
+	GetMulti(keys []string) (map[string]string, error)
+	Set(key, value string, ttl time.Duration) error
+	Delete(keys ...string) error
+	Ping(ctx context.Context) error
+}
+
+// Stats - lookups since startup, a bulk get counts once per key
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
 type Cache struct {
-	Client *redis.Client
+	Driver Driver
+	hits   uint64
+	misses uint64
 }
 
+// NewCache - CACHE_DRIVER picks the driver: redis, the default, uses REDISHOST,
+// REDISPORT and REDISPASSWORD, memory keeps up to CACHE_MAX_ENTRIES (default
+// 10000) values in process, evicting the least recently used. Nothing that must
+// survive an eviction or be shared between instances belongs in the cache
 func NewCache() (*Cache, error) {
-	host := os.Getenv("REDISHOST")
-	port := os.Getenv("REDISPORT")
-	password := os.Getenv("REDISPASSWORD")
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", host, port),
-		Password: password,
-		DB:       0,
-	})
-	if _, err := client.Ping().Result(); err != nil {
-		return nil, fmt.Errorf("could not ping the cache: %v", err)
+	driverName := os.Getenv("CACHE_DRIVER")
+	if driverName == "" {
+		driverName = DriverRedis
+	}
+	switch driverName {
+	case DriverRedis:
+		if os.Getenv("REDISHOST") == "" {
+			return nil, fmt.Errorf("REDISHOST must be set for the redis cache driver, set CACHE_DRIVER=%s to cache in process", DriverMemory)
+		}
+		log.Info("using redis cache driver")
+		driver := NewRedisDriver(os.Getenv("REDISHOST"), os.Getenv("REDISPORT"), os.Getenv("REDISPASSWORD"))
+		// The client reconnects by itself, an unreachable cache is reported by
+		// the health check rather than stopping startup
+		if err := driver.Ping(context.Background()); err != nil {
+			log.Warnf("could not ping the cache, continuing without it for now: %v", err)
+		}
+		return &Cache{Driver: driver}, nil
+	case DriverMemory:
+		log.Info("using in-memory cache driver")
+		maxEntries, err := strconv.Atoi(os.Getenv("CACHE_MAX_ENTRIES"))
+		if err != nil || maxEntries <= 0 {
+			maxEntries = defaultMaxEntries
+		}
+		return &Cache{Driver: NewMemoryDriver(maxEntries)}, nil
 	}
-	return &Cache{Client: client}, nil
+	return nil, fmt.Errorf("unknown cache driver: %s", driverName)
 }
 
 type KeyNotFound struct {
@@ -37,24 +82,66 @@ func (k KeyNotFound) Error() string {
 }
 
 func (c *Cache) Get(key string) (value string, found bool, err error) {
-	val, err := c.Client.Get(key).Result()
+	value, found, err = c.Driver.Get(key)
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return "", false, nil
-		}
 		return "", false, fmt.Errorf("failed to get value for key %s. reason: %v", key, err)
 	}
-	return val, true, nil
+	c.count(found)
+	return value, found, nil
 }
 
+// GetMulti - the values of the keys that were found, missing keys are left out
+func (c *Cache) GetMulti(keys []string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return map[string]string{}, nil
+	}
+	values, err := c.Driver.GetMulti(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get values for %d keys. reason: %v", len(keys), err)
+	}
+	atomic.AddUint64(&c.hits, uint64(len(values)))
+	atomic.AddUint64(&c.misses, uint64(len(keys)-len(values)))
+	return values, nil
+}
+
+// Set - keeps the value for five minutes
 func (c *Cache) Set(key, value string) error {
-	err := c.Client.Set(key, value, 5*time.Minute).Err()
-	return fmt.Errorf("failed to set value for key: %s and value %s. reason: %v", key, value, err)
+	return c.SetWithTTL(key, value, defaultTTL)
+}
+
+func (c *Cache) SetWithTTL(key, value string, ttl time.Duration) error {
+	if err := c.Driver.Set(key, value, ttl); err != nil {
+		return fmt.Errorf("failed to set value for key: %s. reason: %v", key, err)
+	}
+	return nil
+}
+
+// Delete - deleting a key that is not cached is not an error
+func (c *Cache) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.Driver.Delete(keys...); err != nil {
+		return fmt.Errorf("failed to delete %d keys. reason: %v", len(keys), err)
+	}
+	return nil
 }
 
 func (c *Cache) CheckCacheHealth(ctx context.Context) error {
-	if _, err := c.Client.Ping().Result(); err != nil {
+	if err := c.Driver.Ping(ctx); err != nil {
 		return fmt.Errorf("FAILED to ping the cache: %v", err)
 	}
 	return nil
 }
+
+func (c *Cache) Stats() Stats {
+	return Stats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses)}
+}
+
+func (c *Cache) count(found bool) {
+	if found {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+}
@@ -0,0 +1,137 @@
+//go:build integration
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	t.Run("test the memory driver evicts the least recently used value", func(t *testing.T) {
+		c := &Cache{Driver: NewMemoryDriver(2)}
+		assert.NoError(t, c.Set("a", "1"))
+		assert.NoError(t, c.Set("b", "2"))
+		_, found, _ := c.Get("a")
+		assert.True(t, found)
+		assert.NoError(t, c.Set("c", "3"))
+
+		values, err := c.GetMulti([]string{"a", "b", "c"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"a": "1", "c": "3"}, values)
+		assert.Equal(t, Stats{Hits: 3, Misses: 1}, c.Stats())
+
+		assert.NoError(t, c.Delete("a", "missing"))
+		_, found, _ = c.Get("a")
+		assert.False(t, found)
+	})
+
+	t.Run("test values expire after their ttl", func(t *testing.T) {
+		driver := NewMemoryDriver(10)
+		now := time.Now()
+		driver.now = func() time.Time { return now }
+		c := &Cache{Driver: driver}
+		assert.NoError(t, c.SetWithTTL("short", "1", time.Second))
+		assert.NoError(t, c.SetWithTTL("forever", "2", 0))
+
+		now = now.Add(time.Hour)
+		_, found, _ := c.Get("short")
+		assert.False(t, found)
+		value, found, _ := c.Get("forever")
+		assert.True(t, found)
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("test typed values round trip", func(t *testing.T) {
+		type token struct {
+			UID    string
+			Claims map[string]interface{}
+		}
+		c := &Cache{Driver: NewMemoryDriver(10)}
+		claims := map[string]interface{}{"role": "admin", "auth_time": int64(1700000000), "firebase": map[string]interface{}{"identities": []interface{}{"a"}}}
+		assert.NoError(t, SetGob(c, "gob", token{UID: "some-user", Claims: claims}, time.Minute))
+		decoded, found, err := GetGob[token](c, "gob")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, token{UID: "some-user", Claims: claims}, decoded)
+
+		assert.NoError(t, SetJSON(c, "json", []string{"a", "b"}, time.Minute))
+		list, found, err := GetJSON[[]string](c, "json")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []string{"a", "b"}, list)
+
+		_, found, err = GetGob[token](c, "json")
+		assert.False(t, found)
+		assert.True(t, errors.As(err, &DecodeError{}))
+	})
+
+	t.Run("test a load that started before an invalidation is not cached", func(t *testing.T) {
+		entities := NewEntities[string](&Cache{Driver: NewMemoryDriver(10)}, "entity", time.Minute)
+		value := "before"
+		loads := 0
+		started, release := make(chan struct{}), make(chan struct{})
+		load := func(ctx context.Context, id string) (*string, error) {
+			loads++
+			v := value
+			if loads == 1 {
+				close(started)
+				<-release
+			}
+			return &v, nil
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			v, err := entities.Get(context.Background(), "some-id", load)
+			assert.NoError(t, err)
+			assert.Equal(t, "before", *v)
+		}()
+		<-started
+		//The write lands and invalidates while the first load is still reading
+		value = "after"
+		entities.Invalidate("some-id")
+		close(release)
+		<-done
+
+		v, err := entities.Get(context.Background(), "some-id", load)
+		assert.NoError(t, err)
+		assert.Equal(t, "after", *v)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("test a failed invalidation skips the stale copy", func(t *testing.T) {
+		c := &failingDelete{Cache: &Cache{Driver: NewMemoryDriver(10)}}
+		entities := NewEntities[string](c, "entity", time.Minute)
+		value := "before"
+		load := func(ctx context.Context, id string) (*string, error) {
+			v := value
+			return &v, nil
+		}
+		_, err := entities.Get(context.Background(), "some-id", load)
+		assert.NoError(t, err)
+
+		value = "after"
+		entities.Invalidate("some-id")
+		v, err := entities.Get(context.Background(), "some-id", load)
+		assert.NoError(t, err)
+		assert.Equal(t, "after", *v)
+		//The stale copy was overwritten, so later reads are served from the cache
+		value = "later"
+		v, err = entities.Get(context.Background(), "some-id", load)
+		assert.NoError(t, err)
+		assert.Equal(t, "after", *v)
+	})
+}
+
+type failingDelete struct {
+	*Cache
+}
+
+func (f *failingDelete) Delete(keys ...string) error {
+	return errors.New("cache down")
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// MemoryDriver - an in-process LRU cache, for running without redis. Expired
+// values are dropped when they are next looked up or evicted
+type MemoryDriver struct {
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+func NewMemoryDriver(maxEntries int) *MemoryDriver {
+	return &MemoryDriver{
+		MaxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+func (d *MemoryDriver) Get(key string) (string, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	value, found := d.get(key, d.now())
+	return value, found, nil
+}
+
+func (d *MemoryDriver) GetMulti(keys []string) (map[string]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	values := map[string]string{}
+	for _, key := range keys {
+		if value, found := d.get(key, now); found {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+func (d *MemoryDriver) get(key string, now time.Time) (string, bool) {
+	element, ok := d.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(now) {
+		d.remove(element)
+		return "", false
+	}
+	d.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (d *MemoryDriver) Set(key, value string, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = d.now().Add(ttl)
+	}
+	if element, ok := d.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		d.order.MoveToFront(element)
+		return nil
+	}
+	d.entries[key] = d.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for d.MaxEntries > 0 && d.order.Len() > d.MaxEntries {
+		d.remove(d.order.Back())
+	}
+	return nil
+}
+
+func (d *MemoryDriver) Delete(keys ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := d.entries[key]; ok {
+			d.remove(element)
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDriver) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (d *MemoryDriver) remove(element *list.Element) {
+	d.order.Remove(element)
+	delete(d.entries, element.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+type RedisDriver struct {
+	Client *redis.Client
+}
+
+func NewRedisDriver(host string, port string, password string) *RedisDriver {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", host, port),
+		Password: password,
+		DB:       0,
+	})
+	return &RedisDriver{Client: client}
+}
+
+func (d *RedisDriver) Get(key string) (string, bool, error) {
+	val, err := d.Client.Get(key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return val, true, nil
+}
+
+func (d *RedisDriver) GetMulti(keys []string) (map[string]string, error) {
+	vals, err := d.Client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for i, val := range vals {
+		// Missing keys come back as nil
+		if s, ok := val.(string); ok {
+			values[keys[i]] = s
+		}
+	}
+	return values, nil
+}
+
+func (d *RedisDriver) Set(key, value string, ttl time.Duration) error {
+	return d.Client.Set(key, value, ttl).Err()
+}
+
+func (d *RedisDriver) Delete(keys ...string) error {
+	return d.Client.Del(keys...).Err()
+}
+
+func (d *RedisDriver) Ping(ctx context.Context) error {
+	return d.Client.WithContext(ctx).Ping().Err()
+}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"time"
+)
+
+func init() {
+	// The types json decodes into interface values, e.g. the claims of a token
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+type Getter interface {
+	Get(key string) (value string, found bool, err error)
+}
+
+type Setter interface {
+	SetWithTTL(key, value string, ttl time.Duration) error
+}
+
+type Deleter interface {
+	Delete(keys ...string) error
+}
+
+// KeyValue - what the cached stores need of a cache, satisfied by *Cache
+type KeyValue interface {
+	Getter
+	Setter
+	Deleter
+}
+
+// DecodeError - the cached value is not a T, e.g. it was written by an older
+// version. Callers usually treat it as a miss
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode the cached value for key %s: %v", e.Key, e.Err)
+}
+
+func (e DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func GetJSON[T any](c Getter, key string) (T, bool, error) {
+	var value T
+	cached, found, err := c.Get(key)
+	if err != nil || !found {
+		return value, false, err
+	}
+	if err := json.Unmarshal([]byte(cached), &value); err != nil {
+		return value, false, DecodeError{Key: key, Err: err}
+	}
+	return value, true, nil
+}
+
+func SetJSON(c Setter, key string, value any, ttl time.Duration) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("json.Marshal in cache.SetJSON failed for %v", err)
+	}
+	return c.SetWithTTL(key, string(encoded), ttl)
+}
+
+// GetGob - for values json can not round trip, e.g. int64s inside interface
+// values. Concrete types held by interface values must be registered with gob
+func GetGob[T any](c Getter, key string) (T, bool, error) {
+	var value T
+	cached, found, err := c.Get(key)
+	if err != nil || !found {
+		return value, false, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(cached)
+	if err != nil {
+		return value, false, DecodeError{Key: key, Err: err}
+	}
+	if err := gob.NewDecoder(bytes.NewReader(decoded)).Decode(&value); err != nil {
+		return value, false, DecodeError{Key: key, Err: err}
+	}
+	return value, true, nil
+}
+
+func SetGob(c Setter, key string, value any, ttl time.Duration) error {
+	b := bytes.Buffer{}
+	if err := gob.NewEncoder(&b).Encode(value); err != nil {
+		return fmt.Errorf("gob.Encode in cache.SetGob failed for %v", err)
+	}
+	return c.SetWithTTL(key, base64.StdEncoding.EncodeToString(b.Bytes()), ttl)
+}
+
+// unsettledTTL - how long an entity is cached for while a failed invalidation
+// may have left a stale copy behind
+const unsettledTTL = 10 * time.Second
+
+// Entities - reads entities of type T by id through the cache, for the cached
+// stores. Concurrent misses of the same id share one load, and a cache that can
+// not be reached is skipped rather than failing the read
+type Entities[T any] struct {
+	Cache KeyValue
+	Name  string
+	TTL   time.Duration
+	group singleflight.Group
+	mu    sync.Mutex
+	// loads - the loads of each key in progress, which an invalidation marks
+	// stale so they do not cache what they read before the write
+	loads map[string][]*entityLoad
+	// unsettled - keys whose invalidation failed, until when the cached copy
+	// is not trusted
+	unsettled map[string]time.Time
+}
+
+type entityLoad struct {
+	stale bool
+}
+
+// NewEntities - name prefixes the keys and names the entity in logs
+func NewEntities[T any](c KeyValue, name string, ttl time.Duration) *Entities[T] {
+	return &Entities[T]{Cache: c, Name: name, TTL: ttl}
+}
+
+func (e *Entities[T]) Key(id string) string {
+	return e.Name + ":" + id
+}
+
+// Get - returns the cached entity, or the one load returns, which is cached
+// unless the entity was invalidated while it loaded. While an invalidation of
+// the key has failed the cached copy is skipped, and the loaded one is cached
+// briefly, overwriting the stale copy
+func (e *Entities[T]) Get(ctx context.Context, id string, load func(context.Context, string) (*T, error)) (*T, error) {
+	key := e.Key(id)
+	unsettled := e.isUnsettled(key)
+	if !unsettled {
+		cached, found, err := GetJSON[T](e.Cache, key)
+		if err != nil {
+			log.Warnf("reading %s %s from the cache failed: %v", e.Name, id, err)
+		}
+		if found {
+			return &cached, nil
+		}
+	}
+	v, err, _ := e.group.Do(key, func() (interface{}, error) {
+		l := e.beginLoad(key)
+		v, err := load(ctx, id)
+		if err != nil {
+			e.endLoad(key, l)
+			return nil, err
+		}
+		e.cacheLoaded(key, l, v, unsettled)
+		return v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers sharing a load each get their own entity to change
+	loaded := *v.(*T)
+	return &loaded, nil
+}
+
+// Invalidate - call after a write, whether or not it failed. Loads in progress
+// are marked stale, and forgetting the keys stops later reads joining them
+func (e *Entities[T]) Invalidate(ids ...string) {
+	keys := make([]string, 0, len(ids))
+	e.mu.Lock()
+	for _, id := range ids {
+		key := e.Key(id)
+		for _, l := range e.loads[key] {
+			l.stale = true
+		}
+		e.group.Forget(key)
+		keys = append(keys, key)
+	}
+	e.mu.Unlock()
+	if err := e.Cache.Delete(keys...); err != nil {
+		log.Errorf("Cache.Delete in cache.Entities.Invalidate failed for %v", err)
+		e.markUnsettled(keys)
+		return
+	}
+	e.settle(keys)
+}
+
+func (e *Entities[T]) beginLoad(key string) *entityLoad {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.loads == nil {
+		e.loads = map[string][]*entityLoad{}
+	}
+	l := &entityLoad{}
+	e.loads[key] = append(e.loads[key], l)
+	return l
+}
+
+// cacheLoaded - caches v unless the load went stale. Holding the lock while
+// caching means an invalidation either marks the load stale first, or deletes
+// what it cached afterwards
+func (e *Entities[T]) cacheLoaded(key string, l *entityLoad, v *T, unsettled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removeLoad(key, l)
+	if l.stale {
+		return
+	}
+	ttl := e.TTL
+	if unsettled && unsettledTTL < ttl {
+		ttl = unsettledTTL
+	}
+	if err := SetJSON(e.Cache, key, v, ttl); err != nil {
+		log.Warnf("caching %s failed: %v", key, err)
+		return
+	}
+	if unsettled {
+		// The stale copy was overwritten
+		delete(e.unsettled, key)
+	}
+}
+
+func (e *Entities[T]) endLoad(key string, l *entityLoad) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removeLoad(key, l)
+}
+
+// removeLoad - must be called with mu held
+func (e *Entities[T]) removeLoad(key string, l *entityLoad) {
+	loads := e.loads[key]
+	for i := range loads {
+		if loads[i] == l {
+			loads = append(loads[:i], loads[i+1:]...)
+			break
+		}
+	}
+	if len(loads) == 0 {
+		delete(e.loads, key)
+		return
+	}
+	e.loads[key] = loads
+}
+
+func (e *Entities[T]) markUnsettled(keys []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.unsettled == nil {
+		e.unsettled = map[string]time.Time{}
+	}
+	until := time.Now().Add(e.TTL)
+	for _, key := range keys {
+		e.unsettled[key] = until
+	}
+}
+
+func (e *Entities[T]) settle(keys []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, key := range keys {
+		delete(e.unsettled, key)
+	}
+}
+
+func (e *Entities[T]) isUnsettled(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	until, ok := e.unsettled[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		// Whatever was cached before the failed invalidation has expired
+		delete(e.unsettled, key)
+		return false
+	}
+	return true
+}
@@ -2,26 +2,34 @@ package care
 
 import (
 	"context"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"time"
 )
 
 type LogEntry struct {
-	Id            string `json:"id"`
-	PlantId       string `json:"plantId"`
-	Notes         string `json:"notes"`
-	CareDate      string `json:"careDate"`
-	CreatedAt     string `json:"createdAt"`
-	PlantImage    string `json:"plantImage"`
-	PlantName     string `json:"plantName"`
-	WasWatered    bool   `json:"wasWatered"`
-	WasFertilized bool   `json:"wasFertilized"`
+	Id            string    `json:"id"`
+	PlantId       string    `json:"plantId"`
+	Notes         string    `json:"notes"`
+	CareDate      string    `json:"careDate"`
+	CreatedAt     string    `json:"createdAt"`
+	PlantImage    string    `json:"plantImage"`
+	PlantName     string    `json:"plantName"`
+	WasWatered    bool      `json:"wasWatered"`
+	WasFertilized bool      `json:"wasFertilized"`
+	UpdatedAt     time.Time `json:"updatedAt"`
 }
 
 type Store interface {
-	GetAllUsersCareLogEntries(ctx context.Context, userId string) ([]LogEntry, error)
-	GetCareLogsEntries(ctx context.Context, plantId string) ([]LogEntry, error)
+	GetAllUsersCareLogEntries(ctx context.Context, userId string, page pagination.Params) (pagination.Page[LogEntry], error)
+	GetCareLogsEntries(ctx context.Context, plantId string, page pagination.Params) (pagination.Page[LogEntry], error)
 	AddCareLogEntry(ctx context.Context, entry LogEntry) (*LogEntry, error)
 	DeleteCareLogEntry(ctx context.Context, logEntryId string) error
 	UpdateCareLogEntry(ctx context.Context, logEntryId string, entry LogEntry) (*LogEntry, error)
+	GetCareSchedule(ctx context.Context, plantId string) (*Schedule, error)
+	GetCareSchedulesByUserId(ctx context.Context, userId string) ([]Schedule, error)
+	GetAllCareSchedules(ctx context.Context) ([]Schedule, error)
+	UpsertCareSchedule(ctx context.Context, schedule Schedule) (*Schedule, error)
+	DeleteCareSchedule(ctx context.Context, plantId string) error
 }
 
 type Service struct {
@@ -34,12 +42,12 @@ func NewService(store Store) *Service {
 	}
 }
 
-func (s *Service) GetAllUsersCareLogs(ctx context.Context, userId string) ([]LogEntry, error) {
-	return s.Store.GetAllUsersCareLogEntries(ctx, userId)
+func (s *Service) GetAllUsersCareLogs(ctx context.Context, userId string, page pagination.Params) (pagination.Page[LogEntry], error) {
+	return s.Store.GetAllUsersCareLogEntries(ctx, userId, page)
 }
 
-func (s *Service) GetCareLogsEntries(ctx context.Context, plantId string) ([]LogEntry, error) {
-	return s.Store.GetCareLogsEntries(ctx, plantId)
+func (s *Service) GetCareLogsEntries(ctx context.Context, plantId string, page pagination.Params) (pagination.Page[LogEntry], error) {
+	return s.Store.GetCareLogsEntries(ctx, plantId, page)
 }
 
 func (s *Service) AddCareLogEntry(ctx context.Context, entry LogEntry) (*LogEntry, error) {
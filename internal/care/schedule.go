@@ -0,0 +1,113 @@
+package care
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Schedule - the watering and fertilizing intervals a user has set for a plant,
+// along with the due dates computed from the most recent care log entries.
+// An interval of zero means that kind of care is not scheduled
+type Schedule struct {
+	Id                      string     `json:"id"`
+	PlantId                 string     `json:"plantId"`
+	PlantName               string     `json:"plantName"`
+	UserId                  string     `json:"userId"`
+	WateringIntervalDays    int        `json:"wateringIntervalDays"`
+	FertilizingIntervalDays int        `json:"fertilizingIntervalDays"`
+	LastWateredDate         *time.Time `json:"lastWateredDate"`
+	LastFertilizedDate      *time.Time `json:"lastFertilizedDate"`
+	NextWateringDate        *time.Time `json:"nextWateringDate"`
+	NextFertilizingDate     *time.Time `json:"nextFertilizingDate"`
+	IsWateringOverdue       bool       `json:"isWateringOverdue"`
+	IsFertilizingOverdue    bool       `json:"isFertilizingOverdue"`
+	CreatedAt               time.Time  `json:"createdAt"`
+}
+
+// IsOverdue - reports whether any scheduled care for the plant is past due
+func (sc *Schedule) IsOverdue() bool {
+	return sc.IsWateringOverdue || sc.IsFertilizingOverdue
+}
+
+// computeDueDates - fills in the next due dates and overdue flags relative to now.
+// When a kind of care has never been logged, the interval is counted from the
+// day the schedule was created
+func (sc *Schedule) computeDueDates(now time.Time) {
+	today := truncateToDate(now)
+	sc.NextWateringDate, sc.IsWateringOverdue = nextDueDate(sc.LastWateredDate, sc.CreatedAt, sc.WateringIntervalDays, today)
+	sc.NextFertilizingDate, sc.IsFertilizingOverdue = nextDueDate(sc.LastFertilizedDate, sc.CreatedAt, sc.FertilizingIntervalDays, today)
+}
+
+func nextDueDate(lastCareDate *time.Time, scheduleCreatedAt time.Time, intervalDays int, today time.Time) (*time.Time, bool) {
+	if intervalDays <= 0 {
+		return nil, false
+	}
+	base := truncateToDate(scheduleCreatedAt)
+	if lastCareDate != nil {
+		base = truncateToDate(*lastCareDate)
+	}
+	next := base.AddDate(0, 0, intervalDays)
+	return &next, next.Before(today)
+}
+
+func truncateToDate(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *Service) GetCareSchedule(ctx context.Context, plantId string) (*Schedule, error) {
+	tag := "care.GetCareSchedule"
+	sc, err := s.Store.GetCareSchedule(ctx, plantId)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetCareSchedule in %s failed for %v", tag, err)
+	}
+	sc.computeDueDates(time.Now())
+	return sc, nil
+}
+
+func (s *Service) GetCareSchedulesByUserId(ctx context.Context, userId string) ([]Schedule, error) {
+	tag := "care.GetCareSchedulesByUserId"
+	schedules, err := s.Store.GetCareSchedulesByUserId(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetCareSchedulesByUserId in %s failed for %v", tag, err)
+	}
+	now := time.Now()
+	for i := range schedules {
+		schedules[i].computeDueDates(now)
+	}
+	return schedules, nil
+}
+
+// GetOverdueCareSchedules - returns every schedule, across all users, that has
+// watering or fertilizing past due
+func (s *Service) GetOverdueCareSchedules(ctx context.Context) ([]Schedule, error) {
+	tag := "care.GetOverdueCareSchedules"
+	schedules, err := s.Store.GetAllCareSchedules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetAllCareSchedules in %s failed for %v", tag, err)
+	}
+	now := time.Now()
+	overdue := []Schedule{}
+	for _, sc := range schedules {
+		sc.computeDueDates(now)
+		if sc.IsOverdue() {
+			overdue = append(overdue, sc)
+		}
+	}
+	return overdue, nil
+}
+
+func (s *Service) SetCareSchedule(ctx context.Context, schedule Schedule) (*Schedule, error) {
+	tag := "care.SetCareSchedule"
+	sc, err := s.Store.UpsertCareSchedule(ctx, schedule)
+	if err != nil {
+		return nil, fmt.Errorf("Store.UpsertCareSchedule in %s failed for %v", tag, err)
+	}
+	sc.computeDueDates(time.Now())
+	return sc, nil
+}
+
+func (s *Service) DeleteCareSchedule(ctx context.Context, plantId string) error {
+	return s.Store.DeleteCareSchedule(ctx, plantId)
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/admin"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"time"
+)
+
+type AccountRow struct {
+	UserRow
+	CreatedAt           time.Time `db:"account_creation_date"`
+	AccountDeletionDate time.Time `db:"account_deletion_date"`
+}
+
+const selectAccounts = `SELECT
+							nectar_users.id,
+							nectar_users.first_name as name,
+							nectar_users.email,
+							nectar_users.username,
+							nectar_users.profile_image,
+							nectar_users.role,
+							nectar_users.account_creation_date,
+							nectar_users.account_deletion_date
+						FROM nectar_users`
+
+func convertAccountRowToAccount(row AccountRow) admin.Account {
+	account := admin.Account{
+		User:      *convertUserRowToUser(row.UserRow),
+		CreatedAt: row.CreatedAt,
+	}
+	// deletion dates are 'infinity' for active accounts
+	if row.AccountDeletionDate.Before(time.Now()) {
+		account.DeactivatedAt = &row.AccountDeletionDate
+	}
+	return account
+}
+
+func (d *Database) ListAccounts(ctx context.Context) ([]admin.Account, error) {
+	tag := "db.admin.ListAccounts"
+	query := selectAccounts + `
+						ORDER BY nectar_users.account_creation_date DESC`
+	var rows []AccountRow
+	if err := d.Client.SelectContext(ctx, &rows, query); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return convertList(rows, convertAccountRowToAccount), nil
+}
+
+func (d *Database) GetAccount(ctx context.Context, userId string) (*admin.Account, error) {
+	tag := "db.admin.GetAccount"
+	query := selectAccounts + `
+						WHERE nectar_users.id = $1`
+	var row AccountRow
+	if err := d.Client.GetContext(ctx, &row, query, userId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &errs.NoEntityError{Message: fmt.Sprintf("no user with id: %s", userId)}
+		}
+		return nil, fmt.Errorf("sqlx.GetContext in %s failed for %v", tag, err)
+	}
+	account := convertAccountRowToAccount(row)
+	return &account, nil
+}
+
+func (d *Database) SetUserRole(ctx context.Context, userId string, role string) error {
+	tag := "db.admin.SetUserRole"
+	query := `UPDATE nectar_users
+				SET role = $1
+				WHERE nectar_users.id = $2`
+	return d.execExpectingRow(ctx, tag, query, userId, role, userId)
+}
+
+func (d *Database) DeactivateUser(ctx context.Context, userId string) error {
+	tag := "db.admin.DeactivateUser"
+	query := `UPDATE nectar_users
+				SET account_deletion_date = current_timestamp
+				WHERE nectar_users.id = $1`
+	return d.execExpectingRow(ctx, tag, query, userId, userId)
+}
+
+func (d *Database) ReactivateUser(ctx context.Context, userId string) error {
+	tag := "db.admin.ReactivateUser"
+	query := `UPDATE nectar_users
+				SET account_deletion_date = 'infinity'
+				WHERE nectar_users.id = $1`
+	return d.execExpectingRow(ctx, tag, query, userId, userId)
+}
+
+// ForceDeletePlant - soft deletes a plant regardless of who owns it
+func (d *Database) ForceDeletePlant(ctx context.Context, plantId string) error {
+	tag := "db.admin.ForceDeletePlant"
+	query := `UPDATE plant
+				SET deletion_date = current_timestamp
+				WHERE 1=1
+				AND plant.id = $1
+				AND plant.deletion_date > current_timestamp`
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	result, err := tx.ExecContext(ctx, query, plantId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		tx.Rollback()
+		return &errs.NoEntityError{Message: fmt.Sprintf("no records with id: %s", plantId)}
+	}
+	if err := addPlantDeletedEvent(ctx, tx, result, plantId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant.addPlantDeletedEvent in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) execExpectingRow(ctx context.Context, tag string, query string, id string, args ...any) error {
+	result, err := d.Client.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	if rowsAffected == 0 {
+		return &errs.NoEntityError{Message: fmt.Sprintf("no records with id: %s", id)}
+	}
+	return nil
+}
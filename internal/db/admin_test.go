@@ -0,0 +1,71 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"testing"
+)
+
+func TestAdminDatabase(t *testing.T) {
+	t.Run("test suspend and reactivate a user", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := uuid.NewV4().String()
+		_, err = db.AddUser(context.Background(), user.User{
+			Id:       userId,
+			Name:     "Kevin",
+			Email:    userId + "@email.com",
+			Username: userId,
+		})
+		assert.NoError(t, err)
+
+		account, err := db.GetAccount(context.Background(), userId)
+		assert.NoError(t, err)
+		assert.Equal(t, user.RoleUser, account.Role)
+		assert.Nil(t, account.DeactivatedAt)
+
+		err = db.DeactivateUser(context.Background(), userId)
+		assert.NoError(t, err)
+		account, err = db.GetAccount(context.Background(), userId)
+		assert.NoError(t, err)
+		assert.NotNil(t, account.DeactivatedAt)
+
+		//Suspended users have no role for the purposes of authentication
+		_, err = db.GetSessionState(context.Background(), userId, "some-digest")
+		assert.Error(t, err)
+
+		err = db.ReactivateUser(context.Background(), userId)
+		assert.NoError(t, err)
+		state, err := db.GetSessionState(context.Background(), userId, "some-digest")
+		assert.NoError(t, err)
+		assert.Equal(t, user.RoleUser, state.Role)
+	})
+
+	t.Run("test set user role", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := uuid.NewV4().String()
+		_, err = db.AddUser(context.Background(), user.User{
+			Id:       userId,
+			Name:     "Kevin",
+			Email:    userId + "@email.com",
+			Username: userId,
+		})
+		assert.NoError(t, err)
+
+		err = db.SetUserRole(context.Background(), userId, user.RoleModerator)
+		assert.NoError(t, err)
+		state, err := db.GetSessionState(context.Background(), userId, "some-digest")
+		assert.NoError(t, err)
+		assert.Equal(t, user.RoleModerator, state.Role)
+
+		err = db.SetUserRole(context.Background(), uuid.NewV4().String(), user.RoleModerator)
+		assert.Error(t, err)
+	})
+}
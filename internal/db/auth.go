@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/auth"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"time"
+)
+
+type CredentialsRow struct {
+	UserId         string `db:"user_id"`
+	Email          string `db:"email"`
+	PasswordDigest string `db:"password_digest"`
+}
+
+type RefreshTokenRow struct {
+	UserId      string       `db:"user_id"`
+	TokenDigest string       `db:"token_digest"`
+	ExpiresAt   time.Time    `db:"expires_at"`
+	RevokedAt   sql.NullTime `db:"revoked_at"`
+}
+
+func (d *Database) AddUserCredentials(ctx context.Context, userId string, passwordDigest string) error {
+	tag := "db.auth.AddUserCredentials"
+	query := `INSERT INTO user_credentials (
+					user_id,
+					password_digest)
+				VALUES ($1, $2)
+				ON CONFLICT (user_id) DO UPDATE SET
+					password_digest = EXCLUDED.password_digest,
+					last_updated_at = current_timestamp`
+	if _, err := d.Client.ExecContext(ctx, query, userId, passwordDigest); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// GetUserCredentialsByEmail - returns nil, with no error, when there is no active
+// account with credentials for the given email
+func (d *Database) GetUserCredentialsByEmail(ctx context.Context, email string) (*auth.Credentials, error) {
+	tag := "db.auth.GetUserCredentialsByEmail"
+	query := `SELECT
+					uc.user_id,
+					nu.email,
+					uc.password_digest
+				FROM user_credentials uc
+				INNER JOIN nectar_users nu ON nu.id = uc.user_id
+				WHERE 1 = 1
+				AND lower(nu.email) = lower($1)
+				AND nu.account_deletion_date > CURRENT_TIMESTAMP`
+	var row CredentialsRow
+	if err := d.Client.GetContext(ctx, &row, query, email); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("sqlx.GetContext in %s failed for %v", tag, err)
+	}
+	return &auth.Credentials{
+		UserId:         row.UserId,
+		Email:          row.Email,
+		PasswordDigest: row.PasswordDigest,
+	}, nil
+}
+
+func (d *Database) AddRefreshToken(ctx context.Context, token auth.RefreshToken) error {
+	tag := "db.auth.AddRefreshToken"
+	query := `INSERT INTO refresh_token (
+					user_id,
+					token_digest,
+					expires_at)
+				VALUES ($1, $2, $3)`
+	if _, err := d.Client.ExecContext(ctx, query, token.UserId, token.TokenDigest, token.ExpiresAt); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// GetRefreshToken - returns nil, with no error, when no token matches the digest
+func (d *Database) GetRefreshToken(ctx context.Context, tokenDigest string) (*auth.RefreshToken, error) {
+	tag := "db.auth.GetRefreshToken"
+	query := `SELECT
+					user_id,
+					token_digest,
+					expires_at,
+					revoked_at
+				FROM refresh_token
+				WHERE token_digest = $1`
+	var row RefreshTokenRow
+	if err := d.Client.GetContext(ctx, &row, query, tokenDigest); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("sqlx.GetContext in %s failed for %v", tag, err)
+	}
+	rt := &auth.RefreshToken{
+		UserId:      row.UserId,
+		TokenDigest: row.TokenDigest,
+		ExpiresAt:   row.ExpiresAt,
+	}
+	if row.RevokedAt.Valid {
+		rt.RevokedAt = &row.RevokedAt.Time
+	}
+	return rt, nil
+}
+
+// RevokeRefreshToken - reports whether this call was the one to revoke the token,
+// so two requests racing to rotate the same token cannot both succeed
+func (d *Database) RevokeRefreshToken(ctx context.Context, tokenDigest string) (bool, error) {
+	tag := "db.auth.RevokeRefreshToken"
+	query := `UPDATE refresh_token
+				SET revoked_at = current_timestamp
+				WHERE 1 = 1
+				AND token_digest = $1
+				AND revoked_at IS NULL`
+	result, err := d.Client.ExecContext(ctx, query, tokenDigest)
+	if err != nil {
+		return false, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (d *Database) RevokeAllRefreshTokens(ctx context.Context, userId string) error {
+	tag := "db.auth.RevokeAllRefreshTokens"
+	query := `UPDATE refresh_token
+				SET revoked_at = current_timestamp
+				WHERE 1 = 1
+				AND user_id = $1
+				AND revoked_at IS NULL`
+	if _, err := d.Client.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// RevokeSession - a token that has expired is rejected without one, so callers
+// only revoke tokens that are still valid
+func (d *Database) RevokeSession(ctx context.Context, userId string, tokenDigest string, expiresAt time.Time) error {
+	tag := "db.auth.RevokeSession"
+	query := `INSERT INTO revoked_session (
+					token_digest,
+					user_id,
+					expires_at)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (token_digest) DO NOTHING`
+	if _, err := d.Client.ExecContext(ctx, query, tokenDigest, userId, expiresAt); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// PurgeExpiredSessionRevocations - a revocation is only needed until the token
+// it revokes expires
+func (d *Database) PurgeExpiredSessionRevocations(ctx context.Context) (int64, error) {
+	tag := "db.auth.PurgeExpiredSessionRevocations"
+	query := `DELETE FROM revoked_session
+				WHERE expires_at <= current_timestamp`
+	result, err := d.Client.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	return purged, nil
+}
+
+func (d *Database) RevokeAllSessions(ctx context.Context, userId string, revokedAt time.Time) error {
+	tag := "db.auth.RevokeAllSessions"
+	query := `INSERT INTO user_session_revocation (
+					user_id,
+					revoked_at)
+				VALUES ($1, $2)
+				ON CONFLICT (user_id) DO UPDATE SET
+					revoked_at = GREATEST(user_session_revocation.revoked_at, EXCLUDED.revoked_at)`
+	if _, err := d.Client.ExecContext(ctx, query, userId, revokedAt); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// GetSessionState - the role of the token's user along with both of the
+// token's revocations, in one query as it runs on every authenticated request.
+// Returns a *nectar_errors.NoEntityError for deleted or suspended accounts
+func (d *Database) GetSessionState(ctx context.Context, userId string, tokenDigest string) (*auth.SessionState, error) {
+	tag := "db.auth.GetSessionState"
+	query := `SELECT
+					nectar_users.role,
+					EXISTS (
+						SELECT 1
+						FROM revoked_session
+						WHERE 1 = 1
+						AND revoked_session.token_digest = $2
+						AND revoked_session.expires_at > CURRENT_TIMESTAMP) AS session_revoked,
+					user_session_revocation.revoked_at
+				FROM nectar_users
+				LEFT JOIN user_session_revocation ON user_session_revocation.user_id = nectar_users.id
+				WHERE 1 = 1
+				AND nectar_users.account_deletion_date > CURRENT_TIMESTAMP
+				AND nectar_users.id = $1`
+	var state auth.SessionState
+	var revokedAt sql.NullTime
+	if err := d.Client.QueryRowContext(ctx, query, userId, tokenDigest).Scan(&state.Role, &state.SessionRevoked, &revokedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &errs.NoEntityError{Message: fmt.Sprintf("no active user with id: %s", userId)}
+		}
+		return nil, fmt.Errorf("row.Scan in %s failed for %v", tag, err)
+	}
+	if revokedAt.Valid {
+		state.SessionsRevokedAt = &revokedAt.Time
+	}
+	return &state, nil
+}
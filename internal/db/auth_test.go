@@ -0,0 +1,128 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/auth"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"testing"
+	"time"
+)
+
+func TestCredentialsDatabase(t *testing.T) {
+	t.Run("test add and get user credentials", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := uuid.NewV4().String()
+		email := fmt.Sprintf("%s@email.com", userId)
+		_, err = db.AddUser(context.Background(), user.User{
+			Id:       userId,
+			Name:     "Kevin",
+			Email:    email,
+			Username: userId,
+		})
+		assert.NoError(t, err)
+
+		err = db.AddUserCredentials(context.Background(), userId, "digest")
+		assert.NoError(t, err)
+
+		creds, err := db.GetUserCredentialsByEmail(context.Background(), email)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, creds.UserId)
+		assert.Equal(t, "digest", creds.PasswordDigest)
+	})
+
+	t.Run("test get credentials for unknown email", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		creds, err := db.GetUserCredentialsByEmail(context.Background(), "nobody@email.com")
+		assert.NoError(t, err)
+		assert.Nil(t, creds)
+	})
+
+	t.Run("test rotating and revoking refresh tokens", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := uuid.NewV4().String()
+		digest := uuid.NewV4().String()
+		err = db.AddRefreshToken(context.Background(), auth.RefreshToken{
+			UserId:      userId,
+			TokenDigest: digest,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		assert.NoError(t, err)
+
+		rt, err := db.GetRefreshToken(context.Background(), digest)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, rt.UserId)
+		assert.Nil(t, rt.RevokedAt)
+
+		//Only the first revocation should report success
+		wasRevoked, err := db.RevokeRefreshToken(context.Background(), digest)
+		assert.NoError(t, err)
+		assert.True(t, wasRevoked)
+		wasRevoked, err = db.RevokeRefreshToken(context.Background(), digest)
+		assert.NoError(t, err)
+		assert.False(t, wasRevoked)
+
+		rt, err = db.GetRefreshToken(context.Background(), digest)
+		assert.NoError(t, err)
+		assert.NotNil(t, rt.RevokedAt)
+	})
+
+	t.Run("test revoking a session and every session of a user", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := uuid.NewV4().String()
+		_, err = db.AddUser(context.Background(), user.User{
+			Id:       userId,
+			Name:     "Kevin",
+			Email:    fmt.Sprintf("%s@email.com", userId),
+			Username: userId,
+		})
+		assert.NoError(t, err)
+		digest := uuid.NewV4().String()
+		state, err := db.GetSessionState(context.Background(), userId, digest)
+		assert.NoError(t, err)
+		assert.Equal(t, user.RoleUser, state.Role)
+		assert.False(t, state.SessionRevoked)
+		assert.Nil(t, state.SessionsRevokedAt)
+
+		err = db.RevokeSession(context.Background(), userId, digest, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		state, err = db.GetSessionState(context.Background(), userId, digest)
+		assert.NoError(t, err)
+		assert.True(t, state.SessionRevoked)
+
+		//An expired revocation no longer counts, and is purged
+		expiredDigest := uuid.NewV4().String()
+		err = db.RevokeSession(context.Background(), userId, expiredDigest, time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		state, err = db.GetSessionState(context.Background(), userId, expiredDigest)
+		assert.NoError(t, err)
+		assert.False(t, state.SessionRevoked)
+		purged, err := db.PurgeExpiredSessionRevocations(context.Background())
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, purged, int64(1))
+		var remaining int
+		err = db.Client.GetContext(context.Background(), &remaining, `SELECT count(*) FROM revoked_session WHERE token_digest = $1`, expiredDigest)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, remaining)
+
+		now := time.Now()
+		assert.NoError(t, db.RevokeAllSessions(context.Background(), userId, now))
+		//An earlier revocation never moves the time back
+		assert.NoError(t, db.RevokeAllSessions(context.Background(), userId, now.Add(-time.Hour)))
+		state, err = db.GetSessionState(context.Background(), userId, digest)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, now, *state.SessionsRevokedAt, time.Second)
+	})
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+)
+
+func (d *Database) GetPlantOwnerId(ctx context.Context, plantId string) (string, error) {
+	tag := "db.authz.GetPlantOwnerId"
+	query := `SELECT user_id
+				FROM plant
+				WHERE 1 = 1
+				AND plant.deletion_date > CURRENT_TIMESTAMP
+				AND plant.id = $1`
+	return d.getOwnerId(ctx, tag, query, plantId)
+}
+
+func (d *Database) GetCareLogEntryOwnerId(ctx context.Context, logEntryId string) (string, error) {
+	tag := "db.authz.GetCareLogEntryOwnerId"
+	query := `SELECT p.user_id
+				FROM care_log
+				INNER JOIN plant p ON p.id = care_log.plant_id
+				WHERE care_log.id = $1`
+	return d.getOwnerId(ctx, tag, query, logEntryId)
+}
+
+// GetActiveUserId - a user owns themselves, so this only confirms the account
+// exists and has not been deleted
+func (d *Database) GetActiveUserId(ctx context.Context, userId string) (string, error) {
+	tag := "db.authz.GetActiveUserId"
+	query := `SELECT id
+				FROM nectar_users
+				WHERE 1 = 1
+				AND nectar_users.account_deletion_date > CURRENT_TIMESTAMP
+				AND nectar_users.id = $1`
+	return d.getOwnerId(ctx, tag, query, userId)
+}
+
+func (d *Database) getOwnerId(ctx context.Context, tag string, query string, id string) (string, error) {
+	var ownerId string
+	if err := d.Client.QueryRowContext(ctx, query, id).Scan(&ownerId); err != nil {
+		if err == sql.ErrNoRows {
+			return "", &errs.NoEntityError{Message: fmt.Sprintf("no records with id: %s", id)}
+		}
+		return "", fmt.Errorf("row.Scan in %s failed for %v", tag, err)
+	}
+	return ownerId, nil
+}
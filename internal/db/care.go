@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/jmoiron/sqlx"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
 	"time"
 )
 
@@ -18,32 +22,37 @@ type LogEntryRow struct {
 	Notes         sql.NullString `db:"notes"`
 	WasFertilized bool           `db:"was_fertilized"`
 	WasWatered    bool           `db:"was_watered"`
+	UpdatedAt     time.Time      `db:"last_update_date"`
 }
 
-func mapRowsToLogEntries(rows SqlRows) ([]care.LogEntry, error) {
+// mapRowsToLogEntries - the formatted CreatedAt drops sub-second precision, so
+// the cursors are collected from the raw created_at while scanning
+func mapRowsToLogEntries(rows SqlRows, page pagination.Params) (pagination.Page[care.LogEntry], error) {
 	// Use this method of creating a slice to ensure an empty slice is
 	// returned, instead of nil, in case there is no entries in the database
 	logEntries := []care.LogEntry{}
+	cursors := []pagination.Cursor{}
 	for rows.Next() {
 		var log LogEntryRow
 		var careDate time.Time
 		var createdAt time.Time
-		if err := rows.Scan(&log.Id, &log.PlantId, &log.Notes, &log.WasFertilized, &log.WasWatered, &careDate, &createdAt); err != nil {
-			return nil, fmt.Errorf("rows.Scan failed in db.care.mapRowsToLogEntries for %v", err)
+		if err := rows.Scan(&log.Id, &log.PlantId, &log.Notes, &log.WasFertilized, &log.WasWatered, &careDate, &createdAt, &log.UpdatedAt); err != nil {
+			return pagination.Page[care.LogEntry]{}, fmt.Errorf("rows.Scan failed in db.care.mapRowsToLogEntries for %v", err)
 		}
 		log.CareDate = careDate.Format(time.RFC1123)
 		log.CreatedAt = createdAt.Format(time.RFC1123)
 		logEntry := convertRowsToLogEntry(log)
 		logEntries = append(logEntries, logEntry)
+		cursors = append(cursors, pagination.Cursor{CreatedAt: createdAt, Id: log.Id})
 	}
-	return logEntries, nil
+	return pagination.NewPage(logEntries, page, func(i int) pagination.Cursor { return cursors[i] }), nil
 }
 
 func mapRowsToLogEntry(rows SqlRows) (*care.LogEntry, error) {
 	var log LogEntryRow
 	for rows.Next() {
 		var careDate time.Time
-		if err := rows.Scan(&log.Id, &log.PlantId, &log.Notes, &log.WasFertilized, &log.WasWatered, &careDate, &log.CreatedAt); err != nil {
+		if err := rows.Scan(&log.Id, &log.PlantId, &log.Notes, &log.WasFertilized, &log.WasWatered, &careDate, &log.CreatedAt, &log.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("rows.Scan failed in db.care.mapRowsToLogEntry for %v", err)
 		}
 		log.CareDate = careDate.Format(time.RFC1123)
@@ -63,10 +72,13 @@ func convertRowsToLogEntry(row LogEntryRow) care.LogEntry {
 		CreatedAt:     row.CreatedAt,
 		PlantImage:    row.PlantImage,
 		PlantName:     row.PlantName,
+		UpdatedAt:     row.UpdatedAt,
 	}
 }
 
-func (d *Database) GetAllUsersCareLogEntries(ctx context.Context, userId string) ([]care.LogEntry, error) {
+// GetAllUsersCareLogEntries - one page of the care log entries for all of a
+// user's plants, newest first
+func (d *Database) GetAllUsersCareLogEntries(ctx context.Context, userId string, page pagination.Params) (pagination.Page[care.LogEntry], error) {
 	tag := "db.care.GetAllUsersCareLogsEntries"
 	findCareLogEntries := `SELECT 
 							care_log.id, 
@@ -76,42 +88,53 @@ func (d *Database) GetAllUsersCareLogEntries(ctx context.Context, userId string)
 							care_log.was_watered, 
 							care_log.care_date,
 							care_log.created_at,
+							care_log.last_update_date,
 							p.common_name,
-							pi.image
+							COALESCE(pi.image, '')
 						   	FROM care_log
 						   	INNER JOIN plant p on p.id = care_log.plant_id
 						   	INNER JOIN nectar_users nu on p.user_id = nu.id
-						   	INNER JOIN plant_images pi on pi.plant_id = p.id
+						   	LEFT JOIN LATERAL (
+						   		SELECT image FROM plant_images
+						   		WHERE plant_images.plant_id = p.id
+						   		AND plant_images.is_primary_image = true
+						   		AND plant_images.deletion_date > CURRENT_TIMESTAMP
+						   		LIMIT 1) pi ON true
 						   	WHERE 1 = 1
 						   	AND nu.id = $1
-						   	AND pi.is_primary_image = true
-						   	ORDER BY care_date DESC`
-	rows, err := d.Client.QueryContext(ctx, findCareLogEntries, userId)
+						   	AND ($2::timestamptz IS NULL OR (care_log.created_at, care_log.id) < ($2::timestamptz, $3::uuid))
+						   	ORDER BY care_log.created_at DESC, care_log.id DESC
+						   	LIMIT $4`
+	afterCreatedAt, afterId := page.Args()
+	rows, err := d.Client.QueryContext(ctx, findCareLogEntries, userId, afterCreatedAt, afterId, page.FetchLimit())
 	if err != nil {
-		return nil, fmt.Errorf("QueryContext in %s for %v", tag, err)
+		return pagination.Page[care.LogEntry]{}, fmt.Errorf("QueryContext in %s for %v", tag, err)
 	}
 	defer closeDbRows(rows, findCareLogEntries)
 
 	logEntries := []care.LogEntry{}
+	cursors := []pagination.Cursor{}
 	for rows.Next() {
 		var log LogEntryRow
 		var careDate time.Time
 		var createdAt time.Time
 		err := rows.Scan(
-			&log.Id, &log.PlantId, &log.Notes, &log.WasFertilized, &log.WasWatered, &careDate, &createdAt, &log.PlantName, &log.PlantImage)
+			&log.Id, &log.PlantId, &log.Notes, &log.WasFertilized, &log.WasWatered, &careDate, &createdAt, &log.UpdatedAt, &log.PlantName, &log.PlantImage)
 		if err != nil {
-			return nil, fmt.Errorf("rows.Scan failed in %s for %v", tag, err)
+			return pagination.Page[care.LogEntry]{}, fmt.Errorf("rows.Scan failed in %s for %v", tag, err)
 		}
 		log.CareDate = careDate.Format(time.RFC1123)
 		log.CreatedAt = createdAt.Format(time.RFC1123)
 		logEntry := convertRowsToLogEntry(log)
 		logEntries = append(logEntries, logEntry)
+		cursors = append(cursors, pagination.Cursor{CreatedAt: createdAt, Id: log.Id})
 	}
-	return logEntries, nil
+	return pagination.NewPage(logEntries, page, func(i int) pagination.Cursor { return cursors[i] }), nil
 
 }
 
-func (d *Database) GetCareLogsEntries(ctx context.Context, plantId string) ([]care.LogEntry, error) {
+// GetCareLogsEntries - one page of a plant's care log entries, newest first
+func (d *Database) GetCareLogsEntries(ctx context.Context, plantId string, page pagination.Params) (pagination.Page[care.LogEntry], error) {
 	query := `SELECT 
     			id, 
     			plant_id, 
@@ -119,22 +142,53 @@ func (d *Database) GetCareLogsEntries(ctx context.Context, plantId string) ([]ca
     			was_fertilized, 
     			was_watered,
     			care_date,
-    			created_at
+    			created_at,
+    			last_update_date
 				FROM care_log
 				WHERE plant_id = $1
-				ORDER BY created_at DESC`
-	rows, err := d.Client.QueryContext(ctx, query, plantId)
+				AND ($2::timestamptz IS NULL OR (created_at, id) < ($2::timestamptz, $3::uuid))
+				ORDER BY created_at DESC, id DESC
+				LIMIT $4`
+	afterCreatedAt, afterId := page.Args()
+	rows, err := d.Client.QueryContext(ctx, query, plantId, afterCreatedAt, afterId, page.FetchLimit())
 	if err != nil {
-		return nil, fmt.Errorf("QueryContext in db.care.GetCareLogsEntries for %v", err)
+		return pagination.Page[care.LogEntry]{}, fmt.Errorf("QueryContext in db.care.GetCareLogsEntries for %v", err)
 	}
 	defer closeDbRows(rows, query)
-	entries, err := mapRowsToLogEntries(rows)
+	entries, err := mapRowsToLogEntries(rows, page)
 	if err != nil {
-		return nil, fmt.Errorf("mapRowsToLogEntries in db.care.GetCareLogsEntries for %v", err)
+		return pagination.Page[care.LogEntry]{}, fmt.Errorf("mapRowsToLogEntries in db.care.GetCareLogsEntries for %v", err)
 	}
 	return entries, nil
 }
 
+func (d *Database) GetCareLogEntry(ctx context.Context, logEntryId string) (*care.LogEntry, error) {
+	query := `SELECT
+				id,
+				plant_id,
+				notes,
+				was_fertilized,
+				was_watered,
+				care_date,
+				created_at,
+				last_update_date
+				FROM care_log
+				WHERE id = $1`
+	rows, err := d.Client.QueryContext(ctx, query, logEntryId)
+	if err != nil {
+		return nil, fmt.Errorf("QueryContext in db.care.GetCareLogEntry failed for %v", err)
+	}
+	defer closeDbRows(rows, query)
+	entry, err := mapRowsToLogEntry(rows)
+	if err != nil {
+		return nil, fmt.Errorf("mapRowsToLogEntry in db.care.GetCareLogEntry failed for %v", err)
+	}
+	if entry.Id == "" {
+		return nil, &errs.NoEntityError{Message: fmt.Sprintf("no care log entry with id: %s", logEntryId)}
+	}
+	return entry, nil
+}
+
 func (d *Database) AddCareLogEntry(ctx context.Context, entry care.LogEntry) (*care.LogEntry, error) {
 	query := `INSERT INTO care_log (
                     plant_id, 
@@ -149,7 +203,7 @@ func (d *Database) AddCareLogEntry(ctx context.Context, entry care.LogEntry) (*c
 					:was_fertilized,
 					:care_date
 				)
-				RETURNING id, plant_id, notes, was_fertilized, was_watered, care_date, created_at`
+				RETURNING id, plant_id, notes, was_fertilized, was_watered, care_date, created_at, last_update_date`
 	careLogEntry := LogEntryRow{
 		PlantId:       entry.PlantId,
 		Notes:         sql.NullString{String: entry.Notes, Valid: true},
@@ -157,50 +211,115 @@ func (d *Database) AddCareLogEntry(ctx context.Context, entry care.LogEntry) (*c
 		WasFertilized: entry.WasFertilized,
 		CareDate:      entry.CareDate,
 	}
-	rows, err := d.Client.NamedQueryContext(ctx, query, careLogEntry)
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlx.BeginTxx in db.care.AddCareLogEntry failed for %v", err)
+	}
+	rows, err := sqlx.NamedQueryContext(ctx, tx, query, careLogEntry)
 	if err != nil {
+		tx.Rollback()
 		return nil, fmt.Errorf("NamedQueryContext in db.care.AddCareLogEntry failed for %v", err)
 	}
-	defer closeDbRows(rows, query)
 	logEntry, err := mapRowsToLogEntry(rows)
+	closeDbRows(rows, query)
 	if err != nil {
+		tx.Rollback()
 		return nil, fmt.Errorf("mapRowsToLogEntry in db.care.AddCareLogEntry failed for %v", err)
 	}
+	logged := events.CareLogged{
+		CareLogId:     logEntry.Id,
+		PlantId:       logEntry.PlantId,
+		CareDate:      logEntry.CareDate,
+		Notes:         logEntry.Notes,
+		WasWatered:    logEntry.WasWatered,
+		WasFertilized: logEntry.WasFertilized,
+	}
+	if err := addOutboxEvent(ctx, tx, logged); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.outbox.addOutboxEvent in db.care.AddCareLogEntry failed for %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlx.tx.Commit in db.care.AddCareLogEntry failed for %v", err)
+	}
 	return logEntry, nil
 }
 
 func (d *Database) DeleteCareLogEntry(ctx context.Context, logEntryId string) error {
 	query := `DELETE FROM care_log
-				WHERE id = $1`
-	if _, err := d.Client.ExecContext(ctx, query, logEntryId); err != nil {
-		return fmt.Errorf("ExecContext in db.care.DeleteCareLogEntry failed for %v", err)
+				WHERE id = $1
+				RETURNING plant_id`
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in db.care.DeleteCareLogEntry failed for %v", err)
+	}
+	var plantId string
+	err = tx.QueryRowContext(ctx, query, logEntryId).Scan(&plantId)
+	switch {
+	case err == sql.ErrNoRows:
+		// Nothing was deleted, so there is nothing to publish
+	case err != nil:
+		tx.Rollback()
+		return fmt.Errorf("QueryRowContext in db.care.DeleteCareLogEntry failed for %v", err)
+	default:
+		if err := addOutboxEvent(ctx, tx, events.CareLogDeleted{CareLogId: logEntryId, PlantId: plantId}); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("db.outbox.addOutboxEvent in db.care.DeleteCareLogEntry failed for %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in db.care.DeleteCareLogEntry failed for %v", err)
 	}
 	return nil
 }
 
+// UpdateCareLogEntry - a non-zero entry.UpdatedAt is the last_update_date the
+// caller read, the entry is then only updated if it still has it
 func (d *Database) UpdateCareLogEntry(ctx context.Context, logEntryId string, entry care.LogEntry) (*care.LogEntry, error) {
 	query := `UPDATE care_log 
 				SET 
-				notes = :notes,
-				was_watered = :was_watered,
-				was_fertilized = :was_fertilized
-				WHERE id = :id
-				RETURNING id, plant_id, notes, was_fertilized, was_watered, created_at`
-	row := LogEntryRow{
-		Id:            logEntryId,
-		Notes:         sql.NullString{String: entry.Notes, Valid: true},
-		WasWatered:    entry.WasWatered,
-		WasFertilized: entry.WasFertilized,
+				notes = $1,
+				was_watered = $2,
+				was_fertilized = $3,
+				last_update_date = current_timestamp
+				WHERE id = $4
+				AND ($5::timestamp IS NULL OR last_update_date = $5::timestamp)
+				RETURNING id, plant_id, notes, was_fertilized, was_watered, care_date, created_at, last_update_date`
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlx.BeginTxx in db.care.UpdateCareLogEntry failed for %v", err)
 	}
-
-	rows, err := d.Client.NamedQueryContext(ctx, query, row)
+	notes := sql.NullString{String: entry.Notes, Valid: true}
+	rows, err := tx.QueryContext(ctx, query, notes, entry.WasWatered, entry.WasFertilized, logEntryId, expectedUpdateDate(entry.UpdatedAt))
 	if err != nil {
-		return nil, fmt.Errorf("NamedQueryContext in db.care.UpdateCareLogEntry failed for %v", err)
+		tx.Rollback()
+		return nil, fmt.Errorf("QueryContext in db.care.UpdateCareLogEntry failed for %v", err)
 	}
-	defer closeDbRows(rows, query)
 	updatedEntry, err := mapRowsToLogEntry(rows)
+	closeDbRows(rows, query)
 	if err != nil {
+		tx.Rollback()
 		return nil, fmt.Errorf("mapRowsToLogEntry in db.care.UpdateCareLogEntry failed for %v", err)
 	}
+	if updatedEntry.Id == "" {
+		tx.Rollback()
+		if !entry.UpdatedAt.IsZero() {
+			return nil, errs.PreconditionFailedError{Message: "the care log entry was changed or deleted since it was read"}
+		}
+		return nil, &errs.NoEntityError{Message: fmt.Sprintf("no care log entry with id: %s", logEntryId)}
+	}
+	updatedEvent := events.CareLogUpdated{
+		CareLogId:     updatedEntry.Id,
+		PlantId:       updatedEntry.PlantId,
+		Notes:         updatedEntry.Notes,
+		WasWatered:    updatedEntry.WasWatered,
+		WasFertilized: updatedEntry.WasFertilized,
+	}
+	if err := addOutboxEvent(ctx, tx, updatedEvent); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.outbox.addOutboxEvent in db.care.UpdateCareLogEntry failed for %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlx.tx.Commit in db.care.UpdateCareLogEntry failed for %v", err)
+	}
 	return updatedEntry, nil
 }
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"time"
+)
+
+type CareScheduleRow struct {
+	Id                      string       `db:"id"`
+	PlantId                 string       `db:"plant_id"`
+	PlantName               string       `db:"plant_name"`
+	UserId                  string       `db:"user_id"`
+	WateringIntervalDays    int          `db:"watering_interval_days"`
+	FertilizingIntervalDays int          `db:"fertilizing_interval_days"`
+	LastWateredDate         sql.NullTime `db:"last_watered_date"`
+	LastFertilizedDate      sql.NullTime `db:"last_fertilized_date"`
+	CreatedAt               time.Time    `db:"created_at"`
+}
+
+// selectCareSchedules - the most recent watering and fertilizing dates are
+// derived from care_log so the schedule never drifts from what was logged
+const selectCareSchedules = `SELECT
+								cs.id,
+								cs.plant_id,
+								p.common_name AS plant_name,
+								p.user_id,
+								cs.watering_interval_days,
+								cs.fertilizing_interval_days,
+								(SELECT max(cl.care_date) FROM care_log cl
+									WHERE cl.plant_id = cs.plant_id AND cl.was_watered = true) AS last_watered_date,
+								(SELECT max(cl.care_date) FROM care_log cl
+									WHERE cl.plant_id = cs.plant_id AND cl.was_fertilized = true) AS last_fertilized_date,
+								cs.created_at
+							FROM care_schedule cs
+							INNER JOIN plant p ON p.id = cs.plant_id
+							WHERE 1 = 1
+							AND p.deletion_date > CURRENT_TIMESTAMP`
+
+func convertCareScheduleRowToSchedule(row CareScheduleRow) care.Schedule {
+	sc := care.Schedule{
+		Id:                      row.Id,
+		PlantId:                 row.PlantId,
+		PlantName:               row.PlantName,
+		UserId:                  row.UserId,
+		WateringIntervalDays:    row.WateringIntervalDays,
+		FertilizingIntervalDays: row.FertilizingIntervalDays,
+		CreatedAt:               row.CreatedAt,
+	}
+	if row.LastWateredDate.Valid {
+		sc.LastWateredDate = &row.LastWateredDate.Time
+	}
+	if row.LastFertilizedDate.Valid {
+		sc.LastFertilizedDate = &row.LastFertilizedDate.Time
+	}
+	return sc
+}
+
+func (d *Database) GetCareSchedule(ctx context.Context, plantId string) (*care.Schedule, error) {
+	tag := "db.care_schedule.GetCareSchedule"
+	query := selectCareSchedules + `
+							AND cs.plant_id = $1`
+	var row CareScheduleRow
+	if err := d.Client.GetContext(ctx, &row, query, plantId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &errs.NoEntityError{Message: fmt.Sprintf("no care schedule for plant with id: %s", plantId)}
+		}
+		return nil, fmt.Errorf("sqlx.GetContext in %s failed for %v", tag, err)
+	}
+	sc := convertCareScheduleRowToSchedule(row)
+	return &sc, nil
+}
+
+func (d *Database) GetCareSchedulesByUserId(ctx context.Context, userId string) ([]care.Schedule, error) {
+	tag := "db.care_schedule.GetCareSchedulesByUserId"
+	query := selectCareSchedules + `
+							AND p.user_id = $1
+							ORDER BY p.common_name`
+	var rows []CareScheduleRow
+	if err := d.Client.SelectContext(ctx, &rows, query, userId); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return convertList(rows, convertCareScheduleRowToSchedule), nil
+}
+
+func (d *Database) GetAllCareSchedules(ctx context.Context) ([]care.Schedule, error) {
+	tag := "db.care_schedule.GetAllCareSchedules"
+	query := selectCareSchedules + `
+							AND (cs.watering_interval_days > 0 OR cs.fertilizing_interval_days > 0)`
+	var rows []CareScheduleRow
+	if err := d.Client.SelectContext(ctx, &rows, query); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return convertList(rows, convertCareScheduleRowToSchedule), nil
+}
+
+func (d *Database) UpsertCareSchedule(ctx context.Context, schedule care.Schedule) (*care.Schedule, error) {
+	tag := "db.care_schedule.UpsertCareSchedule"
+	query := `INSERT INTO care_schedule (
+					plant_id,
+					watering_interval_days,
+					fertilizing_interval_days)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (plant_id) DO UPDATE SET
+					watering_interval_days = EXCLUDED.watering_interval_days,
+					fertilizing_interval_days = EXCLUDED.fertilizing_interval_days,
+					last_update_date = current_timestamp`
+	_, err := d.Client.ExecContext(ctx, query, schedule.PlantId, schedule.WateringIntervalDays, schedule.FertilizingIntervalDays)
+	if err != nil {
+		return nil, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	sc, err := d.GetCareSchedule(ctx, schedule.PlantId)
+	if err != nil {
+		return nil, fmt.Errorf("db.care_schedule.GetCareSchedule in %s failed for %w", tag, err)
+	}
+	return sc, nil
+}
+
+func (d *Database) DeleteCareSchedule(ctx context.Context, plantId string) error {
+	tag := "db.care_schedule.DeleteCareSchedule"
+	query := `DELETE FROM care_schedule
+				WHERE plant_id = $1`
+	if _, err := d.Client.ExecContext(ctx, query, plantId); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+	"testing"
+)
+
+func TestCareScheduleDatabase(t *testing.T) {
+
+	t.Run("test set and get a care schedule", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		//First add a new plant to db
+		insertedPlant, err := db.AddPlant(context.Background(), plant.Plant{
+			CommonName:     "testPlant",
+			ScientificName: "scientificName",
+			Toxicity:       "not toxic",
+			UserId:         uuid.NewV4().String(),
+		}, []string{})
+		assert.NoError(t, err)
+
+		//Log a watering so the schedule has a last watered date
+		_, err = db.AddCareLogEntry(context.Background(), care.LogEntry{
+			PlantId:    insertedPlant.PlantId,
+			WasWatered: true,
+			CareDate:   "2023-01-01",
+		})
+		assert.NoError(t, err)
+
+		schedule, err := db.UpsertCareSchedule(context.Background(), care.Schedule{
+			PlantId:              insertedPlant.PlantId,
+			WateringIntervalDays: 7,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 7, schedule.WateringIntervalDays)
+		assert.NotNil(t, schedule.LastWateredDate)
+		assert.Nil(t, schedule.LastFertilizedDate)
+
+		//Setting the schedule again should update it in place
+		updated, err := db.UpsertCareSchedule(context.Background(), care.Schedule{
+			PlantId:                 insertedPlant.PlantId,
+			WateringIntervalDays:    3,
+			FertilizingIntervalDays: 30,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, schedule.Id, updated.Id)
+		assert.Equal(t, 3, updated.WateringIntervalDays)
+		assert.Equal(t, 30, updated.FertilizingIntervalDays)
+	})
+
+	t.Run("test delete a care schedule", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		insertedPlant, err := db.AddPlant(context.Background(), plant.Plant{
+			CommonName: "testPlant",
+			UserId:     uuid.NewV4().String(),
+		}, []string{})
+		assert.NoError(t, err)
+
+		_, err = db.UpsertCareSchedule(context.Background(), care.Schedule{
+			PlantId:              insertedPlant.PlantId,
+			WateringIntervalDays: 7,
+		})
+		assert.NoError(t, err)
+
+		err = db.DeleteCareSchedule(context.Background(), insertedPlant.PlantId)
+		assert.NoError(t, err)
+
+		_, err = db.GetCareSchedule(context.Background(), insertedPlant.PlantId)
+		assert.Error(t, err)
+	})
+}
@@ -4,12 +4,15 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
 	"testing"
+	"time"
 )
 
 func TestCareLogDatabase(t *testing.T) {
@@ -102,13 +105,13 @@ func TestCareLogDatabase(t *testing.T) {
 			entries[i] = *logEntry
 		}
 		//Query for all those entries again
-		queriedEntries, err := db.GetCareLogsEntries(context.Background(), insertedPlant.PlantId)
+		queriedEntries, err := db.GetCareLogsEntries(context.Background(), insertedPlant.PlantId, firstPage)
 		assert.NoError(t, err)
-		assert.Equal(t, numEntries, len(queriedEntries))
+		assert.Equal(t, numEntries, len(queriedEntries.Items))
 
 		//Confirm that these are all the same entries we made earlier
 		for i := 0; i < numEntries; i++ {
-			assert.Equal(t, entries[i], queriedEntries[i])
+			assert.Equal(t, entries[i], queriedEntries.Items[i])
 		}
 	})
 
@@ -141,9 +144,9 @@ func TestCareLogDatabase(t *testing.T) {
 		assert.NoError(t, err)
 
 		//Try to query it again, this should be empty
-		entries, err := db.GetCareLogsEntries(context.Background(), insertedPlant.PlantId)
+		entries, err := db.GetCareLogsEntries(context.Background(), insertedPlant.PlantId, firstPage)
 		assert.NoError(t, err)
-		assert.Equal(t, 0, len(entries))
+		assert.Equal(t, 0, len(entries.Items))
 	})
 
 	t.Run("test update a care log entry", func(t *testing.T) {
@@ -183,4 +186,33 @@ func TestCareLogDatabase(t *testing.T) {
 		assert.NotEqual(t, logEntry, updatedEntry)
 		assert.Equal(t, newNote, updatedEntry.Notes)
 	})
+
+	t.Run("test a conditional update only applies to the version that was read", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+		insertedPlant, err := db.AddPlant(context.Background(), testPlant, []string{})
+		assert.NoError(t, err)
+		logEntry, err := db.AddCareLogEntry(context.Background(), care.LogEntry{PlantId: insertedPlant.PlantId, WasWatered: true})
+		assert.NoError(t, err)
+
+		read, err := db.GetCareLogEntry(context.Background(), logEntry.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, logEntry, read)
+
+		//The version as it comes back from an If-Match header
+		version := time.Unix(0, read.UpdatedAt.UnixNano()).UTC()
+		first := care.LogEntry{Notes: "first", UpdatedAt: version}
+		updatedEntry, err := db.UpdateCareLogEntry(context.Background(), logEntry.Id, first)
+		assert.NoError(t, err)
+		assert.True(t, updatedEntry.UpdatedAt.After(read.UpdatedAt))
+
+		//A second writer that read the same version loses
+		second := care.LogEntry{Notes: "second", UpdatedAt: read.UpdatedAt}
+		_, err = db.UpdateCareLogEntry(context.Background(), logEntry.Id, second)
+		assert.True(t, errors.As(err, &errs.PreconditionFailedError{}))
+
+		current, err := db.GetCareLogEntry(context.Background(), logEntry.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", current.Notes)
+	})
 }
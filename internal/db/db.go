@@ -7,6 +7,7 @@ import (
 	_ "github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"time"
 )
 
 type Database struct {
@@ -53,3 +54,12 @@ func convertList[T any, U any](inputList []T, convertFunc func(T) U) []U {
 	}
 	return outputList
 }
+
+// expectedUpdateDate - the argument of a conditional update, nil when the
+// caller read no version and the update is unconditional
+func expectedUpdateDate(updatedAt time.Time) *time.Time {
+	if updatedAt.IsZero() {
+		return nil
+	}
+	return &updatedAt
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/feed"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"time"
+)
+
+type FeedItemRow struct {
+	Type             string         `db:"type"`
+	Id               string         `db:"id"`
+	OccurredAt       time.Time      `db:"occurred_at"`
+	UserId           string         `db:"user_id"`
+	Username         string         `db:"username"`
+	UserProfileImage sql.NullString `db:"profile_image"`
+	PlantId          string         `db:"plant_id"`
+	PlantName        string         `db:"plant_name"`
+	PlantImage       sql.NullString `db:"plant_image"`
+}
+
+func convertFeedItemRowToItem(row FeedItemRow) feed.Item {
+	return feed.Item{
+		Type:             row.Type,
+		Id:               row.Id,
+		OccurredAt:       row.OccurredAt,
+		UserId:           row.UserId,
+		Username:         row.Username,
+		UserProfileImage: row.UserProfileImage.String,
+		PlantId:          row.PlantId,
+		PlantName:        row.PlantName,
+		PlantImage:       row.PlantImage.String,
+	}
+}
+
+// GetFeed - plants from everyone the user follows, newest first. The primary
+// image is joined laterally so plants without one still appear
+func (d *Database) GetFeed(ctx context.Context, userId string, page pagination.Params) (pagination.Page[feed.Item], error) {
+	tag := "db.feed.GetFeed"
+	query := `SELECT
+					'plant' AS type,
+					p.id,
+					p.created_at AS occurred_at,
+					p.user_id,
+					nu.username,
+					nu.profile_image,
+					p.id AS plant_id,
+					p.common_name AS plant_name,
+					pi.image AS plant_image
+				FROM plant p
+				INNER JOIN following f ON f.followee_id = p.user_id
+				INNER JOIN nectar_users nu ON nu.id = p.user_id
+				LEFT JOIN LATERAL (
+					SELECT image FROM plant_images
+					WHERE plant_images.plant_id = p.id
+					AND plant_images.is_primary_image = true
+					AND plant_images.deletion_date > CURRENT_TIMESTAMP
+					LIMIT 1) pi ON true
+				WHERE 1 = 1
+				AND f.follower_id = $1
+				AND p.deletion_date > CURRENT_TIMESTAMP
+				AND nu.account_deletion_date > CURRENT_TIMESTAMP
+				AND ($2::timestamptz IS NULL OR (p.created_at, p.id) < ($2::timestamptz, $3::uuid))
+				ORDER BY p.created_at DESC, p.id DESC
+				LIMIT $4`
+	afterCreatedAt, afterId := page.Args()
+	var rows []FeedItemRow
+	if err := d.Client.SelectContext(ctx, &rows, query, userId, afterCreatedAt, afterId, page.FetchLimit()); err != nil {
+		return pagination.Page[feed.Item]{}, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	items := convertList(rows, convertFeedItemRowToItem)
+	return pagination.NewPage(items, page, func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: items[i].OccurredAt, Id: items[i].Id}
+	}), nil
+}
@@ -0,0 +1,75 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/feed"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+	"testing"
+)
+
+func TestFeedDatabase(t *testing.T) {
+	t.Run("test feed contains plants from followed users but not their care logs", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		follower, followee, stranger := addTestUser(t, db), addTestUser(t, db), addTestUser(t, db)
+		err = db.FollowUser(context.Background(), follower, followee)
+		assert.NoError(t, err)
+
+		//The followee's plant has no images, it should still show up
+		followedPlant, err := db.AddPlant(context.Background(), plant.Plant{
+			CommonName: "followedPlant",
+			Toxicity:   "not toxic",
+			UserId:     followee,
+		}, []string{})
+		assert.NoError(t, err)
+		_, err = db.AddCareLogEntry(context.Background(), care.LogEntry{
+			PlantId:    followedPlant.PlantId,
+			WasWatered: true,
+			CareDate:   "2023-01-01",
+		})
+		assert.NoError(t, err)
+		secondPlant, err := db.AddPlant(context.Background(), plant.Plant{
+			CommonName: "secondPlant",
+			Toxicity:   "not toxic",
+			UserId:     followee,
+		}, []string{})
+		assert.NoError(t, err)
+		_, err = db.AddPlant(context.Background(), plant.Plant{
+			CommonName: "strangerPlant",
+			Toxicity:   "not toxic",
+			UserId:     stranger,
+		}, []string{})
+		assert.NoError(t, err)
+
+		page, err := db.GetFeed(context.Background(), follower, firstPage)
+		assert.NoError(t, err)
+		assert.Empty(t, page.NextCursor)
+		items := page.Items
+		assert.Equal(t, 2, len(items))
+		//Newest first, the care log is private to the followee
+		assert.Equal(t, secondPlant.PlantId, items[0].PlantId)
+		assert.Equal(t, followedPlant.PlantId, items[1].PlantId)
+		for _, item := range items {
+			assert.Equal(t, feed.ItemTypePlant, item.Type)
+			assert.Equal(t, followee, item.UserId)
+		}
+
+		//A page of one should hand out a cursor that leads to the second item
+		page, err = db.GetFeed(context.Background(), follower, pagination.Params{Limit: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, items[0].Id, page.Items[0].Id)
+		next, err := pagination.NewParams(1, page.NextCursor)
+		assert.NoError(t, err)
+		page, err = db.GetFeed(context.Background(), follower, next)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Items))
+		assert.Equal(t, items[1].Id, page.Items[0].Id)
+		assert.Empty(t, page.NextCursor)
+	})
+}
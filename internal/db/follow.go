@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+)
+
+type ProfileRow struct {
+	Id       string         `db:"id"`
+	Name     sql.NullString `db:"name"`
+	Username string         `db:"username"`
+	ImageUrl sql.NullString `db:"profile_image"`
+}
+
+func convertProfileRowToProfile(row ProfileRow) user.Profile {
+	return user.Profile{
+		Id:       row.Id,
+		Name:     row.Name.String,
+		Username: row.Username,
+		ImageUrl: row.ImageUrl.String,
+	}
+}
+
+// FollowUser - following someone already followed is a no-op
+func (d *Database) FollowUser(ctx context.Context, followerId string, followeeId string) error {
+	tag := "db.follow.FollowUser"
+	query := `INSERT INTO following (
+					follower_id,
+					followee_id)
+				VALUES ($1, $2)
+				ON CONFLICT DO NOTHING`
+	if _, err := d.Client.ExecContext(ctx, query, followerId, followeeId); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) UnfollowUser(ctx context.Context, followerId string, followeeId string) error {
+	tag := "db.follow.UnfollowUser"
+	query := `DELETE FROM following
+				WHERE 1 = 1
+				AND follower_id = $1
+				AND followee_id = $2`
+	if _, err := d.Client.ExecContext(ctx, query, followerId, followeeId); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) GetFollowers(ctx context.Context, userId string) ([]user.Profile, error) {
+	tag := "db.follow.GetFollowers"
+	query := `SELECT
+					nu.id,
+					nu.first_name as name,
+					nu.username,
+					nu.profile_image
+				FROM following f
+				INNER JOIN nectar_users nu ON nu.id = f.follower_id
+				WHERE 1 = 1
+				AND f.followee_id = $1
+				AND nu.account_deletion_date > CURRENT_TIMESTAMP
+				ORDER BY f.started_following_date DESC`
+	var rows []ProfileRow
+	if err := d.Client.SelectContext(ctx, &rows, query, userId); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return convertList(rows, convertProfileRowToProfile), nil
+}
+
+func (d *Database) GetFollowing(ctx context.Context, userId string) ([]user.Profile, error) {
+	tag := "db.follow.GetFollowing"
+	query := `SELECT
+					nu.id,
+					nu.first_name as name,
+					nu.username,
+					nu.profile_image
+				FROM following f
+				INNER JOIN nectar_users nu ON nu.id = f.followee_id
+				WHERE 1 = 1
+				AND f.follower_id = $1
+				AND nu.account_deletion_date > CURRENT_TIMESTAMP
+				ORDER BY f.started_following_date DESC`
+	var rows []ProfileRow
+	if err := d.Client.SelectContext(ctx, &rows, query, userId); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return convertList(rows, convertProfileRowToProfile), nil
+}
+
+func (d *Database) GetFollowingIds(ctx context.Context, userId string) ([]string, error) {
+	tag := "db.follow.GetFollowingIds"
+	query := `SELECT f.followee_id
+				FROM following f
+				INNER JOIN nectar_users nu ON nu.id = f.followee_id
+				WHERE 1 = 1
+				AND f.follower_id = $1
+				AND nu.account_deletion_date > CURRENT_TIMESTAMP`
+	// Use this method of creating a slice to ensure an empty slice is
+	// returned, instead of nil, in case the user follows no one
+	ids := []string{}
+	if err := d.Client.SelectContext(ctx, &ids, query, userId); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return ids, nil
+}
+
+func (d *Database) GetFollowCounts(ctx context.Context, userId string) (uint, uint, error) {
+	tag := "db.follow.GetFollowCounts"
+	query := `SELECT
+					(SELECT count(*) FROM following f
+						INNER JOIN nectar_users nu ON nu.id = f.follower_id
+						WHERE f.followee_id = $1 AND nu.account_deletion_date > CURRENT_TIMESTAMP),
+					(SELECT count(*) FROM following f
+						INNER JOIN nectar_users nu ON nu.id = f.followee_id
+						WHERE f.follower_id = $1 AND nu.account_deletion_date > CURRENT_TIMESTAMP)`
+	var followerCount, followingCount uint
+	if err := d.Client.QueryRowContext(ctx, query, userId).Scan(&followerCount, &followingCount); err != nil {
+		return 0, 0, fmt.Errorf("row.Scan in %s failed for %v", tag, err)
+	}
+	return followerCount, followingCount, nil
+}
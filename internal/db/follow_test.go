@@ -0,0 +1,57 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"testing"
+)
+
+func addTestUser(t testing.TB, db *Database) string {
+	userId := uuid.NewV4().String()
+	_, err := db.AddUser(context.Background(), user.User{
+		Id:       userId,
+		Name:     "Kevin",
+		Email:    userId + "@email.com",
+		Username: userId,
+	})
+	assert.NoError(t, err)
+	return userId
+}
+
+func TestFollowDatabase(t *testing.T) {
+	t.Run("test follow and unfollow a user", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		follower, followee := addTestUser(t, db), addTestUser(t, db)
+		err = db.FollowUser(context.Background(), follower, followee)
+		assert.NoError(t, err)
+		//Following twice should not fail or duplicate the relationship
+		err = db.FollowUser(context.Background(), follower, followee)
+		assert.NoError(t, err)
+
+		following, err := db.GetFollowingIds(context.Background(), follower)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{followee}, following)
+
+		followers, err := db.GetFollowers(context.Background(), followee)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(followers))
+		assert.Equal(t, follower, followers[0].Id)
+
+		followerCount, followingCount, err := db.GetFollowCounts(context.Background(), followee)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), followerCount)
+		assert.Equal(t, uint(0), followingCount)
+
+		err = db.UnfollowUser(context.Background(), follower, followee)
+		assert.NoError(t, err)
+		following, err = db.GetFollowingIds(context.Background(), follower)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(following))
+	})
+}
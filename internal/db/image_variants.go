@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
+)
+
+// AddImageVariants - records the resized variants of an image, keyed by the url
+// of the full size image which is what plant_images and nectar_users store
+func (d *Database) AddImageVariants(ctx context.Context, urls imaging.ImageUrls) error {
+	tag := "db.image_variants.AddImageVariants"
+	variants, err := json.Marshal(urls)
+	if err != nil {
+		return fmt.Errorf("json.Marshal in %s failed for %v", tag, err)
+	}
+	query := `INSERT INTO image_variants (image, variants, captured_at)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (image) DO UPDATE SET variants = EXCLUDED.variants, captured_at = EXCLUDED.captured_at`
+	if _, err := d.Client.ExecContext(ctx, query, urls.Url, variants, urls.CapturedAt); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// setPlantImageCaptureTimes - copies the capture time recorded when an image was
+// processed onto the plant's image rows that do not have one yet
+func setPlantImageCaptureTimes(ctx context.Context, execer sqlx.ExecerContext, plantId string) error {
+	query := `UPDATE plant_images
+				SET captured_at = image_variants.captured_at
+				FROM image_variants
+				WHERE 1=1
+				AND image_variants.image = plant_images.image
+				AND plant_images.plant_id = $1
+				AND plant_images.captured_at IS NULL`
+	if _, err := execer.ExecContext(ctx, query, plantId); err != nil {
+		return fmt.Errorf("ExecContext in db.image_variants.setPlantImageCaptureTimes failed for %v", err)
+	}
+	return nil
+}
+
+// convertImageVariants - images without a row in image_variants predate
+// variants and fall back to the original for every size
+func convertImageVariants(image string, variants []byte, capturedAt sql.NullTime) (imaging.ImageUrls, error) {
+	urls := imaging.LegacyImageUrls(image)
+	if len(variants) > 0 {
+		if err := json.Unmarshal(variants, &urls); err != nil {
+			return imaging.ImageUrls{}, fmt.Errorf("json.Unmarshal in db.image_variants.convertImageVariants failed for %v", err)
+		}
+	}
+	urls.CapturedAt = nil
+	if capturedAt.Valid {
+		urls.CapturedAt = &capturedAt.Time
+	}
+	return urls, nil
+}
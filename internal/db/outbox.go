@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/outbox"
+	"sort"
+	"time"
+)
+
+type OutboxRow struct {
+	Id        int64     `db:"id"`
+	Topic     string    `db:"topic"`
+	Key       string    `db:"message_key"`
+	Payload   []byte    `db:"payload"`
+	CreatedAt time.Time `db:"created_at"`
+	Attempts  int       `db:"attempts"`
+}
+
+func convertOutboxRow(row OutboxRow) outbox.Message {
+	return outbox.Message{
+		Id:        row.Id,
+		Topic:     row.Topic,
+		Key:       row.Key,
+		Payload:   row.Payload,
+		CreatedAt: row.CreatedAt,
+		Attempts:  row.Attempts,
+	}
+}
+
+// addOutboxEvent - writes an event to the outbox, execer must be the
+// transaction of the write the event describes so it is only published if that
+// write commits
+func addOutboxEvent(ctx context.Context, execer sqlx.ExecerContext, payload events.Payload) error {
+	message, err := outbox.NewMessage(ctx, payload)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO outbox (topic, message_key, payload)
+				VALUES ($1, $2, $3)`
+	if _, err := execer.ExecContext(ctx, query, message.Topic, message.Key, message.Payload); err != nil {
+		return fmt.Errorf("ExecContext in db.outbox.addOutboxEvent failed for %v", err)
+	}
+	return nil
+}
+
+// ClaimOutboxMessages - leases the oldest pending message of each key that is
+// due. Rows locked by another relay are skipped rather than waited on
+func (d *Database) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	tag := "db.outbox.ClaimOutboxMessages"
+	query := `UPDATE outbox
+				SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+				WHERE id IN (SELECT pending.id
+					FROM outbox pending
+					WHERE 1=1
+					AND pending.sent_at IS NULL
+					AND pending.failed_at IS NULL
+					AND pending.next_attempt_at <= CURRENT_TIMESTAMP
+					AND (pending.locked_until IS NULL OR pending.locked_until < CURRENT_TIMESTAMP)
+					AND NOT EXISTS (SELECT 1
+						FROM outbox earlier
+						WHERE earlier.message_key = pending.message_key
+						AND earlier.id < pending.id
+						AND earlier.sent_at IS NULL
+						AND earlier.failed_at IS NULL)
+					ORDER BY pending.id
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED)
+				RETURNING id, topic, message_key, payload, created_at, attempts`
+	var rows []OutboxRow
+	if err := d.Client.SelectContext(ctx, &rows, query, limit, lease.Seconds()); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Id < rows[j].Id })
+	return convertList(rows, convertOutboxRow), nil
+}
+
+func (d *Database) MarkOutboxMessageSent(ctx context.Context, id int64) error {
+	tag := "db.outbox.MarkOutboxMessageSent"
+	query := `UPDATE outbox
+				SET sent_at = CURRENT_TIMESTAMP,
+					attempts = attempts + 1,
+					locked_until = NULL
+				WHERE id = $1`
+	if _, err := d.Client.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+// MarkOutboxMessageFailed - a nil nextAttemptAt gives up on the message
+func (d *Database) MarkOutboxMessageFailed(ctx context.Context, id int64, reason string, nextAttemptAt *time.Time) error {
+	tag := "db.outbox.MarkOutboxMessageFailed"
+	query := `UPDATE outbox
+				SET attempts = attempts + 1,
+					last_error = $2,
+					locked_until = NULL,
+					next_attempt_at = COALESCE($3::timestamptz, next_attempt_at),
+					failed_at = CASE WHEN $3::timestamptz IS NULL THEN CURRENT_TIMESTAMP END
+				WHERE id = $1`
+	if _, err := d.Client.ExecContext(ctx, query, id, reason, nextAttemptAt); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) DeleteSentOutboxMessages(ctx context.Context, sentBefore time.Time) (int64, error) {
+	tag := "db.outbox.DeleteSentOutboxMessages"
+	query := `DELETE FROM outbox
+				WHERE sent_at < $1`
+	result, err := d.Client.ExecContext(ctx, query, sentBefore)
+	if err != nil {
+		return 0, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return result.RowsAffected()
+}
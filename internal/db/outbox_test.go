@@ -0,0 +1,103 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/outbox"
+	"testing"
+	"time"
+)
+
+func claimOutboxMessagesForKey(t *testing.T, db *Database, key string) []outbox.Message {
+	messages, err := db.ClaimOutboxMessages(context.Background(), 100000, time.Minute)
+	assert.NoError(t, err)
+	claimed := []outbox.Message{}
+	for _, message := range messages {
+		if message.Key == key {
+			claimed = append(claimed, message)
+		}
+	}
+	return claimed
+}
+
+func TestOutboxDatabase(t *testing.T) {
+	t.Run("test adding a plant writes an event to the outbox", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+		p, err := db.AddPlant(context.Background(), testPlant, []string{})
+		assert.NoError(t, err)
+
+		claimed := claimOutboxMessagesForKey(t, db, p.PlantId)
+		assert.Len(t, claimed, 1)
+		assert.Equal(t, events.TopicPlants, claimed[0].Topic)
+		envelope, payload, err := events.Decode(claimed[0].Payload)
+		assert.NoError(t, err)
+		assert.Equal(t, events.TypePlantCreated, envelope.Type)
+		assert.Equal(t, p.PlantId, payload.AggregateId())
+		assert.NoError(t, db.MarkOutboxMessageSent(context.Background(), claimed[0].Id))
+	})
+
+	t.Run("test messages of a key are claimed in order", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+		key := uuid.NewV4().String()
+		for _, payload := range []events.Payload{events.PlantCreated{PlantId: key}, events.PlantUpdated{PlantId: key}, events.PlantDeleted{PlantId: key}} {
+			assert.NoError(t, addOutboxEvent(context.Background(), db.Client, payload))
+		}
+
+		first := claimOutboxMessagesForKey(t, db, key)
+		assert.Len(t, first, 1)
+		//The head of the key is leased, nothing else of the key is claimable
+		assert.Empty(t, claimOutboxMessagesForKey(t, db, key))
+
+		assert.NoError(t, db.MarkOutboxMessageSent(context.Background(), first[0].Id))
+		second := claimOutboxMessagesForKey(t, db, key)
+		assert.Len(t, second, 1)
+		assert.Greater(t, second[0].Id, first[0].Id)
+
+		//A retried message keeps blocking the key until it is due again
+		next := time.Now().Add(time.Hour)
+		assert.NoError(t, db.MarkOutboxMessageFailed(context.Background(), second[0].Id, "broker unavailable", &next))
+		assert.Empty(t, claimOutboxMessagesForKey(t, db, key))
+
+		//A message that was given up on no longer blocks the key
+		assert.NoError(t, db.MarkOutboxMessageFailed(context.Background(), second[0].Id, "broker unavailable", nil))
+		third := claimOutboxMessagesForKey(t, db, key)
+		assert.Len(t, third, 1)
+		assert.Greater(t, third[0].Id, second[0].Id)
+	})
+
+	t.Run("test image events are written with the image and only when it changed", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+		p, err := db.AddPlant(context.Background(), testPlant, []string{})
+		assert.NoError(t, err)
+		created := claimOutboxMessagesForKey(t, db, p.PlantId)
+		assert.Len(t, created, 1)
+		assert.NoError(t, db.MarkOutboxMessageSent(context.Background(), created[0].Id))
+
+		image := "https://plant-images.test/" + uuid.NewV4().String() + ".jpg"
+		_, err = db.AddPlantImageWithId(context.Background(), p.PlantId, image, 3)
+		assert.NoError(t, err)
+		assert.NoError(t, db.DeletePlantImage(context.Background(), p.PlantId, image))
+		//The image is already gone, this is not an event
+		assert.NoError(t, db.DeletePlantImage(context.Background(), p.PlantId, image))
+
+		var eventTypes []string
+		for {
+			claimed := claimOutboxMessagesForKey(t, db, p.PlantId)
+			if len(claimed) == 0 {
+				break
+			}
+			envelope, _, err := events.Decode(claimed[0].Payload)
+			assert.NoError(t, err)
+			eventTypes = append(eventTypes, envelope.Type)
+			assert.NoError(t, db.MarkOutboxMessageSent(context.Background(), claimed[0].Id))
+		}
+		assert.Equal(t, []string{events.TypeImageAdded, events.TypeImageRemoved}, eventTypes)
+	})
+}
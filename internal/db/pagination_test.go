@@ -0,0 +1,92 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+	"testing"
+	"time"
+)
+
+var firstPage = pagination.Params{Limit: pagination.DefaultLimit}
+
+func TestPaginationDatabase(t *testing.T) {
+	t.Run("test paging through a user's plants visits each plant once", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := addTestUser(t, db)
+		numPlants := 5
+		for i := 0; i < numPlants; i++ {
+			_, err := db.AddPlant(context.Background(), plant.Plant{
+				CommonName: "testPlant",
+				Toxicity:   "not toxic",
+				UserId:     userId,
+			}, []string{})
+			assert.NoError(t, err)
+		}
+
+		seen := map[string]bool{}
+		page := pagination.Params{Limit: 2}
+		for pages := 0; pages < numPlants; pages++ {
+			result, err := db.GetPlantsByUserId(context.Background(), userId, page)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(result.Items), 2)
+			for _, p := range result.Items {
+				assert.False(t, seen[p.PlantId])
+				seen[p.PlantId] = true
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			page, err = pagination.NewParams(2, result.NextCursor)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, numPlants, len(seen))
+	})
+
+	t.Run("test paging through a plant's care log entries", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		insertedPlant, err := db.AddPlant(context.Background(), plant.Plant{
+			CommonName: "testPlant",
+			Toxicity:   "not toxic",
+			UserId:     addTestUser(t, db),
+		}, []string{})
+		assert.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, err := db.AddCareLogEntry(context.Background(), care.LogEntry{
+				PlantId:    insertedPlant.PlantId,
+				WasWatered: true,
+				CareDate:   "2023-01-01",
+			})
+			assert.NoError(t, err)
+		}
+
+		first, err := db.GetCareLogsEntries(context.Background(), insertedPlant.PlantId, pagination.Params{Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(first.Items))
+		assert.NotEmpty(t, first.NextCursor)
+
+		next, err := pagination.NewParams(2, first.NextCursor)
+		assert.NoError(t, err)
+		second, err := db.GetCareLogsEntries(context.Background(), insertedPlant.PlantId, next)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(second.Items))
+		assert.Empty(t, second.NextCursor)
+		assert.NotContains(t, first.Items, second.Items[0])
+	})
+
+	t.Run("test a cursor with an id that is not a uuid is a bad request", func(t *testing.T) {
+		tampered := base64.RawURLEncoding.EncodeToString([]byte(time.Now().UTC().Format(time.RFC3339Nano) + "|not-a-uuid"))
+		_, err := pagination.NewParams(2, tampered)
+		assert.ErrorAs(t, err, &nectar_errors.BadRequestError{})
+	})
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
 	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
 	"time"
 )
@@ -20,12 +24,14 @@ type PlantRow struct {
 	Toxicity         sql.NullString `db:"toxicity"`
 	CreatedAt        time.Time      `db:"created_at"`
 	UserProfileImage sql.NullString `db:"profile_image"`
+	UpdatedAt        time.Time      `db:"last_update_date"`
 }
 
 type imagesRow struct {
 	Image          string `db:"image" sql:"type:text"`
 	PlantId        string `db:"plant_id" sql:"type:uuid"`
 	IsPrimaryImage bool   `db:"is_primary_image" sql:"type:bool"`
+	Position       int    `db:"position" sql:"type:integer"`
 }
 
 func convertPlantRowToPlant(p PlantRow) *plant.Plant {
@@ -38,6 +44,7 @@ func convertPlantRowToPlant(p PlantRow) *plant.Plant {
 		Toxicity:         p.Toxicity.String,
 		CreatedAt:        p.CreatedAt,
 		UserProfileImage: p.UserProfileImage.String,
+		UpdatedAt:        p.UpdatedAt,
 	}
 }
 
@@ -51,6 +58,7 @@ func (d *Database) GetPlant(ctx context.Context, id string) (*plant.Plant, error
     				plant.scientific_name, 
     				plant.toxicity, 
     				plant.created_at, 
+    				plant.last_update_date,
     				nectar_users.username,
     				nectar_users.profile_image
 				FROM plant
@@ -59,7 +67,7 @@ func (d *Database) GetPlant(ctx context.Context, id string) (*plant.Plant, error
 				AND	plant.deletion_date > CURRENT_TIMESTAMP
 				AND plant.id = $1`
 	row := d.Client.QueryRowContext(ctx, query, id)
-	err := row.Scan(&pr.PlantId, &pr.UserId, &pr.CommonName, &pr.ScientificName, &pr.Toxicity, &pr.CreatedAt, &pr.Username, &pr.UserProfileImage)
+	err := row.Scan(&pr.PlantId, &pr.UserId, &pr.CommonName, &pr.ScientificName, &pr.Toxicity, &pr.CreatedAt, &pr.UpdatedAt, &pr.Username, &pr.UserProfileImage)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, &errs.NoEntityError{Message: fmt.Sprintf("no records with id: %s", id)}
@@ -67,15 +75,17 @@ func (d *Database) GetPlant(ctx context.Context, id string) (*plant.Plant, error
 		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
 	}
 	pl := convertPlantRowToPlant(pr)
-	images, err := d.getPlantImages(ctx, pl.PlantId)
+	images, imageUrls, err := d.getPlantImages(ctx, pl.PlantId)
 	if err != nil {
 		return nil, fmt.Errorf("db.plant.getPlantImages in %s failed for %v", tag, err)
 	}
 	pl.Images = images
+	pl.ImageUrls = imageUrls
 	return pl, nil
 }
 
-func (d *Database) GetPlantsByUserId(ctx context.Context, id string) ([]plant.Plant, error) {
+// GetPlantsByUserId - one page of the user's plants, newest first
+func (d *Database) GetPlantsByUserId(ctx context.Context, id string, page pagination.Params) (pagination.Page[plant.Plant], error) {
 	tag := "db.plant.GetPlantsByUserId"
 	query := `SELECT 
     				plant.id, 
@@ -84,60 +94,132 @@ func (d *Database) GetPlantsByUserId(ctx context.Context, id string) ([]plant.Pl
     				plant.scientific_name, 
     				plant.toxicity, 
     				plant.created_at, 
+    				plant.last_update_date,
     				nectar_users.username,
     				nectar_users.profile_image
 				FROM plant
 				JOIN nectar_users ON plant.user_id = nectar_users.id
 				WHERE 1 = 1
 				AND	plant.deletion_date > CURRENT_TIMESTAMP
-				AND plant.user_id = $1`
-	rows, err := d.Client.QueryContext(ctx, query, id)
+				AND plant.user_id = $1
+				AND ($2::timestamptz IS NULL OR (plant.created_at, plant.id) < ($2::timestamptz, $3::uuid))
+				ORDER BY plant.created_at DESC, plant.id DESC
+				LIMIT $4`
+	afterCreatedAt, afterId := page.Args()
+	rows, err := d.Client.QueryContext(ctx, query, id, afterCreatedAt, afterId, page.FetchLimit())
 	if err != nil {
-		return nil, fmt.Errorf("sqlx.QueryContext in %s failed for %s", tag, err.Error())
+		return pagination.Page[plant.Plant]{}, fmt.Errorf("sqlx.QueryContext in %s failed for %s", tag, err.Error())
 	}
 	defer closeDbRows(rows, query)
 	plantList := []plant.Plant{}
 	for rows.Next() {
 		pr := PlantRow{}
-		err := rows.Scan(&pr.PlantId, &pr.UserId, &pr.CommonName, &pr.ScientificName, &pr.Toxicity, &pr.CreatedAt, &pr.Username, &pr.UserProfileImage)
+		err := rows.Scan(&pr.PlantId, &pr.UserId, &pr.CommonName, &pr.ScientificName, &pr.Toxicity, &pr.CreatedAt, &pr.UpdatedAt, &pr.Username, &pr.UserProfileImage)
 		if err != nil {
-			return nil, fmt.Errorf("rows.Scan in %s failed for %v", tag, err)
+			return pagination.Page[plant.Plant]{}, fmt.Errorf("rows.Scan in %s failed for %v", tag, err)
 		}
 		p := convertPlantRowToPlant(pr)
 		plantList = append(plantList, *p)
 	}
-	for i, p := range plantList {
-		images, err := d.getPlantImages(ctx, p.PlantId)
-		if err != nil {
-			return nil, err
-		}
-		plantList[i].Images = images
+	result := pagination.NewPage(plantList, page, func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: plantList[i].CreatedAt, Id: plantList[i].PlantId}
+	})
+	if err := d.attachPlantImages(ctx, result.Items); err != nil {
+		return pagination.Page[plant.Plant]{}, fmt.Errorf("db.plant.attachPlantImages in %s failed for %v", tag, err)
+	}
+	return result, nil
+}
+
+// GetPlantIdsByUserId - the ids of every live plant of the user
+func (d *Database) GetPlantIdsByUserId(ctx context.Context, id string) ([]string, error) {
+	tag := "db.plant.GetPlantIdsByUserId"
+	query := `SELECT plant.id
+				FROM plant
+				WHERE 1 = 1
+				AND	plant.deletion_date > CURRENT_TIMESTAMP
+				AND plant.user_id = $1`
+	plantIds := []string{}
+	if err := d.Client.SelectContext(ctx, &plantIds, query, id); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return plantIds, nil
+}
+
+// plantImages - a plant's live images in order, along with their variants
+type plantImages struct {
+	Images    []string
+	ImageUrls []plant.ImageUrls
+}
+
+func (d *Database) getPlantImages(ctx context.Context, plantId string) ([]string, []plant.ImageUrls, error) {
+	images, err := d.getPlantsImages(ctx, []string{plantId})
+	if err != nil {
+		return nil, nil, err
+	}
+	return images[plantId].Images, images[plantId].ImageUrls, nil
+}
+
+// attachPlantImages - sets the images of every plant with a single query
+func (d *Database) attachPlantImages(ctx context.Context, plants []plant.Plant) error {
+	plantIds := make([]string, len(plants))
+	for i, p := range plants {
+		plantIds[i] = p.PlantId
+	}
+	images, err := d.getPlantsImages(ctx, plantIds)
+	if err != nil {
+		return err
 	}
-	return plantList, nil
+	for i, p := range plants {
+		plants[i].Images = images[p.PlantId].Images
+		plants[i].ImageUrls = images[p.PlantId].ImageUrls
+	}
+	return nil
 }
 
-func (d *Database) getPlantImages(ctx context.Context, plantId string) ([]string, error) {
-	tag := "db.plant.getPlantImages"
-	query := `SELECT image 
-			  FROM plant_images 
+// getPlantsImages - the images of each of the plants, loaded in one query.
+// Every plant is in the result, those without images with empty lists
+func (d *Database) getPlantsImages(ctx context.Context, plantIds []string) (map[string]plantImages, error) {
+	tag := "db.plant.getPlantsImages"
+	result := make(map[string]plantImages, len(plantIds))
+	for _, plantId := range plantIds {
+		result[plantId] = plantImages{Images: []string{}, ImageUrls: []plant.ImageUrls{}}
+	}
+	if len(plantIds) == 0 {
+		return result, nil
+	}
+	query := `SELECT plant_images.plant_id, plant_images.image, image_variants.variants, plant_images.captured_at
+			  FROM plant_images
+			  LEFT JOIN image_variants ON image_variants.image = plant_images.image
 			  WHERE 1=1
-			  AND plant_id = $1
-			  AND deletion_date > CURRENT_TIMESTAMP
-			  LIMIT 3`
-	rows, err := d.Client.QueryContext(ctx, query, plantId)
+			  AND plant_images.plant_id = ANY($1::uuid[])
+			  AND plant_images.deletion_date > CURRENT_TIMESTAMP
+			  ORDER BY plant_images.plant_id, plant_images.position, plant_images.id`
+	rows, err := d.Client.QueryContext(ctx, query, pq.Array(plantIds))
 	if err != nil {
 		return nil, fmt.Errorf("sqlx.QueryContext in %s failed for %v", tag, err)
 	}
 	defer closeDbRows(rows, query)
-	images := []string{}
 	for rows.Next() {
+		var plantId string
 		var im string
-		if err := rows.Scan(&im); err != nil {
+		var variants []byte
+		var capturedAt sql.NullTime
+		if err := rows.Scan(&plantId, &im, &variants, &capturedAt); err != nil {
 			return nil, fmt.Errorf("rows.Scan in %s failed for %v", tag, err)
 		}
-		images = append(images, im)
+		urls, err := convertImageVariants(im, variants, capturedAt)
+		if err != nil {
+			return nil, err
+		}
+		images := result[plantId]
+		images.Images = append(images.Images, im)
+		images.ImageUrls = append(images.ImageUrls, urls)
+		result[plantId] = images
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err in %s failed for %v", tag, err)
 	}
-	return images, nil
+	return result, nil
 }
 
 func (d *Database) AddPlant(ctx context.Context, p plant.Plant, images []string) (*plant.Plant, error) {
@@ -160,21 +242,41 @@ func (d *Database) AddPlant(ctx context.Context, p plant.Plant, images []string)
 		return nil, fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
 	}
 	irList := []imagesRow{}
-	insertImagesQuery := "INSERT INTO plant_images (image, plant_id, is_primary_image) VALUES (:image, :plant_id, :is_primary_image)"
+	insertImagesQuery := "INSERT INTO plant_images (image, plant_id, is_primary_image, position) VALUES (:image, :plant_id, :is_primary_image, :position)"
 	for i, _ := range images {
 		isPrimaryImage := i == 0
-		irList = append(irList, imagesRow{Image: images[i], PlantId: id, IsPrimaryImage: isPrimaryImage})
+		irList = append(irList, imagesRow{Image: images[i], PlantId: id, IsPrimaryImage: isPrimaryImage, Position: i})
 	}
 	if _, err := tx.NamedExecContext(ctx, insertImagesQuery, irList); err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("sqlx.tx.NamedExecContext in %s failed for %v", tag, err)
 	}
-	if err := tx.Commit(); err != nil {
+	if err := setPlantSearchTerms(ctx, tx, id, p.SearchTerms); err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+		return nil, fmt.Errorf("db.plant_search.setPlantSearchTerms in %s failed for %v", tag, err)
+	}
+	if err := setPlantImageCaptureTimes(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.image_variants.setPlantImageCaptureTimes in %s failed for %v", tag, err)
 	}
 	p.PlantId = id
 	p.Images = images
+	created := events.PlantCreated{
+		PlantId:        id,
+		UserId:         p.UserId,
+		CommonName:     p.CommonName,
+		ScientificName: p.ScientificName,
+		Toxicity:       p.Toxicity,
+		Images:         images,
+	}
+	if err := addOutboxEvent(ctx, tx, created); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.outbox.addOutboxEvent in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
 	return &p, nil
 }
 
@@ -184,14 +286,45 @@ func (d *Database) DeletePlant(ctx context.Context, id string) error {
 				SET deletion_date = current_timestamp
 				WHERE 1=1
 				AND plant.id = $1
-				AND plant.user_id = $2`
-	if _, err := d.Client.ExecContext(ctx, query, id, ctx.Value("userId")); err != nil {
-		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+				AND plant.user_id = $2
+				AND plant.deletion_date > current_timestamp`
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	result, err := tx.ExecContext(ctx, query, id, ctx.Value("userId"))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if err := addPlantDeletedEvent(ctx, tx, result, id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant.addPlantDeletedEvent in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
 	}
 	return nil
 }
 
-func (d *Database) UpdatePlant(ctx context.Context, id string, p plant.Plant) (*plant.Plant, error) {
+// addPlantDeletedEvent - only when the delete matched a plant, deleting twice
+// or someone else's plant is not an event
+func addPlantDeletedEvent(ctx context.Context, tx *sqlx.Tx, result sql.Result, plantId string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected in db.plant.addPlantDeletedEvent failed for %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+	return addOutboxEvent(ctx, tx, events.PlantDeleted{PlantId: plantId})
+}
+
+// UpdatePlant - a non-zero p.UpdatedAt is the last_update_date the caller
+// read, the plant is then only updated if it still has it. imagesToDelete are
+// removed before p.Images are added, and the plant may have at most maxImages
+// afterwards
+func (d *Database) UpdatePlant(ctx context.Context, id string, p plant.Plant, imagesToDelete []string, maxImages int) (*plant.Plant, error) {
 	tag := "db.plant.UpdatePlant"
 	query := `UPDATE plant SET
 				common_name = $1,
@@ -200,20 +333,96 @@ func (d *Database) UpdatePlant(ctx context.Context, id string, p plant.Plant) (*
             	last_update_date = current_timestamp
 				WHERE 1=1
 				AND plant.id = $4
-				AND plant.user_id = $5`
+				AND plant.user_id = $5
+				AND ($6::timestamp IS NULL OR plant.last_update_date = $6::timestamp)`
 	tx, err := d.Client.Beginx()
 	if err != nil {
 		return nil, fmt.Errorf("sqlx.Begin in %s failed for %v", tag, err)
 	}
-	tx.MustExecContext(ctx, query, p.CommonName, p.ScientificName, p.Toxicity, p.PlantId, ctx.Value("userId"))
-	insertImagesQuery := "INSERT INTO plant_images (image, plant_id, is_primary_image) VALUES (:image, :plant_id, :is_primary_image) ON CONFLICT DO NOTHING"
+	updated, err := tx.ExecContext(ctx, query, p.CommonName, p.ScientificName, p.Toxicity, p.PlantId, ctx.Value("userId"), expectedUpdateDate(p.UpdatedAt))
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	plantsUpdated, err := updated.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	if plantsUpdated == 0 && !p.UpdatedAt.IsZero() {
+		tx.Rollback()
+		return nil, errs.PreconditionFailedError{Message: "the plant was changed or deleted since it was read"}
+	}
+	if err := lockPlantImages(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.plant_images.lockPlantImages in %s failed for %v", tag, err)
+	}
+	deleteImageQuery := `UPDATE plant_images
+				SET deletion_date = current_timestamp
+				WHERE 1=1
+				AND image = $1
+				AND plant_id = $2
+				AND deletion_date > CURRENT_TIMESTAMP`
+	for _, image := range imagesToDelete {
+		result, err := tx.ExecContext(ctx, deleteImageQuery, image, id)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+		}
+		if err := addImageEvent(ctx, tx, result, events.ImageRemoved{PlantId: id, Image: image}); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("db.plant.addImageEvent in %s failed for %v", tag, err)
+		}
+	}
+	// Images the plant already has keep their position, new ones are appended
+	insertImagesQuery := `INSERT INTO plant_images (image, plant_id, position)
+				SELECT $1::text, $2::uuid, COALESCE(MAX(position) + 1, 0)
+				FROM plant_images
+				WHERE 1=1
+				AND plant_id = $2
+				AND deletion_date > CURRENT_TIMESTAMP
+				HAVING NOT COALESCE(bool_or(image = $1), false)`
 	images := p.Images
 	for i, _ := range images {
-		isPrimaryImage := i == 0
-		if _, err := tx.NamedExecContext(ctx, insertImagesQuery, []imagesRow{{Image: images[i], PlantId: id, IsPrimaryImage: isPrimaryImage}}); err != nil {
+		result, err := tx.ExecContext(ctx, insertImagesQuery, images[i], id)
+		if err != nil {
 			log.Error(err)
 			tx.Rollback()
-			return nil, fmt.Errorf("sqlx.tx.NamedExecContext in %s failed for %v", tag, err)
+			return nil, fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+		}
+		if err := addImageEvent(ctx, tx, result, events.ImageAdded{PlantId: id, Image: images[i]}); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("db.plant.addImageEvent in %s failed for %v", tag, err)
+		}
+	}
+	if err := checkPlantImageLimit(ctx, tx, id, maxImages); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := normalizePlantImagePositions(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.plant_images.normalizePlantImagePositions in %s failed for %v", tag, err)
+	}
+	if err := setPlantSearchTerms(ctx, tx, id, p.SearchTerms); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.plant_search.setPlantSearchTerms in %s failed for %v", tag, err)
+	}
+	if err := setPlantImageCaptureTimes(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.image_variants.setPlantImageCaptureTimes in %s failed for %v", tag, err)
+	}
+	if plantsUpdated > 0 {
+		p.PlantId = id
+		updatedEvent := events.PlantUpdated{
+			PlantId:        id,
+			UserId:         p.UserId,
+			CommonName:     p.CommonName,
+			ScientificName: p.ScientificName,
+			Toxicity:       p.Toxicity,
+		}
+		if err := addOutboxEvent(ctx, tx, updatedEvent); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("db.outbox.addOutboxEvent in %s failed for %v", tag, err)
 		}
 	}
 	if err := tx.Commit(); err != nil {
@@ -223,28 +432,105 @@ func (d *Database) UpdatePlant(ctx context.Context, id string, p plant.Plant) (*
 	return &p, nil
 }
 
-func (d *Database) AddPlantImageWithId(ctx context.Context, plantId string, uri string) (string, error) {
+// AddPlantImageWithId - appends the image, or returns a BadRequestError if the
+// plant already has maxImages
+func (d *Database) AddPlantImageWithId(ctx context.Context, plantId string, uri string, maxImages int) (string, error) {
 	tag := "db.plant.AddImageToPlant"
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return uri, fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	if err := lockPlantImages(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return uri, fmt.Errorf("db.plant_images.lockPlantImages in %s failed for %v", tag, err)
+	}
 	query := `INSERT INTO plant_images(
 				image,
-				plant_id) 
-				VALUES ($1, $2)`
-	if _, err := d.Client.ExecContext(ctx, query, uri, plantId); err != nil {
-		return uri, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+				plant_id,
+				position)
+				SELECT $1::text, $2::uuid, COALESCE(MAX(position) + 1, 0)
+				FROM plant_images
+				WHERE 1=1
+				AND plant_id = $2
+				AND deletion_date > CURRENT_TIMESTAMP`
+	result, err := tx.ExecContext(ctx, query, uri, plantId)
+	if err != nil {
+		tx.Rollback()
+		return uri, fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if err := addImageEvent(ctx, tx, result, events.ImageAdded{PlantId: plantId, Image: uri}); err != nil {
+		tx.Rollback()
+		return uri, fmt.Errorf("db.plant.addImageEvent in %s failed for %v", tag, err)
+	}
+	if err := checkPlantImageLimit(ctx, tx, plantId, maxImages); err != nil {
+		tx.Rollback()
+		return uri, err
+	}
+	if err := normalizePlantImagePositions(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return uri, fmt.Errorf("db.plant_images.normalizePlantImagePositions in %s failed for %v", tag, err)
+	}
+	if err := setPlantImageCaptureTimes(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return uri, fmt.Errorf("db.image_variants.setPlantImageCaptureTimes in %s failed for %v", tag, err)
+	}
+	if err := touchPlant(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return uri, fmt.Errorf("db.plant_images.touchPlant in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return uri, fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
 	}
 	return uri, nil
 }
 
+// DeletePlantImage - deleting an image the plant no longer has is not an error
+// and is not an event
 func (d *Database) DeletePlantImage(ctx context.Context, plantId string, uri string) error {
 	tag := "db.plant.DeletePlantImage"
 	log.Infof("deleting image: %s in %s", uri, tag)
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
 	query := `UPDATE plant_images
 				SET deletion_date = current_timestamp
        			WHERE 1=1
        			AND image = $1
-       			AND plant_id = $2`
-	if _, err := d.Client.ExecContext(ctx, query, uri, plantId); err != nil {
-		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+       			AND plant_id = $2
+       			AND deletion_date > CURRENT_TIMESTAMP`
+	result, err := tx.ExecContext(ctx, query, uri, plantId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if err := addImageEvent(ctx, tx, result, events.ImageRemoved{PlantId: plantId, Image: uri}); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant.addImageEvent in %s failed for %v", tag, err)
+	}
+	if err := normalizePlantImagePositions(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant_images.normalizePlantImagePositions in %s failed for %v", tag, err)
+	}
+	if err := touchPlant(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant_images.touchPlant in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
 	}
 	return nil
 }
+
+// addImageEvent - only when the write added or removed an image, adding an
+// image the plant already has or removing one it does not is not an event
+func addImageEvent(ctx context.Context, tx *sqlx.Tx, result sql.Result, payload events.Payload) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected in db.plant.addImageEvent failed for %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+	return addOutboxEvent(ctx, tx, payload)
+}
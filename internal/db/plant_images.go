@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+)
+
+// normalizePlantImagePositions - renumbers a plant's live images from 0 keeping
+// their order, and keeps is_primary_image in step with position 0 for the
+// queries that only need the primary image
+func normalizePlantImagePositions(ctx context.Context, execer sqlx.ExecerContext, plantId string) error {
+	query := `UPDATE plant_images
+				SET position = ordered.position,
+					is_primary_image = ordered.position = 0
+				FROM (SELECT id, row_number() OVER (ORDER BY position, id) - 1 AS position
+					FROM plant_images
+					WHERE 1=1
+					AND plant_id = $1
+					AND deletion_date > CURRENT_TIMESTAMP) ordered
+				WHERE plant_images.id = ordered.id`
+	if _, err := execer.ExecContext(ctx, query, plantId); err != nil {
+		return fmt.Errorf("ExecContext in db.plant_images.normalizePlantImagePositions failed for %v", err)
+	}
+	return nil
+}
+
+// lockPlantImages - locks the plant row so writes to the same plant's images
+// run one at a time until the transaction ends. Locking the image rows would not
+// stop a concurrent insert
+func lockPlantImages(ctx context.Context, tx *sqlx.Tx, plantId string) error {
+	query := `SELECT id
+				FROM plant
+				WHERE id = $1
+				FOR UPDATE`
+	if _, err := tx.ExecContext(ctx, query, plantId); err != nil {
+		return fmt.Errorf("ExecContext in db.plant_images.lockPlantImages failed for %v", err)
+	}
+	return nil
+}
+
+// touchPlant - the plant's images are part of its version, so writes to them
+// move its last_update_date on as well
+func touchPlant(ctx context.Context, tx *sqlx.Tx, plantId string) error {
+	query := `UPDATE plant
+				SET last_update_date = current_timestamp
+				WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, plantId); err != nil {
+		return fmt.Errorf("ExecContext in db.plant_images.touchPlant failed for %v", err)
+	}
+	return nil
+}
+
+// checkPlantImageLimit - run after the writes, with the plant locked, returns a
+// BadRequestError when the plant now has more than maxImages live images
+func checkPlantImageLimit(ctx context.Context, tx *sqlx.Tx, plantId string, maxImages int) error {
+	query := `SELECT count(*)
+				FROM plant_images
+				WHERE 1=1
+				AND plant_id = $1
+				AND deletion_date > CURRENT_TIMESTAMP`
+	var count int
+	if err := tx.GetContext(ctx, &count, query, plantId); err != nil {
+		return fmt.Errorf("GetContext in db.plant_images.checkPlantImageLimit failed for %v", err)
+	}
+	if count > maxImages {
+		return errs.BadRequestError{Message: fmt.Sprintf("a plant may have at most %d images", maxImages)}
+	}
+	return nil
+}
+
+// ReorderPlantImages - images must be exactly the plant's live images, the
+// first becomes the primary image
+func (d *Database) ReorderPlantImages(ctx context.Context, plantId string, images []string) error {
+	tag := "db.plant_images.ReorderPlantImages"
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	var current []string
+	lockQuery := `SELECT image
+				FROM plant_images
+				WHERE 1=1
+				AND plant_id = $1
+				AND deletion_date > CURRENT_TIMESTAMP
+				FOR UPDATE`
+	if err := tx.SelectContext(ctx, &current, lockQuery, plantId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlx.tx.SelectContext in %s failed for %v", tag, err)
+	}
+	if !sameImages(current, images) {
+		tx.Rollback()
+		return errs.BadRequestError{Message: "images must list every image of the plant exactly once"}
+	}
+	updateQuery := `UPDATE plant_images
+				SET position = $3
+				WHERE 1=1
+				AND plant_id = $1
+				AND image = $2
+				AND deletion_date > CURRENT_TIMESTAMP`
+	for position, image := range images {
+		if _, err := tx.ExecContext(ctx, updateQuery, plantId, image, position); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+		}
+	}
+	if err := normalizePlantImagePositions(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant_images.normalizePlantImagePositions in %s failed for %v", tag, err)
+	}
+	if err := touchPlant(ctx, tx, plantId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("db.plant_images.touchPlant in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func sameImages(current []string, requested []string) bool {
+	if len(current) != len(requested) {
+		return false
+	}
+	remaining := map[string]int{}
+	for _, image := range current {
+		remaining[image]++
+	}
+	for _, image := range requested {
+		if remaining[image] == 0 {
+			return false
+		}
+		remaining[image]--
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"testing"
+)
+
+func TestPlantImagesDatabase(t *testing.T) {
+	t.Run("test images are returned in order and can be reordered", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		prefix := "https://plant-images.test/" + uuid.NewV4().String()
+		first, second, third, fourth := prefix+"-1.jpg", prefix+"-2.jpg", prefix+"-3.jpg", prefix+"-4.jpg"
+		p, err := db.AddPlant(context.Background(), testPlant, []string{first, second, third})
+		assert.NoError(t, err)
+
+		_, err = db.AddPlantImageWithId(context.Background(), p.PlantId, fourth, 4)
+		assert.NoError(t, err)
+		stored, err := db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{first, second, third, fourth}, stored.Images)
+
+		err = db.ReorderPlantImages(context.Background(), p.PlantId, []string{third, first, fourth, second})
+		assert.NoError(t, err)
+		stored, err = db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{third, first, fourth, second}, stored.Images)
+
+		//Deleting the primary image promotes the next one
+		assert.NoError(t, db.DeletePlantImage(context.Background(), p.PlantId, third))
+		stored, err = db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{first, fourth, second}, stored.Images)
+		var primary string
+		err = db.Client.GetContext(context.Background(), &primary, `SELECT image FROM plant_images
+			WHERE plant_id = $1 AND is_primary_image AND deletion_date > CURRENT_TIMESTAMP`, p.PlantId)
+		assert.NoError(t, err)
+		assert.Equal(t, first, primary)
+	})
+
+	t.Run("test image writes change the plant's version", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		prefix := "https://plant-images.test/" + uuid.NewV4().String()
+		first, second := prefix+"-1.jpg", prefix+"-2.jpg"
+		p, err := db.AddPlant(context.Background(), testPlant, []string{first})
+		assert.NoError(t, err)
+		stored, err := db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		version := stored.UpdatedAt
+
+		_, err = db.AddPlantImageWithId(context.Background(), p.PlantId, second, 3)
+		assert.NoError(t, err)
+		stored, err = db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.True(t, stored.UpdatedAt.After(version))
+		version = stored.UpdatedAt
+
+		//Setting the primary image is a reorder
+		err = db.ReorderPlantImages(context.Background(), p.PlantId, []string{second, first})
+		assert.NoError(t, err)
+		stored, err = db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.True(t, stored.UpdatedAt.After(version))
+		version = stored.UpdatedAt
+
+		assert.NoError(t, db.DeletePlantImage(context.Background(), p.PlantId, first))
+		stored, err = db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.True(t, stored.UpdatedAt.After(version))
+	})
+
+	t.Run("test reordering must list every image once", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		prefix := "https://plant-images.test/" + uuid.NewV4().String()
+		first, second := prefix+"-1.jpg", prefix+"-2.jpg"
+		p, err := db.AddPlant(context.Background(), testPlant, []string{first, second})
+		assert.NoError(t, err)
+
+		for _, images := range [][]string{{first}, {first, first}, {first, prefix + "-other.jpg"}} {
+			err = db.ReorderPlantImages(context.Background(), p.PlantId, images)
+			assert.ErrorAs(t, err, &nectar_errors.BadRequestError{})
+		}
+	})
+
+	t.Run("test the image limit counts images deleted in the same update", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		prefix := "https://plant-images.test/" + uuid.NewV4().String()
+		first, second, third := prefix+"-1.jpg", prefix+"-2.jpg", prefix+"-3.jpg"
+		p, err := db.AddPlant(context.Background(), testPlant, []string{first, second})
+		assert.NoError(t, err)
+
+		_, err = db.AddPlantImageWithId(context.Background(), p.PlantId, third, 2)
+		assert.ErrorAs(t, err, &nectar_errors.BadRequestError{})
+
+		ctx := context.WithValue(context.Background(), "userId", testPlant.UserId)
+		update := testPlant
+		update.PlantId = p.PlantId
+		update.Images = []string{third}
+		_, err = db.UpdatePlant(ctx, p.PlantId, update, nil, 2)
+		assert.ErrorAs(t, err, &nectar_errors.BadRequestError{})
+		_, err = db.UpdatePlant(ctx, p.PlantId, update, []string{first}, 2)
+		assert.NoError(t, err)
+		stored, err := db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{second, third}, stored.Images)
+	})
+}
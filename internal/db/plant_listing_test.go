@@ -0,0 +1,136 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+	"testing"
+)
+
+// seedPlants - a user with the given number of plants of three images each,
+// deleted again when the test or benchmark finishes
+func seedPlants(t testing.TB, db *Database, count int) string {
+	userId := addTestUser(t, db)
+	t.Cleanup(func() {
+		deleteSeededPlants(t, db, userId)
+	})
+	for i := 0; i < count; i++ {
+		prefix := "https://listing.test/" + uuid.NewV4().String()
+		_, err := db.AddPlant(context.Background(), plant.Plant{
+			CommonName: fmt.Sprintf("plant %d", i),
+			Toxicity:   "not toxic",
+			UserId:     userId,
+		}, []string{prefix + "-0.jpg", prefix + "-1.jpg", prefix + "-2.jpg"})
+		assert.NoError(t, err)
+	}
+	return userId
+}
+
+// deleteSeededPlants - removes the user and every row their plants wrote, so
+// runs do not grow the shared database the other tests measure
+func deleteSeededPlants(t testing.TB, db *Database, userId string) {
+	ctx := context.Background()
+	plantIds := `SELECT id FROM plant WHERE user_id = $1`
+	_, err := db.Client.ExecContext(ctx, `DELETE FROM outbox WHERE message_key IN (SELECT id::text FROM plant WHERE user_id = $1) OR message_key = $1::text`, userId)
+	assert.NoError(t, err)
+	_, err = db.Client.ExecContext(ctx, `DELETE FROM plant_search_terms WHERE plant_id IN (`+plantIds+`)`, userId)
+	assert.NoError(t, err)
+	_, err = db.Client.ExecContext(ctx, `DELETE FROM plant_images WHERE plant_id IN (`+plantIds+`)`, userId)
+	assert.NoError(t, err)
+	_, err = db.Client.ExecContext(ctx, `DELETE FROM plant WHERE user_id = $1`, userId)
+	assert.NoError(t, err)
+	_, err = db.Client.ExecContext(ctx, `DELETE FROM nectar_users WHERE id = $1`, userId)
+	assert.NoError(t, err)
+}
+
+// listAllPlants - every plant of the user, a page at a time
+func listAllPlants(t testing.TB, db *Database, userId string) []plant.Plant {
+	plants := []plant.Plant{}
+	page := pagination.Params{Limit: pagination.MaxLimit}
+	for {
+		result, err := db.GetPlantsByUserId(context.Background(), userId, page)
+		assert.NoError(t, err)
+		plants = append(plants, result.Items...)
+		if result.NextCursor == "" {
+			return plants
+		}
+		page, err = pagination.NewParams(pagination.MaxLimit, result.NextCursor)
+		assert.NoError(t, err)
+	}
+}
+
+func TestPlantListingDatabase(t *testing.T) {
+	t.Run("test listed plants carry their own live images in order", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+		userId := addTestUser(t, db)
+		prefix := "https://listing.test/" + uuid.NewV4().String()
+		withImages, err := db.AddPlant(context.Background(), plant.Plant{CommonName: "withImages", UserId: userId},
+			[]string{prefix + "-a.jpg", prefix + "-b.jpg", prefix + "-c.jpg"})
+		assert.NoError(t, err)
+		assert.NoError(t, db.DeletePlantImage(context.Background(), withImages.PlantId, prefix+"-a.jpg"))
+		withoutImages, err := db.AddPlant(context.Background(), plant.Plant{CommonName: "withoutImages", UserId: userId}, []string{})
+		assert.NoError(t, err)
+
+		plants := listAllPlants(t, db, userId)
+		assert.Len(t, plants, 2)
+		byId := map[string]plant.Plant{}
+		for _, p := range plants {
+			byId[p.PlantId] = p
+		}
+		assert.Equal(t, []string{prefix + "-b.jpg", prefix + "-c.jpg"}, byId[withImages.PlantId].Images)
+		assert.Len(t, byId[withImages.PlantId].ImageUrls, 2)
+		assert.Equal(t, []string{}, byId[withoutImages.PlantId].Images)
+
+		//Care logged for a plant without images is still listed
+		for _, p := range []*plant.Plant{withImages, withoutImages} {
+			_, err := db.AddCareLogEntry(context.Background(), care.LogEntry{PlantId: p.PlantId, WasWatered: true, CareDate: "2023-01-01"})
+			assert.NoError(t, err)
+		}
+		entries, err := db.GetAllUsersCareLogEntries(context.Background(), userId, firstPage)
+		assert.NoError(t, err)
+		assert.Len(t, entries.Items, 2)
+		images := map[string]string{}
+		for _, entry := range entries.Items {
+			images[entry.PlantId] = entry.PlantImage
+		}
+		assert.Equal(t, prefix+"-b.jpg", images[withImages.PlantId])
+		assert.Equal(t, "", images[withoutImages.PlantId])
+	})
+}
+
+// BenchmarkListPlants - listing every plant of a user, with the images loaded
+// per page against the per plant loading it replaced
+func BenchmarkListPlants(b *testing.B) {
+	db, err := NewDatabase()
+	assert.NoError(b, err)
+	for _, count := range []int{10, 100, 500} {
+		userId := seedPlants(b, db, count)
+		plants := listAllPlants(b, db, userId)
+
+		b.Run(fmt.Sprintf("plants=%d/batched", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				listAllPlants(b, db, userId)
+			}
+		})
+		b.Run(fmt.Sprintf("plants=%d/images_batched", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				assert.NoError(b, db.attachPlantImages(context.Background(), plants))
+			}
+		})
+		b.Run(fmt.Sprintf("plants=%d/images_per_plant", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, p := range plants {
+					_, _, err := db.getPlantImages(context.Background(), p.PlantId)
+					assert.NoError(b, err)
+				}
+			}
+		})
+	}
+}
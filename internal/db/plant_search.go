@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+)
+
+type plantSearchRow struct {
+	PlantRow
+	Rank float32 `db:"rank"`
+}
+
+type searchTermRow struct {
+	PlantId string `db:"plant_id"`
+	Term    string `db:"term"`
+}
+
+// setPlantSearchTerms - replaces the plant's search terms and rebuilds its search
+// vector. Names weigh more than terms so exact name matches rank first
+func setPlantSearchTerms(ctx context.Context, tx *sqlx.Tx, plantId string, terms []string) error {
+	tag := "db.plant_search.setPlantSearchTerms"
+	if _, err := tx.ExecContext(ctx, "DELETE FROM plant_search_terms WHERE plant_id = $1", plantId); err != nil {
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if len(terms) > 0 {
+		rows := []searchTermRow{}
+		for _, term := range terms {
+			rows = append(rows, searchTermRow{PlantId: plantId, Term: term})
+		}
+		insertTermsQuery := `INSERT INTO plant_search_terms (plant_id, term) VALUES (:plant_id, :term) ON CONFLICT DO NOTHING`
+		if _, err := tx.NamedExecContext(ctx, insertTermsQuery, rows); err != nil {
+			return fmt.Errorf("sqlx.tx.NamedExecContext in %s failed for %v", tag, err)
+		}
+	}
+	updateVectorQuery := `UPDATE plant SET search_vector =
+							setweight(to_tsvector('english', coalesce(common_name, '')), 'A') ||
+							setweight(to_tsvector('english', coalesce(scientific_name, '')), 'B') ||
+							setweight(to_tsvector('english', coalesce((
+								SELECT string_agg(term, ' ') FROM plant_search_terms WHERE plant_search_terms.plant_id = plant.id
+							), '')), 'C')
+							WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, updateVectorQuery, plantId); err != nil {
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) SearchPlants(ctx context.Context, query plant.SearchQuery) ([]plant.SearchResult, error) {
+	tag := "db.plant_search.SearchPlants"
+	searchQuery := `SELECT
+						plant.id,
+						plant.user_id,
+						plant.common_name,
+						plant.scientific_name,
+						plant.toxicity,
+						plant.created_at,
+						plant.last_update_date,
+						nectar_users.username AS user_name,
+						nectar_users.profile_image,
+						ts_rank(plant.search_vector, q) AS rank
+					FROM plant
+					JOIN nectar_users ON plant.user_id = nectar_users.id,
+					websearch_to_tsquery('english', $1) q
+					WHERE 1 = 1
+					AND plant.search_vector @@ q
+					AND plant.deletion_date > CURRENT_TIMESTAMP
+					AND nectar_users.account_deletion_date > CURRENT_TIMESTAMP
+					AND ($2::uuid IS NULL OR plant.user_id = $2::uuid)
+					AND ($3::text IS NULL OR lower(plant.toxicity) = lower($3::text))
+					ORDER BY rank DESC, plant.created_at DESC, plant.id DESC
+					LIMIT $4`
+	ownerId := sql.NullString{String: query.OwnerId, Valid: query.OwnerId != ""}
+	toxicity := sql.NullString{String: query.Toxicity, Valid: query.Toxicity != ""}
+	var rows []plantSearchRow
+	if err := d.Client.SelectContext(ctx, &rows, searchQuery, query.Text, ownerId, toxicity, query.Limit); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	plants := make([]plant.Plant, len(rows))
+	for i, row := range rows {
+		plants[i] = *convertPlantRowToPlant(row.PlantRow)
+	}
+	if err := d.attachPlantImages(ctx, plants); err != nil {
+		return nil, fmt.Errorf("db.plant.attachPlantImages in %s failed for %v", tag, err)
+	}
+	results := make([]plant.SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = plant.SearchResult{Plant: plants[i], Rank: row.Rank}
+	}
+	return results, nil
+}
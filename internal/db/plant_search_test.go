@@ -0,0 +1,60 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+	"testing"
+)
+
+func TestPlantSearchDatabase(t *testing.T) {
+	t.Run("test search matches names and search terms with filters", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		owner, other := addTestUser(t, db), addTestUser(t, db)
+		monstera := plant.Plant{
+			CommonName:     "Swiss Cheese Plant",
+			ScientificName: "Monstera deliciosa",
+			Toxicity:       "toxic",
+			UserId:         owner,
+			SearchTerms:    []string{"philodendron"},
+		}
+		monstera.SearchTerms = plant.GenerateSearchTerms(monstera)
+		inserted, err := db.AddPlant(context.Background(), monstera, []string{})
+		assert.NoError(t, err)
+
+		otherMonstera := monstera
+		otherMonstera.UserId = other
+		otherMonstera.Toxicity = "not toxic"
+		_, err = db.AddPlant(context.Background(), otherMonstera, []string{})
+		assert.NoError(t, err)
+
+		//A user supplied term should match
+		results, err := db.SearchPlants(context.Background(), plant.SearchQuery{Text: "philodendron", OwnerId: owner, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, inserted.PlantId, results[0].PlantId)
+		assert.Greater(t, results[0].Rank, float32(0))
+
+		//The toxicity filter should drop the other user's plant
+		results, err = db.SearchPlants(context.Background(), plant.SearchQuery{Text: "monstera", Toxicity: "Toxic", Limit: 100})
+		assert.NoError(t, err)
+		for _, result := range results {
+			assert.Equal(t, "toxic", result.Toxicity)
+		}
+
+		//Updating the names should replace the old terms
+		monstera.CommonName = "Split Leaf"
+		monstera.SearchTerms = plant.GenerateSearchTerms(plant.Plant{CommonName: monstera.CommonName, ScientificName: monstera.ScientificName})
+		ctx := context.WithValue(context.Background(), "userId", owner)
+		monstera.PlantId = inserted.PlantId
+		_, err = db.UpdatePlant(ctx, inserted.PlantId, monstera, nil, 3)
+		assert.NoError(t, err)
+		results, err = db.SearchPlants(context.Background(), plant.SearchQuery{Text: "philodendron", OwnerId: owner, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(results))
+	})
+}
@@ -77,7 +77,7 @@ func TestPlantDatabase(t *testing.T) {
 			UserId:         userId,
 			ScientificName: "scientificName",
 			Toxicity:       "very toxic to pets",
-		})
+		}, nil, 3)
 		assert.NoError(t, err)
 
 		assert.Equal(t, newName, updatedPlant.CommonName)
@@ -119,9 +119,9 @@ func TestPlantDatabase(t *testing.T) {
 		np.UserId = differentUserID
 		notMyPlant, err := db.AddPlant(context.Background(), np, images)
 
-		userPlants, err := db.GetPlantsByUserId(context.Background(), userID)
+		userPlants, err := db.GetPlantsByUserId(context.Background(), userID, firstPage)
 		assert.NoError(t, err)
-		assert.Equal(t, numPlants, len(userPlants))
+		assert.Equal(t, numPlants, len(userPlants.Items))
 
 		// Assert that the array only contains plants that
 		// belong to the user
@@ -138,10 +138,10 @@ func TestPlantDatabase(t *testing.T) {
 		assert.NoError(t, err)
 
 		otherId := uuid.NewV4().String()
-		plantList, err := db.GetPlantsByUserId(context.Background(), otherId)
+		plantList, err := db.GetPlantsByUserId(context.Background(), otherId, firstPage)
 		assert.NoError(t, err)
 		// User has no plants, should return an empty slice
-		assert.Equal(t, 0, len(plantList))
+		assert.Equal(t, 0, len(plantList.Items))
 	})
 
 }
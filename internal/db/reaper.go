@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/reaper"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
+	"time"
+)
+
+type ReapableImageRow struct {
+	Image       string       `db:"image"`
+	Variants    []byte       `db:"variants"`
+	CapturedAt  sql.NullTime `db:"captured_at"`
+	WasAttached bool         `db:"was_attached"`
+}
+
+// GetReapableImages - candidates are every processed image plus every image a
+// plant has ever had, as images added by uri have no image_variants row.
+// Profile images replaced before image_variants existed are not tracked
+func (d *Database) GetReapableImages(ctx context.Context, retentionCutoff time.Time, graceCutoff time.Time, limit int) ([]reaper.Image, error) {
+	tag := "db.reaper.GetReapableImages"
+	query := `SELECT candidates.image,
+					image_variants.variants,
+					image_variants.captured_at,
+					EXISTS (SELECT 1 FROM plant_images WHERE plant_images.image = candidates.image) AS was_attached
+				FROM (SELECT image FROM image_variants
+					UNION
+					SELECT image FROM plant_images) candidates
+				LEFT JOIN image_variants ON image_variants.image = candidates.image
+				WHERE 1=1
+				AND (image_variants.created_at IS NULL OR image_variants.created_at < $2)
+				AND NOT EXISTS (SELECT 1
+					FROM plant_images
+					JOIN plant ON plant.id = plant_images.plant_id
+					WHERE plant_images.image = candidates.image
+					AND plant_images.deletion_date > $1
+					AND plant.deletion_date > $1)
+				AND NOT EXISTS (SELECT 1
+					FROM nectar_users
+					WHERE nectar_users.profile_image = candidates.image
+					AND nectar_users.account_deletion_date > $1)
+				ORDER BY candidates.image
+				LIMIT $3`
+	var rows []ReapableImageRow
+	if err := d.Client.SelectContext(ctx, &rows, query, retentionCutoff, graceCutoff, limit); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	images := make([]reaper.Image, 0, len(rows))
+	for _, row := range rows {
+		urls, err := convertImageVariants(row.Image, row.Variants, row.CapturedAt)
+		if err != nil {
+			return nil, fmt.Errorf("db.image_variants.convertImageVariants in %s failed for %v", tag, err)
+		}
+		reason := reaper.ReasonUnreferenced
+		if row.WasAttached {
+			reason = reaper.ReasonSoftDeleted
+		}
+		images = append(images, reaper.Image{Url: row.Image, Reason: reason, Urls: urls})
+	}
+	return images, nil
+}
+
+// DeleteImageRecords - hard deletes the soft deleted plant_images rows and the
+// variants of an image whose blobs have been removed
+func (d *Database) DeleteImageRecords(ctx context.Context, image string) error {
+	tag := "db.reaper.DeleteImageRecords"
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM plant_images WHERE image = $1`, image); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM image_variants WHERE image = $1`, image); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlx.tx.ExecContext in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) GetExpiredPendingUploads(ctx context.Context, cutoff time.Time, limit int) ([]upload.Pending, error) {
+	tag := "db.reaper.GetExpiredPendingUploads"
+	query := `SELECT id, user_id, object_key, content_type, target, target_id, created_at, expires_at, finalized_at
+				FROM pending_uploads
+				WHERE 1=1
+				AND finalized_at IS NULL
+				AND expires_at < $1
+				ORDER BY expires_at
+				LIMIT $2`
+	var rows []PendingUploadRow
+	if err := d.Client.SelectContext(ctx, &rows, query, cutoff, limit); err != nil {
+		return nil, fmt.Errorf("sqlx.SelectContext in %s failed for %v", tag, err)
+	}
+	return convertList(rows, convertPendingUploadRow), nil
+}
+
+func (d *Database) DeletePendingUpload(ctx context.Context, id string) error {
+	tag := "db.reaper.DeletePendingUpload"
+	query := `DELETE FROM pending_uploads
+				WHERE id = $1`
+	if _, err := d.Client.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/reaper"
+	"testing"
+	"time"
+)
+
+func reapableReasons(t *testing.T, db *Database) map[string]string {
+	// Cutoffs in the future so images created by this test are already past them
+	future := time.Now().Add(time.Minute)
+	found, err := db.GetReapableImages(context.Background(), future, future, 100000)
+	assert.NoError(t, err)
+	reasons := map[string]string{}
+	for _, image := range found {
+		reasons[image.Url] = image.Reason
+	}
+	return reasons
+}
+
+func TestReaperDatabase(t *testing.T) {
+	t.Run("test unreferenced and soft deleted images are reapable", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		prefix := "https://reaper.test/" + uuid.NewV4().String()
+		unreferenced, live, deleted := prefix+"-unreferenced.jpg", prefix+"-live.jpg", prefix+"-deleted.jpg"
+		for _, image := range []string{unreferenced, live, deleted} {
+			assert.NoError(t, db.AddImageVariants(context.Background(), imaging.LegacyImageUrls(image)))
+		}
+		p, err := db.AddPlant(context.Background(), testPlant, []string{live, deleted})
+		assert.NoError(t, err)
+		assert.NoError(t, db.DeletePlantImage(context.Background(), p.PlantId, deleted))
+
+		reasons := reapableReasons(t, db)
+		assert.Equal(t, reaper.ReasonUnreferenced, reasons[unreferenced])
+		assert.Equal(t, reaper.ReasonSoftDeleted, reasons[deleted])
+		assert.NotContains(t, reasons, live)
+
+		//Nothing is reapable before the retention and grace periods have passed
+		past := time.Now().Add(-time.Minute)
+		found, err := db.GetReapableImages(context.Background(), past, past, 100000)
+		assert.NoError(t, err)
+		for _, image := range found {
+			assert.NotContains(t, []string{unreferenced, live, deleted}, image.Url)
+		}
+
+		assert.NoError(t, db.DeleteImageRecords(context.Background(), deleted))
+		reasons = reapableReasons(t, db)
+		assert.NotContains(t, reasons, deleted)
+		plantAfter, err := db.GetPlant(context.Background(), p.PlantId)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{live}, plantAfter.Images)
+	})
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ClaimCareReminder - reports whether this call recorded the reminder, so of
+// several workers finding the same overdue care only one sends it
+func (d *Database) ClaimCareReminder(ctx context.Context, plantId string, careType string, dueDate time.Time) (bool, error) {
+	tag := "db.reminder.ClaimCareReminder"
+	query := `INSERT INTO care_reminder (
+					plant_id,
+					care_type,
+					due_date)
+				VALUES ($1, $2, $3)
+				ON CONFLICT DO NOTHING`
+	result, err := d.Client.ExecContext(ctx, query, plantId, careType, dueDate)
+	if err != nil {
+		return false, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// ReleaseCareReminder - undoes a claim whose reminder could not be sent, so a
+// later scan claims it again
+func (d *Database) ReleaseCareReminder(ctx context.Context, plantId string, careType string, dueDate time.Time) error {
+	tag := "db.reminder.ReleaseCareReminder"
+	query := `DELETE FROM care_reminder
+				WHERE 1 = 1
+				AND plant_id = $1
+				AND care_type = $2
+				AND due_date = $3`
+	if _, err := d.Client.ExecContext(ctx, query, plantId, careType, dueDate); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCareReminderDatabase(t *testing.T) {
+	t.Run("test a care reminder is only claimed once", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		plantId := uuid.NewV4().String()
+		dueDate := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+		claimed, err := db.ClaimCareReminder(context.Background(), plantId, "watering", dueDate)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		//A second worker finding the same overdue care does not send it again
+		claimed, err = db.ClaimCareReminder(context.Background(), plantId, "watering", dueDate)
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+
+		//A new due date for the same plant has not been sent yet
+		claimed, err = db.ClaimCareReminder(context.Background(), plantId, "watering", dueDate.AddDate(0, 0, 7))
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+
+		//A released reminder can be claimed again
+		assert.NoError(t, db.ReleaseCareReminder(context.Background(), plantId, "watering", dueDate))
+		claimed, err = db.ClaimCareReminder(context.Background(), plantId, "watering", dueDate)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+	})
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
+	"time"
+)
+
+type PendingUploadRow struct {
+	Id          string       `db:"id"`
+	UserId      string       `db:"user_id"`
+	ObjectKey   string       `db:"object_key"`
+	ContentType string       `db:"content_type"`
+	Target      string       `db:"target"`
+	TargetId    string       `db:"target_id"`
+	CreatedAt   time.Time    `db:"created_at"`
+	ExpiresAt   time.Time    `db:"expires_at"`
+	FinalizedAt sql.NullTime `db:"finalized_at"`
+}
+
+func convertPendingUploadRow(row PendingUploadRow) upload.Pending {
+	pending := upload.Pending{
+		Id:          row.Id,
+		UserId:      row.UserId,
+		ObjectKey:   row.ObjectKey,
+		ContentType: row.ContentType,
+		Target:      row.Target,
+		TargetId:    row.TargetId,
+		CreatedAt:   row.CreatedAt,
+		ExpiresAt:   row.ExpiresAt,
+	}
+	if row.FinalizedAt.Valid {
+		pending.FinalizedAt = &row.FinalizedAt.Time
+	}
+	return pending
+}
+
+func (d *Database) AddPendingUpload(ctx context.Context, pending upload.Pending) error {
+	tag := "db.upload.AddPendingUpload"
+	query := `INSERT INTO pending_uploads (
+					id,
+					user_id,
+					object_key,
+					content_type,
+					target,
+					target_id,
+					expires_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := d.Client.ExecContext(ctx, query, pending.Id, pending.UserId, pending.ObjectKey, pending.ContentType, pending.Target, pending.TargetId, pending.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
+
+func (d *Database) GetPendingUpload(ctx context.Context, id string) (*upload.Pending, error) {
+	tag := "db.upload.GetPendingUpload"
+	query := `SELECT id, user_id, object_key, content_type, target, target_id, created_at, expires_at, finalized_at
+				FROM pending_uploads
+				WHERE id = $1`
+	var row PendingUploadRow
+	if err := d.Client.GetContext(ctx, &row, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &errs.NoEntityError{Message: fmt.Sprintf("no upload with id: %s", id)}
+		}
+		return nil, fmt.Errorf("sqlx.GetContext in %s failed for %v", tag, err)
+	}
+	pending := convertPendingUploadRow(row)
+	return &pending, nil
+}
+
+// ClaimPendingUpload - marks the upload finalized, returning false when it
+// already was or has expired so concurrent finalize requests process it once
+func (d *Database) ClaimPendingUpload(ctx context.Context, id string) (bool, error) {
+	tag := "db.upload.ClaimPendingUpload"
+	query := `UPDATE pending_uploads
+				SET finalized_at = current_timestamp
+				WHERE 1 = 1
+				AND id = $1
+				AND finalized_at IS NULL
+				AND expires_at > current_timestamp`
+	result, err := d.Client.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	return count == 1, nil
+}
+
+// ReleasePendingUpload - undoes a claim after processing failed
+func (d *Database) ReleasePendingUpload(ctx context.Context, id string) error {
+	tag := "db.upload.ReleasePendingUpload"
+	query := `UPDATE pending_uploads
+				SET finalized_at = NULL
+				WHERE id = $1`
+	if _, err := d.Client.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
+	"testing"
+	"time"
+)
+
+func TestPendingUploadDatabase(t *testing.T) {
+	t.Run("test claim and release a pending upload", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := addTestUser(t, db)
+		id := uuid.NewV4().String()
+		err = db.AddPendingUpload(context.Background(), upload.Pending{
+			Id:          id,
+			UserId:      userId,
+			ObjectKey:   "upload-" + id + ".jpg",
+			ContentType: "image/jpeg",
+			Target:      upload.TargetProfile,
+			TargetId:    userId,
+			ExpiresAt:   time.Now().Add(time.Minute),
+		})
+		assert.NoError(t, err)
+
+		pending, err := db.GetPendingUpload(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, pending.UserId)
+		assert.Equal(t, upload.TargetProfile, pending.Target)
+		assert.Nil(t, pending.FinalizedAt)
+
+		claimed, err := db.ClaimPendingUpload(context.Background(), id)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		//A second finalize must not process the upload again
+		claimed, err = db.ClaimPendingUpload(context.Background(), id)
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+
+		assert.NoError(t, db.ReleasePendingUpload(context.Background(), id))
+		claimed, err = db.ClaimPendingUpload(context.Background(), id)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("test expired uploads cannot be claimed", func(t *testing.T) {
+		db, err := NewDatabase()
+		assert.NoError(t, err)
+
+		userId := addTestUser(t, db)
+		id := uuid.NewV4().String()
+		err = db.AddPendingUpload(context.Background(), upload.Pending{
+			Id:          id,
+			UserId:      userId,
+			ObjectKey:   "upload-" + id + ".jpg",
+			ContentType: "image/jpeg",
+			Target:      upload.TargetProfile,
+			TargetId:    userId,
+			ExpiresAt:   time.Now().Add(-time.Minute),
+		})
+		assert.NoError(t, err)
+		claimed, err := db.ClaimPendingUpload(context.Background(), id)
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+
+		_, err = db.GetPendingUpload(context.Background(), uuid.NewV4().String())
+		var noEntityError *nectar_errors.NoEntityError
+		assert.ErrorAs(t, err, &noEntityError)
+	})
+}
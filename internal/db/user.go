@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/jmoiron/sqlx"
 	"github.com/omeid/pgerror"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"time"
 )
 
 type UserRow struct {
@@ -16,6 +19,8 @@ type UserRow struct {
 	Email      string         `db:"email"`
 	Username   string         `db:"username"`
 	ImageUrl   sql.NullString `db:"profile_image"`
+	Role       string         `db:"role"`
+	UpdatedAt  time.Time      `db:"last_update_date"`
 }
 
 func convertUserRowToUser(u UserRow) *user.User {
@@ -26,6 +31,8 @@ func convertUserRowToUser(u UserRow) *user.User {
 		Username:   u.Username,
 		Name:       u.Name.String,
 		ImageUrl:   u.ImageUrl.String,
+		Role:       u.Role,
+		UpdatedAt:  u.UpdatedAt,
 	}
 }
 
@@ -36,7 +43,9 @@ func (d *Database) GetUserById(ctx context.Context, id string) (*user.User, erro
     				nectar_users.first_name as name, 
     				nectar_users.email,
     				nectar_users.username,
-    				nectar_users.profile_image
+    				nectar_users.profile_image,
+    				nectar_users.role,
+    				nectar_users.last_update_date
 				FROM nectar_users
 				WHERE nectar_users.id = $1`
 	var rows []UserRow
@@ -68,24 +77,43 @@ func (d *Database) AddUser(ctx context.Context, u user.User) (*user.User, error)
 		Id:       u.Id,
 		Username: u.Username,
 	}
-	rows, err := d.Client.NamedQueryContext(ctx, query, userRow)
+	tx, err := d.Client.BeginTxx(ctx, nil)
 	if err != nil {
+		return nil, fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	rows, err := sqlx.NamedQueryContext(ctx, tx, query, userRow)
+	if err != nil {
+		tx.Rollback()
 		if e := pgerror.UniqueViolation(err); e != nil {
 			return nil, nectar_errors.DuplicateKeyError{}
 		}
 		return nil, fmt.Errorf("NamedQueryContext in %s failed for %v", tag, err)
 	}
-	defer closeDbRows(rows, query)
 	var userID string
 	for rows.Next() {
 		if err := rows.Scan(&userID); err != nil {
+			closeDbRows(rows, query)
+			tx.Rollback()
 			return nil, fmt.Errorf("rows.Scan in %s failed for %v", tag, err)
 		}
 	}
+	closeDbRows(rows, query)
 	u.Id = userID
+	if err := addOutboxEvent(ctx, tx, events.UserCreated{UserId: userID, Username: u.Username, Role: u.Role}); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.outbox.addOutboxEvent in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		if e := pgerror.UniqueViolation(err); e != nil {
+			return nil, nectar_errors.DuplicateKeyError{}
+		}
+		return nil, fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
 	return &u, nil
 }
 
+// UpdateUser - a non-zero u.UpdatedAt is the last_update_date the caller read,
+// the user is then only updated if it still has it
 func (d *Database) UpdateUser(ctx context.Context, id string, u user.User) (*user.User, error) {
 	tag := "db.user.UpdateUser"
 	query := `UPDATE nectar_users
@@ -93,30 +121,58 @@ func (d *Database) UpdateUser(ctx context.Context, id string, u user.User) (*use
 						first_name = $1,
 					    username = $2,
 					    email = $3,
-					    profile_image = $4
+					    profile_image = $4,
+					    last_update_date = current_timestamp
 					WHERE nectar_users.id = $5
+					AND ($6::timestamp IS NULL OR nectar_users.last_update_date = $6::timestamp)
 					RETURNING
 						nectar_users.id, 
 						nectar_users.first_name as name, 
 						nectar_users.email,
 						nectar_users.username,
-						nectar_users.profile_image`
-	row := d.Client.QueryRowContext(ctx, query, u.Name, u.Username, u.Email, u.ImageUrl, id)
+						nectar_users.profile_image,
+						nectar_users.role,
+						nectar_users.last_update_date`
+	tx, err := d.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	row := tx.QueryRowContext(ctx, query, u.Name, u.Username, u.Email, u.ImageUrl, id, expectedUpdateDate(u.UpdatedAt))
 	var ur UserRow
-	if err := row.Scan(&ur.Id, &ur.Name, &ur.Email, &ur.Username, &ur.ImageUrl); err != nil {
+	if err := row.Scan(&ur.Id, &ur.Name, &ur.Email, &ur.Username, &ur.ImageUrl, &ur.Role, &ur.UpdatedAt); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows && !u.UpdatedAt.IsZero() {
+			return nil, nectar_errors.PreconditionFailedError{Message: "the user was changed or deleted since it was read"}
+		}
 		return nil, fmt.Errorf("rows.Scan in %s failed for %v", tag, err)
 	}
-	return convertUserRowToUser(ur), nil
+	updated := convertUserRowToUser(ur)
+	if err := addOutboxEvent(ctx, tx, events.UserUpdated{UserId: updated.Id, Username: updated.Username, ImageUrl: updated.ImageUrl}); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("db.outbox.addOutboxEvent in %s failed for %v", tag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
+	return updated, nil
 }
 
 func (d *Database) UpdateUserProfileImage(ctx context.Context, uri string, id string) (string, error) {
 	tag := "db.UpdateUserProfileImage"
 	query := `UPDATE nectar_users
-				SET profile_image = $1
+				SET profile_image = $1,
+				last_update_date = current_timestamp
 				WHERE nectar_users.id = $2`
-	_, err := d.Client.QueryContext(ctx, query, uri, id)
+	result, err := d.Client.ExecContext(ctx, query, uri, id)
+	if err != nil {
+		return "", fmt.Errorf("sqlx.ExecContext in %s failed for %v", tag, err)
+	}
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return "", fmt.Errorf("sqlx.QueryContext in %s failed for %v", tag, err)
+		return "", fmt.Errorf("result.RowsAffected in %s failed for %v", tag, err)
+	}
+	if rowsAffected == 0 {
+		return "", &nectar_errors.NoEntityError{Message: fmt.Sprintf("no user with id: %s", id)}
 	}
 	return uri, nil
 }
@@ -144,7 +200,9 @@ func (d *Database) GetUser(ctx context.Context, id string) (*user.User, error) {
     				nectar_users.first_name as name, 
     				nectar_users.email,
     				nectar_users.username,
-    				nectar_users.profile_image
+    				nectar_users.profile_image,
+    				nectar_users.role,
+    				nectar_users.last_update_date
 				FROM nectar_users
 				WHERE nectar_users.id = $1`
 	var rows []UserRow
@@ -162,10 +220,25 @@ func (d *Database) DeleteUser(ctx context.Context, id string) error {
 	tag := "db.user.DeleteUser"
 	query := `UPDATE nectar_users
 				SET account_deletion_date = current_timestamp
-				WHERE nectar_users.id = $1`
-	_, err := d.Client.ExecContext(ctx, query, id)
+				WHERE nectar_users.id = $1
+				AND nectar_users.account_deletion_date > current_timestamp`
+	tx, err := d.Client.BeginTxx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("sqlx.BeginTxx in %s failed for %v", tag, err)
+	}
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		tx.Rollback()
 		return fmt.Errorf("ExecContext in %s failed for %v", tag, err)
 	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+		if err := addOutboxEvent(ctx, tx, events.UserDeleted{UserId: id}); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("db.outbox.addOutboxEvent in %s failed for %v", tag, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlx.tx.Commit in %s failed for %v", tag, err)
+	}
 	return nil
 }
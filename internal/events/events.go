@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+const (
+	TopicPlants        = "plants"
+	TopicUsers         = "users"
+	TopicCareLogs      = "care-logs"
+	TopicCareReminders = "care-reminders"
+)
+
+// Envelope - the shape of every message on the queue. Version is the version of
+// the payload's schema, consumers decode payloads through the registry
+type Envelope struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Actor      string          `json:"actor,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Payload - the body of an event. Events with the same aggregate id are
+// published in order
+type Payload interface {
+	EventType() string
+	AggregateId() string
+}
+
+type UnknownEventError struct {
+	Type string
+}
+
+func (e UnknownEventError) Error() string {
+	return fmt.Sprintf("unknown event type: %s", e.Type)
+}
+
+type UnsupportedVersionError struct {
+	Type    string
+	Version int
+}
+
+func (e UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported version %d of event type: %s", e.Version, e.Type)
+}
+
+type MessageQueue interface {
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
+}
+
+// New - wraps the payload in an envelope at its registered version. The actor
+// is the user making the request, empty for changes made by workers
+func New(ctx context.Context, payload Payload) (Envelope, error) {
+	schema, ok := Lookup(payload.EventType())
+	if !ok {
+		return Envelope{}, UnknownEventError{Type: payload.EventType()}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("json.Marshal in events.New failed for %v", err)
+	}
+	actor, _ := ctx.Value("userId").(string)
+	return Envelope{
+		Id:         uuid.NewV4().String(),
+		Type:       schema.Type,
+		Version:    schema.Version,
+		OccurredAt: time.Now().UTC(),
+		Actor:      actor,
+		Payload:    data,
+	}, nil
+}
+
+func Encode(envelope Envelope) ([]byte, error) {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal in events.Encode failed for %v", err)
+	}
+	return data, nil
+}
+
+// Decode - returns the envelope and its payload as the registered type, e.g.
+// *PlantCreated. Payloads newer than the registered version are rejected so an
+// old consumer never silently drops fields
+func Decode(data []byte) (Envelope, Payload, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Envelope{}, nil, fmt.Errorf("json.Unmarshal in events.Decode failed for %v", err)
+	}
+	schema, ok := Lookup(envelope.Type)
+	if !ok {
+		return envelope, nil, UnknownEventError{Type: envelope.Type}
+	}
+	if envelope.Version < 1 || envelope.Version > schema.Version {
+		return envelope, nil, UnsupportedVersionError{Type: envelope.Type, Version: envelope.Version}
+	}
+	payload := schema.new()
+	if err := json.Unmarshal(envelope.Payload, payload); err != nil {
+		return envelope, nil, fmt.Errorf("json.Unmarshal of %s in events.Decode failed for %v", envelope.Type, err)
+	}
+	return envelope, payload, nil
+}
+
+// Publish - encodes the event and pushes it straight to the queue. Changes that
+// are written to the database go through the outbox instead, so they are only
+// published if the write commits
+func Publish(ctx context.Context, mq MessageQueue, payload Payload) error {
+	schema, ok := Lookup(payload.EventType())
+	if !ok {
+		return UnknownEventError{Type: payload.EventType()}
+	}
+	return PublishTo(ctx, mq, schema.Topic, payload)
+}
+
+// PublishTo - Publish to a topic other than the registered one
+func PublishTo(ctx context.Context, mq MessageQueue, topic string, payload Payload) error {
+	envelope, err := New(ctx, payload)
+	if err != nil {
+		return err
+	}
+	data, err := Encode(envelope)
+	if err != nil {
+		return err
+	}
+	if err := mq.PushToQueue(ctx, topic, payload.AggregateId(), data); err != nil {
+		return fmt.Errorf("PushToQueue in events.PublishTo failed for %v", err)
+	}
+	return nil
+}
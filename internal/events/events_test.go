@@ -0,0 +1,60 @@
+//go:build integration
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type recordingQueue struct {
+	topic   string
+	key     string
+	message []byte
+}
+
+func (q *recordingQueue) PushToQueue(ctx context.Context, topic string, key string, message []byte) error {
+	q.topic = topic
+	q.key = key
+	q.message = message
+	return nil
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("test published events decode to their payload", func(t *testing.T) {
+		queue := &recordingQueue{}
+		ctx := context.WithValue(context.Background(), "userId", "some-user")
+		err := Publish(ctx, queue, ImageAdded{PlantId: "some-plant", Image: "https://images.test/a.jpg"})
+		assert.NoError(t, err)
+		assert.Equal(t, TopicPlants, queue.topic)
+		assert.Equal(t, "some-plant", queue.key)
+
+		envelope, payload, err := Decode(queue.message)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, envelope.Id)
+		assert.Equal(t, TypeImageAdded, envelope.Type)
+		assert.Equal(t, 1, envelope.Version)
+		assert.Equal(t, "some-user", envelope.Actor)
+		assert.Equal(t, &ImageAdded{PlantId: "some-plant", Image: "https://images.test/a.jpg"}, payload)
+	})
+
+	t.Run("test unknown types and newer versions are rejected", func(t *testing.T) {
+		unknown, _ := json.Marshal(Envelope{Type: "plant.watered", Version: 1, Payload: json.RawMessage(`{}`)})
+		_, _, err := Decode(unknown)
+		assert.True(t, errors.As(err, &UnknownEventError{}))
+
+		newer, _ := json.Marshal(Envelope{Type: TypePlantDeleted, Version: 2, Payload: json.RawMessage(`{}`)})
+		_, _, err = Decode(newer)
+		assert.True(t, errors.As(err, &UnsupportedVersionError{}))
+	})
+
+	t.Run("test every payload is registered under its own type", func(t *testing.T) {
+		for _, schema := range Schemas() {
+			assert.Equal(t, schema.Type, schema.new().EventType())
+			assert.NotEmpty(t, schema.Topic)
+		}
+	})
+}
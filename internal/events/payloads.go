@@ -0,0 +1,141 @@
+package events
+
+import "time"
+
+const (
+	TypePlantCreated   = "plant.created"
+	TypePlantUpdated   = "plant.updated"
+	TypePlantDeleted   = "plant.deleted"
+	TypeImageAdded     = "plant.image_added"
+	TypeImageRemoved   = "plant.image_removed"
+	TypeCareLogged     = "care_log.created"
+	TypeCareLogUpdated = "care_log.updated"
+	TypeCareLogDeleted = "care_log.deleted"
+	TypeUserCreated    = "user.created"
+	TypeUserUpdated    = "user.updated"
+	TypeUserDeleted    = "user.deleted"
+	TypeCareReminder   = "care.reminder"
+)
+
+type PlantCreated struct {
+	PlantId        string   `json:"plantId"`
+	UserId         string   `json:"userId"`
+	CommonName     string   `json:"commonName"`
+	ScientificName string   `json:"scientificName"`
+	Toxicity       string   `json:"toxicity"`
+	Images         []string `json:"images"`
+}
+
+func (e PlantCreated) EventType() string   { return TypePlantCreated }
+func (e PlantCreated) AggregateId() string { return e.PlantId }
+
+type PlantUpdated struct {
+	PlantId        string `json:"plantId"`
+	UserId         string `json:"userId"`
+	CommonName     string `json:"commonName"`
+	ScientificName string `json:"scientificName"`
+	Toxicity       string `json:"toxicity"`
+}
+
+func (e PlantUpdated) EventType() string   { return TypePlantUpdated }
+func (e PlantUpdated) AggregateId() string { return e.PlantId }
+
+type PlantDeleted struct {
+	PlantId string `json:"plantId"`
+}
+
+func (e PlantDeleted) EventType() string   { return TypePlantDeleted }
+func (e PlantDeleted) AggregateId() string { return e.PlantId }
+
+type ImageAdded struct {
+	PlantId string `json:"plantId"`
+	Image   string `json:"image"`
+}
+
+func (e ImageAdded) EventType() string   { return TypeImageAdded }
+func (e ImageAdded) AggregateId() string { return e.PlantId }
+
+type ImageRemoved struct {
+	PlantId string `json:"plantId"`
+	Image   string `json:"image"`
+}
+
+func (e ImageRemoved) EventType() string   { return TypeImageRemoved }
+func (e ImageRemoved) AggregateId() string { return e.PlantId }
+
+// CareLogged - keyed by the plant so a plant's care history is consumed in order
+type CareLogged struct {
+	CareLogId     string `json:"careLogId"`
+	PlantId       string `json:"plantId"`
+	CareDate      string `json:"careDate"`
+	Notes         string `json:"notes"`
+	WasWatered    bool   `json:"wasWatered"`
+	WasFertilized bool   `json:"wasFertilized"`
+}
+
+func (e CareLogged) EventType() string   { return TypeCareLogged }
+func (e CareLogged) AggregateId() string { return e.PlantId }
+
+type CareLogUpdated struct {
+	CareLogId     string `json:"careLogId"`
+	PlantId       string `json:"plantId"`
+	Notes         string `json:"notes"`
+	WasWatered    bool   `json:"wasWatered"`
+	WasFertilized bool   `json:"wasFertilized"`
+}
+
+func (e CareLogUpdated) EventType() string   { return TypeCareLogUpdated }
+func (e CareLogUpdated) AggregateId() string { return e.PlantId }
+
+type CareLogDeleted struct {
+	CareLogId string `json:"careLogId"`
+	PlantId   string `json:"plantId"`
+}
+
+func (e CareLogDeleted) EventType() string   { return TypeCareLogDeleted }
+func (e CareLogDeleted) AggregateId() string { return e.PlantId }
+
+// UserCreated - carries no contact details, consumers that need them look the
+// user up
+type UserCreated struct {
+	UserId   string `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (e UserCreated) EventType() string   { return TypeUserCreated }
+func (e UserCreated) AggregateId() string { return e.UserId }
+
+type UserUpdated struct {
+	UserId   string `json:"userId"`
+	Username string `json:"username"`
+	ImageUrl string `json:"imageUrl"`
+}
+
+func (e UserUpdated) EventType() string   { return TypeUserUpdated }
+func (e UserUpdated) AggregateId() string { return e.UserId }
+
+type UserDeleted struct {
+	UserId string `json:"userId"`
+}
+
+func (e UserDeleted) EventType() string   { return TypeUserDeleted }
+func (e UserDeleted) AggregateId() string { return e.UserId }
+
+// CareReminderItem - a single overdue kind of care for a single plant
+type CareReminderItem struct {
+	PlantId   string    `json:"plantId"`
+	PlantName string    `json:"plantName"`
+	CareType  string    `json:"careType"`
+	DueDate   time.Time `json:"dueDate"`
+}
+
+// CareReminder - every overdue item of a user found by one scan, published by
+// the reminder worker rather than through the outbox
+type CareReminder struct {
+	UserId string             `json:"userId"`
+	Items  []CareReminderItem `json:"items"`
+}
+
+func (e CareReminder) EventType() string   { return TypeCareReminder }
+func (e CareReminder) AggregateId() string { return e.UserId }
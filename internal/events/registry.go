@@ -0,0 +1,52 @@
+package events
+
+import "sort"
+
+// Schema - the current version of an event type and the topic it is published
+// to. Bump Version whenever a payload changes in a way old consumers can not
+// read, adding optional fields does not need a new version
+type Schema struct {
+	Type    string
+	Version int
+	Topic   string
+	new     func() Payload
+}
+
+var registry = map[string]Schema{}
+
+func register(eventType string, version int, topic string, new func() Payload) {
+	if _, ok := registry[eventType]; ok {
+		panic("events: " + eventType + " registered twice")
+	}
+	registry[eventType] = Schema{Type: eventType, Version: version, Topic: topic, new: new}
+}
+
+func Lookup(eventType string) (Schema, bool) {
+	schema, ok := registry[eventType]
+	return schema, ok
+}
+
+// Schemas - every registered event type, sorted by type
+func Schemas() []Schema {
+	schemas := make([]Schema, 0, len(registry))
+	for _, schema := range registry {
+		schemas = append(schemas, schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Type < schemas[j].Type })
+	return schemas
+}
+
+func init() {
+	register(TypePlantCreated, 1, TopicPlants, func() Payload { return &PlantCreated{} })
+	register(TypePlantUpdated, 1, TopicPlants, func() Payload { return &PlantUpdated{} })
+	register(TypePlantDeleted, 1, TopicPlants, func() Payload { return &PlantDeleted{} })
+	register(TypeImageAdded, 1, TopicPlants, func() Payload { return &ImageAdded{} })
+	register(TypeImageRemoved, 1, TopicPlants, func() Payload { return &ImageRemoved{} })
+	register(TypeCareLogged, 1, TopicCareLogs, func() Payload { return &CareLogged{} })
+	register(TypeCareLogUpdated, 1, TopicCareLogs, func() Payload { return &CareLogUpdated{} })
+	register(TypeCareLogDeleted, 1, TopicCareLogs, func() Payload { return &CareLogDeleted{} })
+	register(TypeUserCreated, 1, TopicUsers, func() Payload { return &UserCreated{} })
+	register(TypeUserUpdated, 1, TopicUsers, func() Payload { return &UserUpdated{} })
+	register(TypeUserDeleted, 1, TopicUsers, func() Payload { return &UserDeleted{} })
+	register(TypeCareReminder, 1, TopicCareReminders, func() Payload { return &CareReminder{} })
+}
@@ -0,0 +1,49 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"time"
+)
+
+const ItemTypePlant = "plant"
+
+// Item - a single entry in a user's feed, a plant a followed user added. Care
+// logs are private to their owner, so following someone does not show them
+type Item struct {
+	Type             string    `json:"type"`
+	Id               string    `json:"id"`
+	OccurredAt       time.Time `json:"occurredAt"`
+	UserId           string    `json:"userId"`
+	Username         string    `json:"username"`
+	UserProfileImage string    `json:"userProfileImage"`
+	PlantId          string    `json:"plantId"`
+	PlantName        string    `json:"plantName"`
+	PlantImage       string    `json:"plantImage"`
+}
+
+type Store interface {
+	GetFeed(ctx context.Context, userId string, page pagination.Params) (pagination.Page[Item], error)
+}
+
+type Service struct {
+	Store Store
+}
+
+// NewService - returns a pointer to a new feed service
+func NewService(store Store) *Service {
+	return &Service{
+		Store: store,
+	}
+}
+
+// GetFeed - returns one page of the user's feed, newest first
+func (s *Service) GetFeed(ctx context.Context, userId string, page pagination.Params) (pagination.Page[Item], error) {
+	tag := "feed.GetFeed"
+	items, err := s.Store.GetFeed(ctx, userId, page)
+	if err != nil {
+		return pagination.Page[Item]{}, fmt.Errorf("Store.GetFeed in %s failed for %v", tag, err)
+	}
+	return items, nil
+}
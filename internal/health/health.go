@@ -3,6 +3,7 @@ package health
 import (
 	"context"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
 )
 
 type Store interface {
@@ -11,6 +12,7 @@ type Store interface {
 
 type Cache interface {
 	CheckCacheHealth(ctx context.Context) error
+	Stats() cache.Stats
 }
 
 type Service struct {
@@ -34,3 +36,7 @@ func (s *Service) CheckCacheHealth(ctx context.Context) error {
 	log.Info("Retrieving database health")
 	return s.Cache.CheckCacheHealth(ctx)
 }
+
+func (s *Service) CacheStats() cache.Stats {
+	return s.Cache.Stats()
+}
@@ -0,0 +1,178 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	tagOrientation        = 0x0112
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+
+	exifDateLayout = "2006:01:02 15:04:05"
+)
+
+// Metadata - the little we keep from an image's EXIF block before it is thrown
+// away by re-encoding. Orientation is 1 when unknown
+type Metadata struct {
+	Orientation int
+	CapturedAt  *time.Time
+}
+
+// readMetadata - finds the EXIF block of a JPEG. Anything that is not a JPEG or
+// has no readable EXIF block returns the default metadata
+func readMetadata(r io.Reader) Metadata {
+	metadata := Metadata{Orientation: 1}
+	exif, err := findJpegExif(r)
+	if err != nil || exif == nil {
+		return metadata
+	}
+	parseExif(exif, &metadata)
+	return metadata
+}
+
+func findJpegExif(r io.Reader) ([]byte, error) {
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return nil, errors.New("not a jpeg")
+	}
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xFF {
+			return nil, errors.New("invalid jpeg marker")
+		}
+		// Start of scan, the metadata segments all come before the image data
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil, nil
+		}
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || length < 2 {
+			return nil, errors.New("invalid jpeg segment")
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, err
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+func parseExif(tiff []byte, metadata *Metadata) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	if value, ok := ifd0[tagOrientation]; ok {
+		orientation := int(order.Uint16(value[:2]))
+		if orientation >= 1 && orientation <= 8 {
+			metadata.Orientation = orientation
+		}
+	}
+	exifOffset, ok := ifd0[tagExifIFD]
+	if !ok {
+		return
+	}
+	exifIFD := readIFD(tiff, order, order.Uint32(exifOffset))
+	original, ok := exifIFD[tagDateTimeOriginal]
+	if !ok {
+		return
+	}
+	date := readAscii(tiff, order, original, 20)
+	location := time.UTC
+	if offset, ok := exifIFD[tagOffsetTimeOriginal]; ok {
+		if zone, err := time.Parse("-07:00", readAscii(tiff, order, offset, 7)); err == nil {
+			location = zone.Location()
+		}
+	}
+	if capturedAt, err := time.ParseInLocation(exifDateLayout, date, location); err == nil {
+		metadata.CapturedAt = &capturedAt
+	}
+}
+
+// readIFD - maps each tag in the directory at offset to its raw 4 byte value
+// field, which holds either the value itself or an offset to it
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	entries := map[uint16][]byte{}
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		entries[order.Uint16(tiff[start:])] = tiff[start+8 : start+12]
+	}
+	return entries
+}
+
+// readAscii - reads an ASCII value of up to length bytes stored at the offset
+// held in value
+func readAscii(tiff []byte, order binary.ByteOrder, value []byte, length int) string {
+	offset := int(order.Uint32(value))
+	if offset < 0 || offset+length > len(tiff) {
+		return ""
+	}
+	return strings.TrimRight(string(tiff[offset:offset+length]), "\x00 ")
+}
+
+// orient - rotates and flips img so it displays upright once the EXIF
+// orientation it relied on is stripped
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,87 @@
+//go:build integration
+
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// exifSegment - an APP1 segment with an orientation and a DateTimeOriginal
+func exifSegment(orientation uint16, capturedAt string) []byte {
+	tiff := &bytes.Buffer{}
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(tiff, le, uint16(42))
+	binary.Write(tiff, le, uint32(8))
+	// IFD0 with two entries, ends at 8 + 2 + 2*12 + 4 = 38
+	binary.Write(tiff, le, uint16(2))
+	binary.Write(tiff, le, []uint16{tagOrientation, 3})
+	binary.Write(tiff, le, uint32(1))
+	binary.Write(tiff, le, []uint16{orientation, 0})
+	binary.Write(tiff, le, []uint16{tagExifIFD, 4})
+	binary.Write(tiff, le, []uint32{1, 38})
+	binary.Write(tiff, le, uint32(0))
+	// Exif IFD with one entry, its string follows at 38 + 2 + 12 + 4 = 56
+	binary.Write(tiff, le, uint16(1))
+	binary.Write(tiff, le, []uint16{tagDateTimeOriginal, 2})
+	binary.Write(tiff, le, []uint32{20, 56})
+	binary.Write(tiff, le, uint32(0))
+	tiff.WriteString(capturedAt + "\x00")
+
+	segment := &bytes.Buffer{}
+	segment.Write([]byte{0xFF, 0xE1})
+	binary.Write(segment, binary.BigEndian, uint16(2+6+tiff.Len()))
+	segment.WriteString("Exif\x00\x00")
+	segment.Write(tiff.Bytes())
+	return segment.Bytes()
+}
+
+func writeTestJpegWithExif(t *testing.T, width int, height int, orientation uint16, capturedAt string) string {
+	encoded := &bytes.Buffer{}
+	assert.NoError(t, jpeg.Encode(encoded, image.NewRGBA(image.Rect(0, 0, width, height)), nil))
+	raw := encoded.Bytes()
+	withExif := append(append([]byte{}, raw[:2]...), exifSegment(orientation, capturedAt)...)
+	withExif = append(withExif, raw[2:]...)
+	path := filepath.Join(t.TempDir(), "phone.jpg")
+	assert.NoError(t, os.WriteFile(path, withExif, 0o644))
+	return path
+}
+
+func TestExifNormalization(t *testing.T) {
+	t.Run("test orientation is applied and capture time is read", func(t *testing.T) {
+		processor := &Processor{Formats: []string{FormatJPEG}, Quality: 80}
+		variants, metadata, err := processor.Process(writeTestJpegWithExif(t, 40, 20, 6, "2023:04:01 10:20:30"))
+		assert.NoError(t, err)
+		defer Cleanup(variants)
+
+		assert.Equal(t, 6, metadata.Orientation)
+		assert.NotNil(t, metadata.CapturedAt)
+		assert.True(t, time.Date(2023, 4, 1, 10, 20, 30, 0, time.UTC).Equal(*metadata.CapturedAt))
+
+		for _, variant := range variants {
+			raw, err := os.ReadFile(variant.Path)
+			assert.NoError(t, err)
+			config, err := jpeg.DecodeConfig(bytes.NewReader(raw))
+			assert.NoError(t, err)
+			//Rotated a quarter turn, so the landscape original is now portrait
+			assert.Equal(t, 20, config.Width)
+			assert.Equal(t, 40, config.Height)
+			//Re-encoding leaves no EXIF block behind
+			assert.False(t, bytes.Contains(raw, []byte("Exif\x00\x00")))
+		}
+	})
+
+	t.Run("test images without exif keep their defaults", func(t *testing.T) {
+		metadata := readMetadata(bytes.NewReader([]byte("not an image")))
+		assert.Equal(t, 1, metadata.Orientation)
+		assert.Nil(t, metadata.CapturedAt)
+	})
+}
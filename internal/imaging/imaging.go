@@ -0,0 +1,250 @@
+package imaging
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	SizeThumbnail = "thumbnail"
+	SizeMedium    = "medium"
+	SizeFull      = "full"
+
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+
+	defaultQuality = 82
+)
+
+// Size - a variant is scaled down so its longest side is at most MaxDimension.
+// Images already smaller are never scaled up
+type Size struct {
+	Name         string
+	MaxDimension int
+}
+
+var Sizes = []Size{
+	{Name: SizeThumbnail, MaxDimension: 200},
+	{Name: SizeMedium, MaxDimension: 800},
+	{Name: SizeFull, MaxDimension: 2048},
+}
+
+// ImageUrls - where each variant of an uploaded image was stored. Url is the
+// full size JPEG, WebP holds the same sizes when WebP encoding is enabled.
+// CapturedAt comes from the original's EXIF block, when it had one
+type ImageUrls struct {
+	Url          string     `json:"url"`
+	ThumbnailUrl string     `json:"thumbnailUrl"`
+	MediumUrl    string     `json:"mediumUrl"`
+	WebP         *ImageUrls `json:"webp,omitempty"`
+	CapturedAt   *time.Time `json:"capturedAt,omitempty"`
+}
+
+// LegacyImageUrls - images uploaded before variants existed only have the
+// original, so every size points at it
+func LegacyImageUrls(url string) ImageUrls {
+	return ImageUrls{Url: url, ThumbnailUrl: url, MediumUrl: url}
+}
+
+// Variant - one encoded size and format of an image, written to a temp file
+type Variant struct {
+	Size   string
+	Format string
+	Path   string
+}
+
+type BlobStore interface {
+	UploadToBlobStore(fileList []string, ctx context.Context) (resultUris []string, err error)
+}
+
+type Processor struct {
+	Formats []string
+	Quality int
+}
+
+// NewProcessor - IMAGE_FORMATS is a comma separated list of jpeg and webp
+// (default both) and IMAGE_QUALITY the lossy quality from 1 to 100 (default 82).
+// JPEG is always produced, WebP needs a build with cgo
+func NewProcessor() *Processor {
+	formats := []string{FormatJPEG}
+	requested := os.Getenv("IMAGE_FORMATS")
+	if requested == "" {
+		requested = FormatJPEG + "," + FormatWebP
+	}
+	for _, format := range strings.Split(requested, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format != FormatWebP {
+			continue
+		}
+		if !webpSupported {
+			log.Warn("webp image variants requested but this build has no webp encoder, only jpeg will be produced")
+			continue
+		}
+		formats = append(formats, FormatWebP)
+	}
+	quality, err := strconv.Atoi(os.Getenv("IMAGE_QUALITY"))
+	if err != nil || quality < 1 || quality > 100 {
+		quality = defaultQuality
+	}
+	return &Processor{
+		Formats: formats,
+		Quality: quality,
+	}
+}
+
+// Process - decodes the image at srcPath and writes every size in every format
+// to temp files. Variants are rotated upright and, being re-encoded, carry none
+// of the original's metadata. The caller should Cleanup the variants once stored
+func (p *Processor) Process(srcPath string) ([]Variant, Metadata, error) {
+	tag := "imaging.Process"
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("os.Open in %s failed for %v", tag, err)
+	}
+	defer src.Close()
+	metadata := readMetadata(src)
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, Metadata{}, fmt.Errorf("file.Seek in %s failed for %v", tag, err)
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("image.Decode in %s failed for %v", tag, err)
+	}
+	base := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
+	variants := []Variant{}
+	for _, size := range Sizes {
+		resized := orient(resize(img, size.MaxDimension), metadata.Orientation)
+		for _, format := range p.Formats {
+			variant, err := p.encode(resized, base, size.Name, format)
+			if err != nil {
+				Cleanup(variants)
+				return nil, Metadata{}, fmt.Errorf("encode in %s failed for %v", tag, err)
+			}
+			variants = append(variants, variant)
+		}
+	}
+	return variants, metadata, nil
+}
+
+// UploadVariants - processes the image, stores every variant in the blob store
+// and returns their urls
+func (p *Processor) UploadVariants(ctx context.Context, srcPath string, blobStore BlobStore) (*ImageUrls, error) {
+	tag := "imaging.UploadVariants"
+	variants, metadata, err := p.Process(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer Cleanup(variants)
+	paths := make([]string, len(variants))
+	for i, variant := range variants {
+		paths[i] = variant.Path
+	}
+	urls, err := blobStore.UploadToBlobStore(paths, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("blob.UploadToBlobStore in %s failed for %v", tag, err)
+	}
+	imageUrls := NewImageUrls(variants, urls)
+	imageUrls.CapturedAt = metadata.CapturedAt
+	return &imageUrls, nil
+}
+
+// NewImageUrls - urls[i] is where variants[i] was stored
+func NewImageUrls(variants []Variant, urls []string) ImageUrls {
+	var jpegUrls ImageUrls
+	var webpUrls *ImageUrls
+	for i, variant := range variants {
+		target := &jpegUrls
+		if variant.Format == FormatWebP {
+			if webpUrls == nil {
+				webpUrls = &ImageUrls{}
+			}
+			target = webpUrls
+		}
+		switch variant.Size {
+		case SizeThumbnail:
+			target.ThumbnailUrl = urls[i]
+		case SizeMedium:
+			target.MediumUrl = urls[i]
+		case SizeFull:
+			target.Url = urls[i]
+		}
+	}
+	jpegUrls.WebP = webpUrls
+	return jpegUrls
+}
+
+// Cleanup - removes the temp files written by Process
+func Cleanup(variants []Variant) {
+	for _, variant := range variants {
+		if err := os.Remove(variant.Path); err != nil && !os.IsNotExist(err) {
+			log.Error(err)
+		}
+	}
+}
+
+func (p *Processor) encode(img image.Image, base string, size string, format string) (Variant, error) {
+	extension := ".jpg"
+	if format == FormatWebP {
+		extension = ".webp"
+	}
+	out, err := os.CreateTemp("", fmt.Sprintf("%s-%s-*%s", base, size, extension))
+	if err != nil {
+		return Variant{}, err
+	}
+	variant := Variant{Size: size, Format: format, Path: out.Name()}
+	if format == FormatWebP {
+		err = encodeWebP(out, img, p.Quality)
+	} else {
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: p.Quality})
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(variant.Path)
+		return Variant{}, err
+	}
+	return variant, nil
+}
+
+// resize - scales img to fit within maxDimension on a white background, so
+// transparent images do not turn black as JPEGs
+func resize(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxDimension || height > maxDimension {
+		if width >= height {
+			height = max(1, height*maxDimension/width)
+			width = maxDimension
+		} else {
+			width = max(1, width*maxDimension/height)
+			height = maxDimension
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
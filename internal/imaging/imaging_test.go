@@ -0,0 +1,85 @@
+//go:build integration
+
+package imaging
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeBlobStore struct{}
+
+func (fakeBlobStore) UploadToBlobStore(fileList []string, ctx context.Context) ([]string, error) {
+	urls := make([]string, len(fileList))
+	for i, path := range fileList {
+		urls[i] = "https://blobs.example.com/" + filepath.Base(path)
+	}
+	return urls, nil
+}
+
+func writeTestPng(t *testing.T, width int, height int) string {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.NRGBA{G: 255, A: 255})
+	}
+	path := filepath.Join(t.TempDir(), "leaf.png")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.NoError(t, png.Encode(file, img))
+	return path
+}
+
+func TestProcessor(t *testing.T) {
+	t.Run("test every size is produced without upscaling", func(t *testing.T) {
+		processor := &Processor{Formats: []string{FormatJPEG}, Quality: 80}
+		variants, _, err := processor.Process(writeTestPng(t, 1000, 500))
+		assert.NoError(t, err)
+		defer Cleanup(variants)
+		assert.Equal(t, len(Sizes), len(variants))
+
+		expected := map[string]image.Point{
+			SizeThumbnail: {X: 200, Y: 100},
+			SizeMedium:    {X: 800, Y: 400},
+			SizeFull:      {X: 1000, Y: 500},
+		}
+		for _, variant := range variants {
+			file, err := os.Open(variant.Path)
+			assert.NoError(t, err)
+			config, format, err := image.DecodeConfig(file)
+			file.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, "jpeg", format)
+			assert.Equal(t, expected[variant.Size], image.Point{X: config.Width, Y: config.Height}, variant.Size)
+		}
+	})
+
+	t.Run("test upload variants returns a url per size and removes temp files", func(t *testing.T) {
+		processor := &Processor{Formats: []string{FormatJPEG}, Quality: 80}
+		urls, err := processor.UploadVariants(context.Background(), writeTestPng(t, 300, 300), fakeBlobStore{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, urls.Url)
+		assert.NotEmpty(t, urls.ThumbnailUrl)
+		assert.NotEmpty(t, urls.MediumUrl)
+		assert.Nil(t, urls.WebP)
+		_, err = os.Stat(filepath.Join(os.TempDir(), filepath.Base(urls.ThumbnailUrl)))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("test webp variants when supported", func(t *testing.T) {
+		if !webpSupported {
+			t.Skip("built without cgo")
+		}
+		processor := &Processor{Formats: []string{FormatJPEG, FormatWebP}, Quality: 80}
+		urls, err := processor.UploadVariants(context.Background(), writeTestPng(t, 300, 300), fakeBlobStore{})
+		assert.NoError(t, err)
+		assert.NotNil(t, urls.WebP)
+		assert.Equal(t, ".webp", filepath.Ext(urls.WebP.ThumbnailUrl))
+	})
+}
@@ -0,0 +1,15 @@
+//go:build cgo
+
+package imaging
+
+import (
+	"github.com/chai2010/webp"
+	"image"
+	"io"
+)
+
+const webpSupported = true
+
+func encodeWebP(w io.Writer, img image.Image, quality int) error {
+	return webp.Encode(w, img, &webp.Options{Quality: float32(quality)})
+}
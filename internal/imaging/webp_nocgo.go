@@ -0,0 +1,16 @@
+//go:build !cgo
+
+package imaging
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+// The webp encoder wraps libwebp, builds without cgo only produce jpeg
+const webpSupported = false
+
+func encodeWebP(w io.Writer, img image.Image, quality int) error {
+	return errors.New("webp encoding is not supported without cgo")
+}
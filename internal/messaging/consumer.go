@@ -0,0 +1,274 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Shopify/sarama"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DeadLetterSuffix = ".dlq"
+
+	defaultConsumerGroup       = "nectar-rest-api"
+	defaultConsumerConcurrency = 4
+	defaultConsumerMaxAttempts = 5
+	defaultConsumerBackoff     = time.Second
+	maxConsumerBackoff         = time.Minute
+)
+
+// HandlerFunc - handles one message of a topic. A returned error retries the
+// message with backoff, once MaxAttempts is reached it is sent to the topic's
+// dead letter topic. Handlers must cope with a message being delivered twice
+type HandlerFunc func(ctx context.Context, message []byte) error
+
+// Publisher - where dead letters are sent
+type Publisher interface {
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
+}
+
+// DeadLetter - what is published to <topic>.dlq for a message no attempt
+// could handle
+type DeadLetter struct {
+	Topic    string    `json:"topic"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+	Message  []byte    `json:"message"`
+}
+
+// Consumer - a kafka consumer group member handing each message of the topics
+// it has handlers for to that handler. Messages of a partition are handled in
+// order, at most Concurrency handlers run at once across partitions
+type Consumer struct {
+	Brokers     []string
+	GroupId     string
+	Publisher   Publisher
+	Concurrency int
+	MaxAttempts int
+	Backoff     time.Duration
+
+	handlers map[string]HandlerFunc
+	slots    chan struct{}
+	group    sarama.ConsumerGroup
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewConsumer - BROKERS_URL are the brokers, KAFKA_CONSUMER_GROUP (default
+// nectar-rest-api) the group, CONSUMER_CONCURRENCY (default 4) how many
+// messages are handled at once, CONSUMER_MAX_ATTEMPTS (default 5) how often a
+// message is tried before it is dead lettered and CONSUMER_RETRY_BACKOFF
+// (default 1s) the delay before the first retry, doubling after that
+func NewConsumer(publisher Publisher) *Consumer {
+	groupId := os.Getenv("KAFKA_CONSUMER_GROUP")
+	if groupId == "" {
+		groupId = defaultConsumerGroup
+	}
+	backoff, err := time.ParseDuration(os.Getenv("CONSUMER_RETRY_BACKOFF"))
+	if err != nil || backoff <= 0 {
+		backoff = defaultConsumerBackoff
+	}
+	return &Consumer{
+		Brokers:     strings.Split(os.Getenv("BROKERS_URL"), ","),
+		GroupId:     groupId,
+		Publisher:   publisher,
+		Concurrency: envInt("CONSUMER_CONCURRENCY", defaultConsumerConcurrency),
+		MaxAttempts: envInt("CONSUMER_MAX_ATTEMPTS", defaultConsumerMaxAttempts),
+		Backoff:     backoff,
+		handlers:    map[string]HandlerFunc{},
+	}
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Handle - registers the handler of a topic, must be called before Start
+func (c *Consumer) Handle(topic string, handler HandlerFunc) {
+	if _, ok := c.handlers[topic]; ok {
+		panic("messaging: a handler for " + topic + " is already registered")
+	}
+	c.handlers[topic] = handler
+}
+
+// Topics - the topics with a handler, sorted
+func (c *Consumer) Topics() []string {
+	topics := make([]string, 0, len(c.handlers))
+	for topic := range c.handlers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Start - joins the consumer group and consumes in the background until ctx is
+// cancelled or Close is called
+func (c *Consumer) Start(ctx context.Context) error {
+	topics := c.Topics()
+	if len(topics) == 0 {
+		return fmt.Errorf("no handlers registered with the consumer")
+	}
+	config := sarama.NewConfig()
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	group, err := sarama.NewConsumerGroup(c.Brokers, c.GroupId, config)
+	if err != nil {
+		return fmt.Errorf("failed to create a consumer group: %v", err)
+	}
+	c.group = group
+	c.slots = make(chan struct{}, c.Concurrency)
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	log.Infof("starting message consumer, group: %s, topics: %v", c.GroupId, topics)
+	go func() {
+		defer close(c.done)
+		for ctx.Err() == nil {
+			// Consume returns whenever the group rebalances
+			if err := group.Consume(ctx, topics, c); err != nil {
+				log.Errorf("message consumer failed: %v", err)
+				sleep(ctx, c.Backoff)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close - stops taking new messages and waits for the ones being handled, or
+// for ctx to end, before leaving the group. Unfinished messages are not
+// committed and are delivered again
+func (c *Consumer) Close(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		log.Warn("message consumer did not stop in time")
+	}
+	if err := c.group.Close(); err != nil {
+		return fmt.Errorf("failed to close the consumer group: %v", err)
+	}
+	log.Info("stopped message consumer")
+	return nil
+}
+
+func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim - a message is only marked once it was handled or dead
+// lettered, so one interrupted by a shutdown or rebalance is delivered again
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			select {
+			case c.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+			err := c.Process(ctx, message.Topic, string(message.Key), message.Value)
+			<-c.slots
+			if err != nil {
+				return nil
+			}
+			session.MarkMessage(message, "")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Process - hands the message to its topic's handler until it succeeds or runs
+// out of attempts, and dead letters it then. Handlers are not cancelled by ctx
+// so a shutdown lets them finish, only the waits between attempts are. An
+// error means ctx ended before the message was handled or dead lettered
+func (c *Consumer) Process(ctx context.Context, topic string, key string, message []byte) error {
+	handler, ok := c.handlers[topic]
+	if !ok {
+		log.Warnf("no handler for a message of topic %s, skipping it", topic)
+		return nil
+	}
+	var handlerErr error
+	attempts := 0
+	for attempts < c.MaxAttempts {
+		attempts++
+		if handlerErr = handler(context.Background(), message); handlerErr == nil {
+			return nil
+		}
+		log.Warnf("handling a message of topic %s failed, attempt %d: %v", topic, attempts, handlerErr)
+		if attempts < c.MaxAttempts && !sleep(ctx, backoff(c.Backoff, attempts)) {
+			return ctx.Err()
+		}
+	}
+	return c.deadLetter(ctx, topic, key, message, attempts, handlerErr)
+}
+
+// deadLetter - keeps trying to publish, giving up would lose the message. The
+// dead letter keeps the message's key
+func (c *Consumer) deadLetter(ctx context.Context, topic string, key string, message []byte, attempts int, handlerErr error) error {
+	deadLetter, err := json.Marshal(DeadLetter{
+		Topic:    topic,
+		Error:    handlerErr.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now().UTC(),
+		Message:  message,
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal in messaging.deadLetter failed for %v", err)
+	}
+	for retry := 1; ; retry++ {
+		err := c.Publisher.PushToQueue(ctx, topic+DeadLetterSuffix, key, deadLetter)
+		if err == nil {
+			log.Errorf("gave up on a message of topic %s after %d attempts, sent it to %s: %v", topic, attempts, topic+DeadLetterSuffix, handlerErr)
+			return nil
+		}
+		log.Errorf("dead lettering a message of topic %s failed: %v", topic, err)
+		if !sleep(ctx, backoff(c.Backoff, retry)) {
+			return ctx.Err()
+		}
+	}
+}
+
+// backoff - the delay after the given attempt, doubling up to a minute
+func backoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxConsumerBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxConsumerBackoff {
+		return maxConsumerBackoff
+	}
+	return delay
+}
+
+// sleep - returns false if ctx ended first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
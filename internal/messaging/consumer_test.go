@@ -0,0 +1,72 @@
+//go:build integration
+
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestConsumer(publisher Publisher) *Consumer {
+	consumer := NewConsumer(publisher)
+	consumer.MaxAttempts = 3
+	consumer.Backoff = time.Millisecond
+	return consumer
+}
+
+func TestConsumer(t *testing.T) {
+	t.Run("test a failing message is retried until it succeeds", func(t *testing.T) {
+		consumer := newTestConsumer(NewLogDriver())
+		calls := 0
+		consumer.Handle("plants", func(ctx context.Context, message []byte) error {
+			calls++
+			if calls < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		})
+		assert.NoError(t, consumer.Process(context.Background(), "plants", "some-plant", []byte("message")))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("test a message is dead lettered after the last attempt", func(t *testing.T) {
+		driver := NewMemoryDriver()
+		deadLetters, unsubscribe := driver.Subscribe("plants"+DeadLetterSuffix, 1)
+		defer unsubscribe()
+		consumer := newTestConsumer(driver)
+		consumer.Handle("plants", func(ctx context.Context, message []byte) error {
+			return errors.New("broken message")
+		})
+		assert.NoError(t, consumer.Process(context.Background(), "plants", "some-plant", []byte("message")))
+
+		var deadLetter DeadLetter
+		message := <-deadLetters
+		assert.Equal(t, "some-plant", message.Key)
+		assert.NoError(t, json.Unmarshal(message.Value, &deadLetter))
+		assert.Equal(t, "plants", deadLetter.Topic)
+		assert.Equal(t, "broken message", deadLetter.Error)
+		assert.Equal(t, 3, deadLetter.Attempts)
+		assert.Equal(t, []byte("message"), deadLetter.Message)
+	})
+
+	t.Run("test a shutdown between attempts leaves the message unhandled", func(t *testing.T) {
+		consumer := newTestConsumer(NewLogDriver())
+		consumer.Backoff = time.Hour
+		ctx, cancel := context.WithCancel(context.Background())
+		consumer.Handle("plants", func(ctx context.Context, message []byte) error {
+			cancel()
+			return errors.New("not yet")
+		})
+		assert.ErrorIs(t, consumer.Process(ctx, "plants", "some-plant", []byte("message")), context.Canceled)
+	})
+
+	t.Run("test retries back off up to a minute", func(t *testing.T) {
+		assert.Equal(t, time.Second, backoff(time.Second, 1))
+		assert.Equal(t, 4*time.Second, backoff(time.Second, 3))
+		assert.Equal(t, time.Minute, backoff(time.Second, 100))
+	})
+}
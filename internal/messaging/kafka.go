@@ -0,0 +1,43 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"github.com/Shopify/sarama"
+	log "github.com/sirupsen/logrus"
+)
+
+type KafkaDriver struct {
+	Producer sarama.SyncProducer
+}
+
+func NewKafkaDriver(brokersUrl []string) (*KafkaDriver, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	// NewSyncProducer creates a new SyncProducer using the given broker addresses and configuration.
+	conn, err := sarama.NewSyncProducer(brokersUrl, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a sync producer: %v", err)
+	}
+	return &KafkaDriver{Producer: conn}, nil
+}
+
+func (d *KafkaDriver) PushToQueue(ctx context.Context, topic string, key string, message []byte) error {
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.StringEncoder(message),
+	}
+	partition, offset, err := d.Producer.SendMessage(msg)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Message is stored in topic(%s)/partition(%d)/offset(%d)\n", topic, partition, offset)
+	return nil
+}
+
+func (d *KafkaDriver) Close() error {
+	return d.Producer.Close()
+}
@@ -0,0 +1,23 @@
+package messaging
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogDriver - drops every message after logging it, for running the api
+// without a broker
+type LogDriver struct{}
+
+func NewLogDriver() *LogDriver {
+	return &LogDriver{}
+}
+
+func (d *LogDriver) PushToQueue(ctx context.Context, topic string, key string, message []byte) error {
+	log.Debugf("dropping message for topic %s with key %s: %s", topic, key, message)
+	return nil
+}
+
+func (d *LogDriver) Close() error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrDriverClosed = errors.New("message queue driver is closed")
+
+// Message - a message delivered by the memory driver
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+type subscriber struct {
+	mu       sync.Mutex
+	messages chan Message
+	done     chan struct{}
+	once     sync.Once
+	closed   bool
+}
+
+// deliver - blocks until the subscriber has room, unsubscribes or ctx ends
+func (s *subscriber) deliver(ctx context.Context, message Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	select {
+	case s.messages <- message:
+		return nil
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *subscriber) close() {
+	// done first, so a deliver blocked on a full channel lets go of the lock
+	s.once.Do(func() { close(s.done) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.messages)
+	}
+}
+
+// MemoryDriver - delivers every message to the in-process subscribers of its
+// topic, for local runs and tests. Messages of a topic nobody subscribed to are
+// dropped
+type MemoryDriver struct {
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber
+	closed      bool
+}
+
+func NewMemoryDriver() *MemoryDriver {
+	return &MemoryDriver{subscribers: map[string][]*subscriber{}}
+}
+
+// Subscribe - returns a channel receiving every message pushed to the topic
+// from now on and a function that ends the subscription. A full channel holds
+// up the publisher, so buffer for bursts. The channel is closed when the
+// subscription ends or the driver is closed
+func (d *MemoryDriver) Subscribe(topic string, buffer int) (<-chan Message, func()) {
+	sub := &subscriber{messages: make(chan Message, buffer), done: make(chan struct{})}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		sub.close()
+		return sub.messages, func() {}
+	}
+	d.subscribers[topic] = append(d.subscribers[topic], sub)
+	return sub.messages, func() { d.unsubscribe(topic, sub) }
+}
+
+func (d *MemoryDriver) unsubscribe(topic string, sub *subscriber) {
+	d.mu.Lock()
+	subscribers := d.subscribers[topic]
+	for i, s := range subscribers {
+		if s == sub {
+			d.subscribers[topic] = append(subscribers[:i:i], subscribers[i+1:]...)
+			break
+		}
+	}
+	d.mu.Unlock()
+	sub.close()
+}
+
+func (d *MemoryDriver) PushToQueue(ctx context.Context, topic string, key string, message []byte) error {
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return ErrDriverClosed
+	}
+	subscribers := append([]*subscriber{}, d.subscribers[topic]...)
+	d.mu.RUnlock()
+
+	// Each subscriber gets its own copy so one can not change what another reads
+	for _, sub := range subscribers {
+		value := append([]byte{}, message...)
+		if err := sub.deliver(ctx, Message{Topic: topic, Key: key, Value: value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDriver) Close() error {
+	d.mu.Lock()
+	subscribers := d.subscribers
+	d.subscribers = map[string][]*subscriber{}
+	d.closed = true
+	d.mu.Unlock()
+	for _, topicSubscribers := range subscribers {
+		for _, sub := range topicSubscribers {
+			sub.close()
+		}
+	}
+	return nil
+}
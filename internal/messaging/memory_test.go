@@ -0,0 +1,50 @@
+//go:build integration
+
+package messaging
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryDriver(t *testing.T) {
+	t.Run("test subscribers receive the messages of their topic", func(t *testing.T) {
+		driver := NewMemoryDriver()
+		plants, unsubscribe := driver.Subscribe("plants", 2)
+		defer unsubscribe()
+		users, _ := driver.Subscribe("users", 2)
+
+		assert.NoError(t, driver.PushToQueue(context.Background(), "plants", "some-plant", []byte("first")))
+		assert.NoError(t, driver.PushToQueue(context.Background(), "plants", "some-plant", []byte("second")))
+		assert.Equal(t, Message{Topic: "plants", Key: "some-plant", Value: []byte("first")}, <-plants)
+		assert.Equal(t, Message{Topic: "plants", Key: "some-plant", Value: []byte("second")}, <-plants)
+		assert.Empty(t, users)
+
+		assert.NoError(t, driver.Close())
+		_, open := <-users
+		assert.False(t, open)
+		assert.ErrorIs(t, driver.PushToQueue(context.Background(), "plants", "some-plant", []byte("third")), ErrDriverClosed)
+	})
+
+	t.Run("test a full subscriber holds up the publisher until unsubscribed", func(t *testing.T) {
+		driver := NewMemoryDriver()
+		_, unsubscribe := driver.Subscribe("plants", 0)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, driver.PushToQueue(ctx, "plants", "some-plant", []byte("blocked")), context.DeadlineExceeded)
+
+		pushed := make(chan error)
+		go func() { pushed <- driver.PushToQueue(context.Background(), "plants", "some-plant", []byte("blocked")) }()
+		unsubscribe()
+		assert.NoError(t, <-pushed)
+	})
+
+	t.Run("test the log driver accepts every message", func(t *testing.T) {
+		mq := &MessageQueue{Driver: NewLogDriver()}
+		assert.NoError(t, mq.PushToQueue(context.Background(), "plants", "some-plant", []byte("dropped")))
+		assert.NoError(t, mq.Close())
+	})
+}
@@ -3,51 +3,69 @@ package messaging
 import (
 	"context"
 	"fmt"
-	"github.com/Shopify/sarama"
 	log "github.com/sirupsen/logrus"
 	"os"
 )
 
-type messageProducer = sarama.SyncProducer
+const (
+	DriverKafka  = "kafka"
+	DriverMemory = "memory"
+	DriverLog    = "log"
+)
+
+// Driver - where messages are sent. PushToQueue must be safe to call from
+// several goroutines, messages with the same key must be delivered in the order
+// they were pushed
+type Driver interface {
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
+	Close() error
+}
 
 type MessageQueue struct {
-	Producer messageProducer
+	Driver Driver
 }
 
+// NewMessageQueue - MESSAGE_QUEUE_DRIVER picks the driver: kafka sends to the
+// brokers in BROKERS_URL, memory delivers to in-process subscribers and log only
+// logs each message. When it is unset kafka is used if KAFKA_ACTIVE is set.
+// Memory and log drop messages nobody is listening for, the outbox relay and
+// the reminder worker still count them as sent, so they must be asked for
 func NewMessageQueue() (*MessageQueue, error) {
-	if os.Getenv("KAFKA_ACTIVE") != "" {
-		brokersUrl := os.Getenv("BROKERS_URL")
-		conn, err := connectProducer([]string{brokersUrl})
+	driverName := os.Getenv("MESSAGE_QUEUE_DRIVER")
+	if driverName == "" {
+		if os.Getenv("KAFKA_ACTIVE") == "" {
+			return nil, fmt.Errorf("no message queue configured, set KAFKA_ACTIVE and BROKERS_URL, or MESSAGE_QUEUE_DRIVER=%s to drop messages", DriverLog)
+		}
+		driverName = DriverKafka
+	}
+	var driver Driver
+	switch driverName {
+	case DriverKafka:
+		log.Info("using kafka message queue driver")
+		kafka, err := NewKafkaDriver([]string{os.Getenv("BROKERS_URL")})
 		if err != nil {
 			return nil, err
 		}
-		return &MessageQueue{Producer: conn}, nil
+		driver = kafka
+	case DriverMemory:
+		log.Info("using in-memory message queue driver")
+		driver = NewMemoryDriver()
+	case DriverLog:
+		log.Info("using logging message queue driver, messages are not delivered")
+		driver = NewLogDriver()
+	default:
+		return nil, fmt.Errorf("unknown message queue driver: %s", driverName)
 	}
-	return &MessageQueue{}, nil
+	return &MessageQueue{Driver: driver}, nil
 }
 
-func connectProducer(brokersUrl []string) (sarama.SyncProducer, error) {
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-	config.Producer.RequiredAcks = sarama.WaitForAll
-	config.Producer.Retry.Max = 5
-	// NewSyncProducer creates a new SyncProducer using the given broker addresses and configuration.
-	conn, err := sarama.NewSyncProducer(brokersUrl, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a sync producer: %v", err)
-	}
-	return conn, nil
-}
-
-func (mq *MessageQueue) PushToQueue(ctx context.Context, topic string, message []byte) error {
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(message),
-	}
-	partition, offset, err := mq.Producer.SendMessage(msg)
-	if err != nil {
+func (mq *MessageQueue) PushToQueue(ctx context.Context, topic string, key string, message []byte) error {
+	if err := mq.Driver.PushToQueue(ctx, topic, key, message); err != nil {
 		return fmt.Errorf("failed to push to queue: %v", err)
 	}
-	log.Debugf("Message is stored in topic(%s)/partition(%d)/offset(%d)\n", topic, partition, offset)
 	return nil
 }
+
+func (mq *MessageQueue) Close() error {
+	return mq.Driver.Close()
+}
@@ -25,3 +25,21 @@ type DuplicateKeyError struct{}
 func (DuplicateKeyError) Error() string {
 	return "Unable to insert record. A duplicate key was found"
 }
+
+type ForbiddenError struct {
+	Message string
+}
+
+func (e ForbiddenError) Error() string {
+	return e.Message
+}
+
+// PreconditionFailedError - a conditional write found the entity changed since
+// the caller last read it
+type PreconditionFailedError struct {
+	Message string
+}
+
+func (e PreconditionFailedError) Error() string {
+	return e.Message
+}
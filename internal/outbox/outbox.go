@@ -0,0 +1,186 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultInterval    = time.Second
+	defaultBatchSize   = 100
+	defaultMaxAttempts = 10
+	defaultRetention   = 24 * time.Hour
+	defaultLease       = 30 * time.Second
+	baseBackoff        = time.Second
+	maxBackoff         = time.Hour
+)
+
+// Message - a row of the outbox table, the payload is an encoded
+// events.Envelope. Messages with the same key are published in the order they
+// were written
+type Message struct {
+	Id        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+	Attempts  int
+}
+
+// NewMessage - the event in its envelope, keyed by its aggregate
+func NewMessage(ctx context.Context, payload events.Payload) (Message, error) {
+	envelope, err := events.New(ctx, payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("events.New in outbox.NewMessage failed for %v", err)
+	}
+	data, err := events.Encode(envelope)
+	if err != nil {
+		return Message{}, fmt.Errorf("events.Encode in outbox.NewMessage failed for %v", err)
+	}
+	schema, _ := events.Lookup(envelope.Type)
+	return Message{Topic: schema.Topic, Key: payload.AggregateId(), Payload: data}, nil
+}
+
+// Store - ClaimOutboxMessages leases the oldest pending message of each key so
+// concurrent relays never publish the same message or reorder a key
+type Store interface {
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]Message, error)
+	MarkOutboxMessageSent(ctx context.Context, id int64) error
+	MarkOutboxMessageFailed(ctx context.Context, id int64, reason string, nextAttemptAt *time.Time) error
+	DeleteSentOutboxMessages(ctx context.Context, sentBefore time.Time) (int64, error)
+}
+
+type MessageQueue interface {
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
+}
+
+type Relay struct {
+	Store        Store
+	MessageQueue MessageQueue
+	Interval     time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	Retention    time.Duration
+}
+
+// NewRelay - OUTBOX_RELAY_INTERVAL (default 1s) is how often pending messages
+// are polled for, OUTBOX_MAX_ATTEMPTS (default 10) how often a message is tried
+// before it is given up on and OUTBOX_RETENTION (default 24h) how long sent
+// messages are kept
+func NewRelay(store Store, messageQueue MessageQueue) *Relay {
+	maxAttempts, err := strconv.Atoi(os.Getenv("OUTBOX_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &Relay{
+		Store:        store,
+		MessageQueue: messageQueue,
+		Interval:     envDuration("OUTBOX_RELAY_INTERVAL", defaultInterval),
+		BatchSize:    defaultBatchSize,
+		MaxAttempts:  maxAttempts,
+		Retention:    envDuration("OUTBOX_RETENTION", defaultRetention),
+	}
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Start - relays pending messages on every tick until the context is cancelled
+func (r *Relay) Start(ctx context.Context) {
+	log.Infof("starting outbox relay, interval: %s", r.Interval)
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		r.drain(ctx)
+		if deleted, err := r.Store.DeleteSentOutboxMessages(ctx, time.Now().Add(-r.Retention)); err != nil {
+			log.Errorf("outbox cleanup failed: %v", err)
+		} else if deleted > 0 {
+			log.Debugf("deleted %d sent outbox messages", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("stopped outbox relay")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain - relays until nothing is left to claim, only one message per key is
+// claimed at a time so a burst for one key takes several rounds
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		relayed, err := r.RelayPending(ctx)
+		if err != nil {
+			log.Errorf("outbox relay failed: %v", err)
+			return
+		}
+		if relayed == 0 {
+			return
+		}
+	}
+}
+
+// RelayPending - publishes one batch of pending messages, returning how many
+// were claimed
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	tag := "outbox.RelayPending"
+	messages, err := r.Store.ClaimOutboxMessages(ctx, r.BatchSize, defaultLease)
+	if err != nil {
+		return 0, fmt.Errorf("Store.ClaimOutboxMessages in %s failed for %v", tag, err)
+	}
+	for _, message := range messages {
+		if err := r.MessageQueue.PushToQueue(ctx, message.Topic, message.Key, message.Payload); err != nil {
+			r.markFailed(ctx, message, err)
+			continue
+		}
+		if err := r.Store.MarkOutboxMessageSent(ctx, message.Id); err != nil {
+			// The lease runs out and the message is published again, consumers
+			// must already cope with at least once delivery
+			log.Errorf("Store.MarkOutboxMessageSent in %s failed for %v", tag, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// markFailed - retries with exponential backoff until MaxAttempts, after which
+// the message is left in the table for inspection and no longer blocks its key
+func (r *Relay) markFailed(ctx context.Context, message Message, publishErr error) {
+	attempts := message.Attempts + 1
+	var nextAttemptAt *time.Time
+	if attempts < r.MaxAttempts {
+		next := time.Now().Add(Backoff(attempts))
+		nextAttemptAt = &next
+		log.Warnf("publishing outbox message %d failed, attempt %d: %v", message.Id, attempts, publishErr)
+	} else {
+		log.Errorf("giving up on outbox message %d after %d attempts: %v", message.Id, attempts, publishErr)
+	}
+	if err := r.Store.MarkOutboxMessageFailed(ctx, message.Id, publishErr.Error(), nextAttemptAt); err != nil {
+		log.Errorf("Store.MarkOutboxMessageFailed in outbox.markFailed failed for %v", err)
+	}
+}
+
+// Backoff - the delay before the given attempt, doubling from a second up to an hour
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return baseBackoff
+	}
+	if attempts > 12 {
+		return maxBackoff
+	}
+	backoff := baseBackoff << (attempts - 1)
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
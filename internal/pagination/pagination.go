@@ -0,0 +1,107 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor - the position of the last item on the previous page. Lists are ordered
+// by CreatedAt then Id, both descending, so the pair is unique and stable
+type Cursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// Params - what the caller asked for. A nil After starts from the newest item
+type Params struct {
+	Limit int
+	After *Cursor
+}
+
+// Page - one page of a list. An empty NextCursor means there are no more items
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// NewParams - clamps the limit and decodes the opaque cursor handed out with a
+// previous page. An unreadable cursor is a BadRequestError
+func NewParams(limit int, cursor string) (Params, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	after, err := Decode(cursor)
+	if err != nil {
+		return Params{}, nectar_errors.BadRequestError{Message: "invalid cursor"}
+	}
+	return Params{Limit: limit, After: after}, nil
+}
+
+// FetchLimit - stores should ask for one more row than the page holds so that
+// NewPage can tell whether another page exists
+func (p Params) FetchLimit() int {
+	return p.Limit + 1
+}
+
+// NewPage - trims items fetched with FetchLimit down to the page size and sets
+// NextCursor from the last item kept. cursorAt returns the cursor for items[i]
+func NewPage[T any](items []T, p Params, cursorAt func(i int) Cursor) Page[T] {
+	if len(items) <= p.Limit {
+		return Page[T]{Items: items}
+	}
+	return Page[T]{
+		Items:      items[:p.Limit],
+		NextCursor: Encode(cursorAt(p.Limit - 1)),
+	}
+}
+
+func Encode(c Cursor) string {
+	raw := fmt.Sprintf("%s|%s", c.CreatedAt.UTC().Format(time.RFC3339Nano), c.Id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func Decode(cursor string) (*Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	// Checked here so a tampered cursor is a bad request rather than a failed
+	// ::uuid cast in the store
+	id, err := uuid.FromString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{CreatedAt: createdAt, Id: id.String()}, nil
+}
+
+// Args - the cursor as query arguments for the keyset condition
+// ($n::timestamptz IS NULL OR (created_at, id) < ($n::timestamptz, $m::uuid))
+func (p Params) Args() (createdAt any, id any) {
+	if p.After == nil {
+		return nil, nil
+	}
+	return p.After.CreatedAt, p.After.Id
+}
@@ -0,0 +1,75 @@
+package plant
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
+	"os"
+	"time"
+)
+
+const defaultCacheTTL = 5 * time.Minute
+
+// CachedStore - a Store reading plants through the cache. Writes made through
+// it invalidate the plant. Writes made elsewhere must call Invalidate, or
+// InvalidateOwner when the owner's username or profile image changes
+type CachedStore struct {
+	Store
+	plants *cache.Entities[Plant]
+}
+
+// NewCachedStore - PLANT_CACHE_TTL (default 5m) is how long a plant is cached
+func NewCachedStore(store Store, c cache.KeyValue) *CachedStore {
+	ttl, err := time.ParseDuration(os.Getenv("PLANT_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &CachedStore{Store: store, plants: cache.NewEntities[Plant](c, "plant", ttl)}
+}
+
+func (s *CachedStore) GetPlant(ctx context.Context, id string) (*Plant, error) {
+	return s.plants.Get(ctx, id, s.Store.GetPlant)
+}
+
+func (s *CachedStore) UpdatePlant(ctx context.Context, id string, p Plant, imagesToDelete []string, maxImages int) (*Plant, error) {
+	defer s.Invalidate(id)
+	return s.Store.UpdatePlant(ctx, id, p, imagesToDelete, maxImages)
+}
+
+func (s *CachedStore) DeletePlant(ctx context.Context, id string) error {
+	defer s.Invalidate(id)
+	return s.Store.DeletePlant(ctx, id)
+}
+
+func (s *CachedStore) AddPlantImageWithId(ctx context.Context, plantId string, imageUri string, maxImages int) (string, error) {
+	defer s.Invalidate(plantId)
+	return s.Store.AddPlantImageWithId(ctx, plantId, imageUri, maxImages)
+}
+
+func (s *CachedStore) DeletePlantImage(ctx context.Context, plantId string, uri string) error {
+	defer s.Invalidate(plantId)
+	return s.Store.DeletePlantImage(ctx, plantId, uri)
+}
+
+func (s *CachedStore) ReorderPlantImages(ctx context.Context, plantId string, images []string) error {
+	defer s.Invalidate(plantId)
+	return s.Store.ReorderPlantImages(ctx, plantId, images)
+}
+
+// Invalidate - forgets the cached plants, after a write whether or not it failed
+func (s *CachedStore) Invalidate(plantIds ...string) {
+	s.plants.Invalidate(plantIds...)
+}
+
+// InvalidateOwner - forgets every cached plant of the user, which show the
+// user's username and profile image
+func (s *CachedStore) InvalidateOwner(ctx context.Context, userId string) {
+	plantIds, err := s.Store.GetPlantIdsByUserId(ctx, userId)
+	if err != nil {
+		log.Errorf("Store.GetPlantIdsByUserId in plant.CachedStore.InvalidateOwner failed for %v", err)
+		return
+	}
+	if len(plantIds) > 0 {
+		s.Invalidate(plantIds...)
+	}
+}
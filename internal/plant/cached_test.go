@@ -0,0 +1,93 @@
+//go:build integration
+
+package plant
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingStore struct {
+	Store
+	reads   int32
+	release chan struct{}
+	plant   Plant
+}
+
+func (s *countingStore) GetPlant(ctx context.Context, id string) (*Plant, error) {
+	atomic.AddInt32(&s.reads, 1)
+	if s.release != nil {
+		<-s.release
+	}
+	p := s.plant
+	return &p, nil
+}
+
+func (s *countingStore) UpdatePlant(ctx context.Context, id string, p Plant, imagesToDelete []string, maxImages int) (*Plant, error) {
+	s.plant = p
+	return &p, nil
+}
+
+func (s *countingStore) GetPlantIdsByUserId(ctx context.Context, userId string) ([]string, error) {
+	return []string{s.plant.PlantId}, nil
+}
+
+func TestCachedStore(t *testing.T) {
+	t.Run("test reads are cached until the plant is updated", func(t *testing.T) {
+		store := &countingStore{plant: Plant{PlantId: "some-plant", CommonName: "Monstera"}}
+		cached := NewCachedStore(store, &cache.Cache{Driver: cache.NewMemoryDriver(10)})
+
+		for i := 0; i < 3; i++ {
+			p, err := cached.GetPlant(context.Background(), "some-plant")
+			assert.NoError(t, err)
+			assert.Equal(t, "Monstera", p.CommonName)
+		}
+		assert.Equal(t, int32(1), store.reads)
+
+		_, err := cached.UpdatePlant(context.Background(), "some-plant", Plant{PlantId: "some-plant", CommonName: "Pothos"}, nil, 3)
+		assert.NoError(t, err)
+		p, err := cached.GetPlant(context.Background(), "some-plant")
+		assert.NoError(t, err)
+		assert.Equal(t, "Pothos", p.CommonName)
+		assert.Equal(t, int32(2), store.reads)
+	})
+
+	t.Run("test a change to the owner's profile invalidates their plants", func(t *testing.T) {
+		store := &countingStore{plant: Plant{PlantId: "some-plant", UserId: "some-user", Username: "kevin"}}
+		cached := NewCachedStore(store, &cache.Cache{Driver: cache.NewMemoryDriver(10)})
+
+		_, err := cached.GetPlant(context.Background(), "some-plant")
+		assert.NoError(t, err)
+		store.plant.Username = "kevinm"
+		cached.InvalidateOwner(context.Background(), "some-user")
+		p, err := cached.GetPlant(context.Background(), "some-plant")
+		assert.NoError(t, err)
+		assert.Equal(t, "kevinm", p.Username)
+		assert.Equal(t, int32(2), store.reads)
+	})
+
+	t.Run("test concurrent misses share one query", func(t *testing.T) {
+		store := &countingStore{plant: Plant{PlantId: "some-plant"}, release: make(chan struct{})}
+		cached := NewCachedStore(store, &cache.Cache{Driver: cache.NewMemoryDriver(10)})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cached.GetPlant(context.Background(), "some-plant")
+				assert.NoError(t, err)
+			}()
+		}
+		// Give every reader the chance to join the query before it returns
+		time.Sleep(50 * time.Millisecond)
+		close(store.release)
+		wg.Wait()
+		assert.Equal(t, int32(1), store.reads)
+	})
+}
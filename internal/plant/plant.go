@@ -5,62 +5,87 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"os"
+	"strconv"
 	"time"
 )
 
+const defaultMaxImages = 3
+
 type Plant struct {
-	PlantId          string    `json:"plantId"`
-	UserId           string    `json:"userId"`
-	Username         string    `json:"username"`
-	UserProfileImage string    `json:"userProfileImage"`
-	CommonName       string    `json:"commonName"`
-	ScientificName   string    `json:"scientificName"`
-	Toxicity         string    `json:"toxicity"`
-	CreatedAt        time.Time `json:"createdAt"`
-	Images           []string  `json:"images"`
-	SearchTerms      []string  `json:"searchTerms"`
+	PlantId          string      `json:"plantId"`
+	UserId           string      `json:"userId"`
+	Username         string      `json:"username"`
+	UserProfileImage string      `json:"userProfileImage"`
+	CommonName       string      `json:"commonName"`
+	ScientificName   string      `json:"scientificName"`
+	Toxicity         string      `json:"toxicity"`
+	CreatedAt        time.Time   `json:"createdAt"`
+	UpdatedAt        time.Time   `json:"updatedAt"`
+	Images           []string    `json:"images"`
+	ImageUrls        []ImageUrls `json:"imageUrls"`
+	SearchTerms      []string    `json:"searchTerms"`
 }
 
-type ImageUrls struct {
-	Url          string `json:"url"`
-	ThumbnailUrl string `json:"thumbnailUrl"`
-}
+// ImageUrls - the resized variants of each of a plant's images, in the same
+// order as Images
+type ImageUrls = imaging.ImageUrls
 
-//Store - this interface defines all the methods
+// Store - this interface defines all the methods
 // the service needs in order to operate
 type Store interface {
 	GetPlant(ctx context.Context, id string) (*Plant, error)
-	GetPlantsByUserId(ctx context.Context, userId string) ([]Plant, error)
+	GetPlantsByUserId(ctx context.Context, userId string, page pagination.Params) (pagination.Page[Plant], error)
+	GetPlantIdsByUserId(ctx context.Context, userId string) ([]string, error)
 	AddPlant(ctx context.Context, p Plant, images []string) (*Plant, error)
-	AddPlantImageWithId(ctx context.Context, plantId string, imageUri string) (string, error)
+	AddPlantImageWithId(ctx context.Context, plantId string, imageUri string, maxImages int) (string, error)
 	DeletePlant(ctx context.Context, id string) error
-	UpdatePlant(ctx context.Context, id string, p Plant) (*Plant, error)
-	GetCareLogsEntries(ctx context.Context, plantId string) ([]care.LogEntry, error)
+	UpdatePlant(ctx context.Context, id string, p Plant, imagesToDelete []string, maxImages int) (*Plant, error)
+	GetCareLogsEntries(ctx context.Context, plantId string, page pagination.Params) (pagination.Page[care.LogEntry], error)
 	DeletePlantImage(ctx context.Context, plantId string, uri string) error
+	SearchPlants(ctx context.Context, query SearchQuery) ([]SearchResult, error)
+	AddImageVariants(ctx context.Context, urls ImageUrls) error
+	ReorderPlantImages(ctx context.Context, plantId string, images []string) error
 }
 
 type MessageQueue interface {
-	PushToQueue(ctx context.Context, topic string, message []byte) error
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
 }
 
 type BlobStore interface {
 	UploadToBlobStore(fileList []string, ctx context.Context) (resultUris []string, err error)
 }
 
+type ImageProcessor interface {
+	UploadVariants(ctx context.Context, srcPath string, blobStore imaging.BlobStore) (*imaging.ImageUrls, error)
+}
+
 // Service - is the struct on which out logic will
 // be built upon
 type Service struct {
-	Store        Store
-	MessageQueue MessageQueue
-	BlobStore    BlobStore
+	Store          Store
+	MessageQueue   MessageQueue
+	BlobStore      BlobStore
+	ImageProcessor ImageProcessor
+	MaxImages      int
 }
 
-// NewService - returns a pointer to a new service
-func NewService(store Store, blobService BlobStore, messageQueue MessageQueue) *Service {
+// NewService - returns a pointer to a new service. PLANT_MAX_IMAGES (default 3)
+// is how many images a plant may have
+func NewService(store Store, blobService BlobStore, imageProcessor ImageProcessor, messageQueue MessageQueue) *Service {
+	maxImages, err := strconv.Atoi(os.Getenv("PLANT_MAX_IMAGES"))
+	if err != nil || maxImages <= 0 {
+		maxImages = defaultMaxImages
+	}
 	return &Service{
-		Store:        store,
-		BlobStore:    blobService,
-		MessageQueue: messageQueue,
+		Store:          store,
+		BlobStore:      blobService,
+		ImageProcessor: imageProcessor,
+		MessageQueue:   messageQueue,
+		MaxImages:      maxImages,
 	}
 }
 
@@ -69,17 +94,39 @@ func (s *Service) GetPlant(ctx context.Context, id string) (*Plant, error) {
 	return s.Store.GetPlant(ctx, id)
 }
 
-func (s *Service) GetPlantsByUserId(ctx context.Context, id string) ([]Plant, error) {
+func (s *Service) GetPlantsByUserId(ctx context.Context, id string, page pagination.Params) (pagination.Page[Plant], error) {
 	tag := "plant.GetPlantsByUserId"
-	pl, err := s.Store.GetPlantsByUserId(ctx, id)
+	pl, err := s.Store.GetPlantsByUserId(ctx, id, page)
 	if err != nil {
-		return nil, fmt.Errorf("Store.GetPlantsByUser in %s failed for %v", tag, err)
+		return pagination.Page[Plant]{}, fmt.Errorf("Store.GetPlantsByUser in %s failed for %v", tag, err)
 	}
 	return pl, nil
 }
 
 func (s *Service) UpdatePlant(ctx context.Context, id string, updatedPlant Plant, imagesToDelete []string) (*Plant, error) {
-	return s.Store.UpdatePlant(ctx, id, updatedPlant)
+	current, err := s.Store.GetPlant(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetPlant in plant.UpdatePlant failed for %w", err)
+	}
+	deleted := map[string]bool{}
+	for _, image := range imagesToDelete {
+		deleted[image] = true
+	}
+	var remaining []string
+	for _, image := range current.Images {
+		if !deleted[image] {
+			remaining = append(remaining, image)
+		}
+	}
+	if err := s.checkImageLimit(append(remaining, updatedPlant.Images...)); err != nil {
+		return nil, err
+	}
+	updatedPlant.SearchTerms = GenerateSearchTerms(updatedPlant)
+	if _, err := s.Store.UpdatePlant(ctx, id, updatedPlant, imagesToDelete, s.MaxImages); err != nil {
+		return nil, err
+	}
+	// Read back so the caller gets the new last_update_date and images
+	return s.GetPlant(ctx, id)
 }
 
 func (s *Service) DeletePlant(ctx context.Context, id string) error {
@@ -89,34 +136,107 @@ func (s *Service) DeletePlant(ctx context.Context, id string) error {
 
 func (s *Service) AddPlant(ctx context.Context, newPlant Plant, images []string) (*Plant, error) {
 	log.Info("attempting to add a new plant")
+	if err := s.checkImageLimit(images); err != nil {
+		return nil, err
+	}
+	newPlant.SearchTerms = GenerateSearchTerms(newPlant)
 	return s.Store.AddPlant(ctx, newPlant, images)
 }
 
-func (s *Service) AddPlantImage(ctx context.Context, uri string) (string, error) {
+// AddPlantImage - stores every variant of an image that is not attached to a
+// plant yet, its Url can later be passed to AddPlant
+func (s *Service) AddPlantImage(ctx context.Context, uri string) (*ImageUrls, error) {
 	tag := "plant.AddPlantImage"
-	resultUris, err := s.BlobStore.UploadToBlobStore([]string{uri}, ctx)
+	urls, err := s.uploadImage(ctx, uri)
 	if err != nil {
-		return "", fmt.Errorf("blob.UploadToBlobStore in %s failed for %v", tag, err)
+		return nil, fmt.Errorf("uploadImage in %s failed for %v", tag, err)
 	}
-	resultUri := resultUris[0]
-	return resultUri, nil
+	return urls, nil
 }
 
-func (s *Service) AddPlantImageWithId(ctx context.Context, plantId string, uri string) (*Plant, string, error) {
+func (s *Service) AddPlantImageWithId(ctx context.Context, plantId string, uri string) (*Plant, *ImageUrls, error) {
 	tag := "plant.AddImageToPlant"
-	resultUris, err := s.BlobStore.UploadToBlobStore([]string{uri}, ctx)
+	current, err := s.Store.GetPlant(ctx, plantId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("store.GetPlant in %s failed for %w", tag, err)
+	}
+	// Checked before processing so a plant that is full costs no uploads, the
+	// store checks again as it adds the image
+	if len(current.Images) >= s.MaxImages {
+		return nil, nil, errs.BadRequestError{Message: fmt.Sprintf("a plant may have at most %d images", s.MaxImages)}
+	}
+	urls, err := s.uploadImage(ctx, uri)
 	if err != nil {
-		return nil, "", fmt.Errorf("blob.UploadToBlobStore in %s failed for %v", tag, err)
+		return nil, nil, fmt.Errorf("uploadImage in %s failed for %v", tag, err)
 	}
-	resultUri := resultUris[0]
-	if _, err := s.Store.AddPlantImageWithId(ctx, plantId, resultUri); err != nil {
-		return nil, "", fmt.Errorf("store.AddImageToPlant in %s failed for %v", tag, err)
+	if _, err := s.Store.AddPlantImageWithId(ctx, plantId, urls.Url, s.MaxImages); err != nil {
+		return nil, nil, fmt.Errorf("store.AddImageToPlant in %s failed for %w", tag, err)
 	}
 	p, err := s.Store.GetPlant(ctx, plantId)
 	if err != nil {
-		return nil, "", fmt.Errorf("store.GetPlant in %s failed for %v", tag, err)
+		return nil, nil, fmt.Errorf("store.GetPlant in %s failed for %v", tag, err)
+	}
+	return p, urls, nil
+}
+
+func (s *Service) uploadImage(ctx context.Context, uri string) (*ImageUrls, error) {
+	urls, err := s.ImageProcessor.UploadVariants(ctx, uri, s.BlobStore)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Store.AddImageVariants(ctx, *urls); err != nil {
+		return nil, fmt.Errorf("store.AddImageVariants failed for %v", err)
+	}
+	return urls, nil
+}
+
+// SetPrimaryPlantImage - moves the image to the front, the rest keep their order
+func (s *Service) SetPrimaryPlantImage(ctx context.Context, plantId string, image string) (*Plant, error) {
+	tag := "plant.SetPrimaryPlantImage"
+	p, err := s.Store.GetPlant(ctx, plantId)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetPlant in %s failed for %w", tag, err)
+	}
+	images := []string{image}
+	found := false
+	for _, im := range p.Images {
+		if im == image && !found {
+			found = true
+			continue
+		}
+		images = append(images, im)
+	}
+	if !found {
+		return nil, errs.BadRequestError{Message: "image does not belong to the plant"}
+	}
+	return s.ReorderPlantImages(ctx, plantId, images)
+}
+
+// ReorderPlantImages - images must list every image of the plant once, the
+// first becomes the primary image
+func (s *Service) ReorderPlantImages(ctx context.Context, plantId string, images []string) (*Plant, error) {
+	tag := "plant.ReorderPlantImages"
+	if err := s.Store.ReorderPlantImages(ctx, plantId, images); err != nil {
+		return nil, fmt.Errorf("Store.ReorderPlantImages in %s failed for %w", tag, err)
+	}
+	p, err := s.Store.GetPlant(ctx, plantId)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetPlant in %s failed for %w", tag, err)
+	}
+	return p, nil
+}
+
+// checkImageLimit - images is every image the plant would have after a write,
+// an image listed twice is only stored once
+func (s *Service) checkImageLimit(images []string) error {
+	unique := map[string]bool{}
+	for _, image := range images {
+		unique[image] = true
+	}
+	if len(unique) > s.MaxImages {
+		return errs.BadRequestError{Message: fmt.Sprintf("a plant may have at most %d images", s.MaxImages)}
 	}
-	return p, resultUri, nil
+	return nil
 }
 
 func (s *Service) DeletePlantImage(ctx context.Context, plantId string, uri string) error {
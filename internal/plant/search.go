@@ -0,0 +1,67 @@
+package plant
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"strings"
+	"unicode"
+)
+
+const minSearchTermLength = 2
+
+// SearchQuery - Text is matched against the common name, scientific name and
+// search terms of every plant. OwnerId and Toxicity narrow the results when set
+type SearchQuery struct {
+	Text     string
+	OwnerId  string
+	Toxicity string
+	Limit    int
+}
+
+// SearchResult - a plant along with how well it matched the query
+type SearchResult struct {
+	Plant
+	Rank float32 `json:"rank"`
+}
+
+// GenerateSearchTerms - lower cased words from the plant's names, plus any terms
+// the user supplied, without duplicates
+func GenerateSearchTerms(p Plant) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	add := func(term string) {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if len([]rune(term)) < minSearchTermLength || seen[term] {
+			return
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+	splitWords := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-' && r != '\''
+	}
+	for _, name := range []string{p.CommonName, p.ScientificName} {
+		for _, word := range strings.FieldsFunc(name, splitWords) {
+			add(word)
+		}
+	}
+	for _, term := range p.SearchTerms {
+		add(term)
+	}
+	return terms
+}
+
+// SearchPlants - plants matching the query, best match first
+func (s *Service) SearchPlants(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	tag := "plant.SearchPlants"
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, nectar_errors.BadRequestError{Message: "search text is required"}
+	}
+	results, err := s.Store.SearchPlants(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("Store.SearchPlants in %s failed for %v", tag, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,215 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	ReasonSoftDeleted   = "soft_deleted"
+	ReasonUnreferenced  = "unreferenced"
+	ReasonExpiredUpload = "expired_upload"
+
+	defaultRetention   = 30 * 24 * time.Hour
+	defaultGracePeriod = 24 * time.Hour
+	defaultInterval    = 24 * time.Hour
+	defaultBatchSize   = 500
+)
+
+// Image - an image no live plant or profile uses any more, along with every
+// variant generated for it
+type Image struct {
+	Url    string
+	Reason string
+	Urls   imaging.ImageUrls
+}
+
+// Removal - one image or expired upload removed by a run, or that would have
+// been on a dry run
+type Removal struct {
+	Image  string   `json:"image"`
+	Reason string   `json:"reason"`
+	Keys   []string `json:"keys"`
+}
+
+type Failure struct {
+	Image string `json:"image"`
+	Error string `json:"error"`
+}
+
+// Report - what a single run removed. Failed images keep their rows and are
+// retried on the next run
+type Report struct {
+	DryRun       bool      `json:"dryRun"`
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	Removed      []Removal `json:"removed"`
+	Failed       []Failure `json:"failed"`
+	BlobsDeleted int       `json:"blobsDeleted"`
+	// SessionRevocationsPurged - logged out tokens that have since expired, so
+	// no longer need remembering
+	SessionRevocationsPurged int64 `json:"sessionRevocationsPurged"`
+}
+
+// Store - images are reapable once every plant_images row using them was soft
+// deleted, or their plant was, before retentionCutoff and no active profile
+// uses them. Images never attached to anything are reapable once processed
+// before graceCutoff
+type Store interface {
+	GetReapableImages(ctx context.Context, retentionCutoff time.Time, graceCutoff time.Time, limit int) ([]Image, error)
+	DeleteImageRecords(ctx context.Context, image string) error
+	GetExpiredPendingUploads(ctx context.Context, cutoff time.Time, limit int) ([]upload.Pending, error)
+	DeletePendingUpload(ctx context.Context, id string) error
+	PurgeExpiredSessionRevocations(ctx context.Context) (int64, error)
+}
+
+type BlobStore interface {
+	KeyForUrl(rawUrl string) (string, bool)
+	Delete(ctx context.Context, key string) error
+}
+
+type Service struct {
+	Store       Store
+	BlobStore   BlobStore
+	Retention   time.Duration
+	GracePeriod time.Duration
+	Interval    time.Duration
+	BatchSize   int
+}
+
+// NewService - returns a pointer to a new reaper service. BLOB_REAPER_RETENTION
+// (default 30 days) is how long soft deleted images are kept,
+// BLOB_REAPER_GRACE_PERIOD (default 24h) how long an unattached image is given
+// to be attached, BLOB_REAPER_INTERVAL (default 24h) how often Start runs and
+// BLOB_REAPER_BATCH_SIZE (default 500) how many images one run removes
+func NewService(store Store, blobStore BlobStore) *Service {
+	batchSize, err := strconv.Atoi(os.Getenv("BLOB_REAPER_BATCH_SIZE"))
+	if err != nil || batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Service{
+		Store:       store,
+		BlobStore:   blobStore,
+		Retention:   envDuration("BLOB_REAPER_RETENTION", defaultRetention),
+		GracePeriod: envDuration("BLOB_REAPER_GRACE_PERIOD", defaultGracePeriod),
+		Interval:    envDuration("BLOB_REAPER_INTERVAL", defaultInterval),
+		BatchSize:   batchSize,
+	}
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Start - reaps on every tick until the context is cancelled
+func (s *Service) Start(ctx context.Context) {
+	log.Infof("starting blob reaper, interval: %s, retention: %s", s.Interval, s.Retention)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Reap(ctx, false); err != nil {
+			log.Errorf("blob reaper run failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("stopped blob reaper")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reap - deletes the blobs of reapable images and expired uploads, then their
+// rows, and purges expired session revocations. A dry run only reports what
+// would be removed
+func (s *Service) Reap(ctx context.Context, dryRun bool) (*Report, error) {
+	tag := "reaper.Reap"
+	now := time.Now()
+	report := &Report{DryRun: dryRun, StartedAt: now, Removed: []Removal{}, Failed: []Failure{}}
+
+	images, err := s.Store.GetReapableImages(ctx, now.Add(-s.Retention), now.Add(-s.GracePeriod), s.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetReapableImages in %s failed for %v", tag, err)
+	}
+	for _, image := range images {
+		removal := Removal{Image: image.Url, Reason: image.Reason, Keys: s.keys(image.Urls)}
+		s.remove(ctx, report, removal, func() error {
+			return s.Store.DeleteImageRecords(ctx, image.Url)
+		})
+	}
+
+	uploads, err := s.Store.GetExpiredPendingUploads(ctx, now.Add(-s.GracePeriod), s.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetExpiredPendingUploads in %s failed for %v", tag, err)
+	}
+	for _, pending := range uploads {
+		removal := Removal{Image: pending.ObjectKey, Reason: ReasonExpiredUpload, Keys: []string{pending.ObjectKey}}
+		s.remove(ctx, report, removal, func() error {
+			return s.Store.DeletePendingUpload(ctx, pending.Id)
+		})
+	}
+
+	if !dryRun {
+		purged, err := s.Store.PurgeExpiredSessionRevocations(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Store.PurgeExpiredSessionRevocations in %s failed for %v", tag, err)
+		}
+		report.SessionRevocationsPurged = purged
+	}
+
+	report.FinishedAt = time.Now()
+	log.Infof("blob reaper removed %d images and %d blobs, %d failed, dry run: %t",
+		len(report.Removed), report.BlobsDeleted, len(report.Failed), dryRun)
+	return report, nil
+}
+
+// remove - rows are only deleted once every blob is, so a failed run leaves
+// the image to be found again by the next one
+func (s *Service) remove(ctx context.Context, report *Report, removal Removal, deleteRecords func() error) {
+	if report.DryRun {
+		report.Removed = append(report.Removed, removal)
+		return
+	}
+	for _, key := range removal.Keys {
+		if err := s.BlobStore.Delete(ctx, key); err != nil {
+			report.Failed = append(report.Failed, Failure{Image: removal.Image, Error: err.Error()})
+			return
+		}
+		report.BlobsDeleted++
+	}
+	if err := deleteRecords(); err != nil {
+		report.Failed = append(report.Failed, Failure{Image: removal.Image, Error: err.Error()})
+		return
+	}
+	report.Removed = append(report.Removed, removal)
+}
+
+// keys - the blob keys of every variant of an image. Urls the blob store does
+// not own, e.g. images added by uri, are skipped
+func (s *Service) keys(urls imaging.ImageUrls) []string {
+	candidates := []string{urls.Url, urls.ThumbnailUrl, urls.MediumUrl}
+	if urls.WebP != nil {
+		candidates = append(candidates, urls.WebP.Url, urls.WebP.ThumbnailUrl, urls.WebP.MediumUrl)
+	}
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, candidate := range candidates {
+		key, ok := s.BlobStore.KeyForUrl(candidate)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
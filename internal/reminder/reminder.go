@@ -0,0 +1,151 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/events"
+	"os"
+	"time"
+)
+
+const (
+	CareTypeWatering    = "watering"
+	CareTypeFertilizing = "fertilizing"
+
+	defaultInterval = time.Hour
+)
+
+// Item - a single overdue kind of care for a single plant
+type Item = events.CareReminderItem
+
+// Store - records which reminders have been claimed so a user is only notified
+// once for each overdue due date, however many workers are scanning
+type Store interface {
+	ClaimCareReminder(ctx context.Context, plantId string, careType string, dueDate time.Time) (claimed bool, err error)
+	ReleaseCareReminder(ctx context.Context, plantId string, careType string, dueDate time.Time) error
+}
+
+type CareService interface {
+	GetOverdueCareSchedules(ctx context.Context) ([]care.Schedule, error)
+}
+
+type MessageQueue interface {
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
+}
+
+type Service struct {
+	Store        Store
+	CareService  CareService
+	MessageQueue MessageQueue
+	Topic        string
+	Interval     time.Duration
+}
+
+// NewService - returns a pointer to a new reminder service. The topic and scan
+// interval are read from CARE_REMINDER_TOPIC and CARE_REMINDER_INTERVAL
+func NewService(store Store, careService CareService, messageQueue MessageQueue) *Service {
+	topic := os.Getenv("CARE_REMINDER_TOPIC")
+	if topic == "" {
+		topic = events.TopicCareReminders
+	}
+	interval, err := time.ParseDuration(os.Getenv("CARE_REMINDER_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = defaultInterval
+	}
+	return &Service{
+		Store:        store,
+		CareService:  careService,
+		MessageQueue: messageQueue,
+		Topic:        topic,
+		Interval:     interval,
+	}
+}
+
+// Start - scans for overdue care on every tick until the context is cancelled
+func (s *Service) Start(ctx context.Context) {
+	log.Infof("starting care reminder worker, interval: %s, topic: %s", s.Interval, s.Topic)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.SendDueCareReminders(ctx); err != nil {
+			log.Errorf("care reminder scan failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("stopped care reminder worker")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDueCareReminders - publishes one event per user containing every overdue
+// item this scan claimed. An item another worker claimed first is left to it.
+// The items of an event that could not be published, or every claimed item
+// when claiming fails part way through the scan, are released for the next scan
+func (s *Service) SendDueCareReminders(ctx context.Context) error {
+	tag := "reminder.SendDueCareReminders"
+	schedules, err := s.CareService.GetOverdueCareSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("CareService.GetOverdueCareSchedules in %s failed for %v", tag, err)
+	}
+	itemsByUser := map[string][]Item{}
+	for _, sc := range schedules {
+		for _, item := range overdueItems(sc) {
+			claimed, err := s.Store.ClaimCareReminder(ctx, item.PlantId, item.CareType, item.DueDate)
+			if err != nil {
+				// Nothing is published, so nothing claimed so far may stay claimed
+				for _, claimedItems := range itemsByUser {
+					s.release(ctx, claimedItems)
+				}
+				return fmt.Errorf("Store.ClaimCareReminder in %s failed for %v", tag, err)
+			}
+			if claimed {
+				itemsByUser[sc.UserId] = append(itemsByUser[sc.UserId], item)
+			}
+		}
+	}
+	published := 0
+	for userId, items := range itemsByUser {
+		reminder := events.CareReminder{UserId: userId, Items: items}
+		if err := events.PublishTo(ctx, s.MessageQueue, s.Topic, reminder); err != nil {
+			log.Errorf("failed to publish care reminder for user %s in %s: %v", userId, tag, err)
+			s.release(ctx, items)
+			continue
+		}
+		published++
+	}
+	log.Infof("published care reminders to %d of %d users", published, len(itemsByUser))
+	return nil
+}
+
+func (s *Service) release(ctx context.Context, items []Item) {
+	for _, item := range items {
+		if err := s.Store.ReleaseCareReminder(ctx, item.PlantId, item.CareType, item.DueDate); err != nil {
+			log.Errorf("Store.ReleaseCareReminder in reminder.release failed for %v", err)
+		}
+	}
+}
+
+func overdueItems(sc care.Schedule) []Item {
+	var items []Item
+	if sc.IsWateringOverdue && sc.NextWateringDate != nil {
+		items = append(items, Item{
+			PlantId:   sc.PlantId,
+			PlantName: sc.PlantName,
+			CareType:  CareTypeWatering,
+			DueDate:   *sc.NextWateringDate,
+		})
+	}
+	if sc.IsFertilizingOverdue && sc.NextFertilizingDate != nil {
+		items = append(items, Item{
+			PlantId:   sc.PlantId,
+			PlantName: sc.PlantName,
+			CareType:  CareTypeFertilizing,
+			DueDate:   *sc.NextFertilizingDate,
+		})
+	}
+	return items
+}
@@ -0,0 +1,68 @@
+//go:build integration
+
+package reminder
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	claimed  map[string]bool
+	failOn   string
+	released []string
+}
+
+func (s *fakeStore) ClaimCareReminder(ctx context.Context, plantId string, careType string, dueDate time.Time) (bool, error) {
+	if plantId == s.failOn {
+		return false, errors.New("db down")
+	}
+	s.claimed[plantId+careType] = true
+	return true, nil
+}
+
+func (s *fakeStore) ReleaseCareReminder(ctx context.Context, plantId string, careType string, dueDate time.Time) error {
+	delete(s.claimed, plantId+careType)
+	s.released = append(s.released, plantId+careType)
+	return nil
+}
+
+type fakeCareService struct {
+	schedules []care.Schedule
+}
+
+func (f fakeCareService) GetOverdueCareSchedules(ctx context.Context) ([]care.Schedule, error) {
+	return f.schedules, nil
+}
+
+type countingQueue struct {
+	pushed int
+}
+
+func (q *countingQueue) PushToQueue(ctx context.Context, topic string, key string, message []byte) error {
+	q.pushed++
+	return nil
+}
+
+func TestSendDueCareReminders(t *testing.T) {
+	t.Run("test a failed claim releases what the scan already claimed", func(t *testing.T) {
+		due := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+		schedules := []care.Schedule{
+			{PlantId: "first-plant", UserId: "some-user", IsWateringOverdue: true, NextWateringDate: &due},
+			{PlantId: "second-plant", UserId: "some-user", IsWateringOverdue: true, NextWateringDate: &due},
+		}
+		store := &fakeStore{claimed: map[string]bool{}, failOn: "second-plant"}
+		queue := &countingQueue{}
+		s := &Service{Store: store, CareService: fakeCareService{schedules: schedules}, MessageQueue: queue, Topic: "care-reminders"}
+
+		err := s.SendDueCareReminders(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, 0, queue.pushed)
+		assert.Empty(t, store.claimed)
+		assert.Equal(t, []string{"first-plant" + CareTypeWatering}, store.released)
+	})
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/admin"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/reaper"
+	"net/http"
+	"strconv"
+)
+
+type AdminService interface {
+	ListAccounts(ctx context.Context) ([]admin.Account, error)
+	SuspendUser(ctx context.Context, callerRole string, userId string) error
+	ReactivateUser(ctx context.Context, callerRole string, userId string) error
+	SetUserRole(ctx context.Context, userId string, role string) error
+	RemovePlant(ctx context.Context, plantId string) error
+	RemovePlantImage(ctx context.Context, plantId string, uri string) error
+	ReapBlobs(ctx context.Context, dryRun bool) (*reaper.Report, error)
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type RemovePlantImageRequest struct {
+	Uri string `json:"uri" validate:"required"`
+}
+
+// RequireRole - only lets callers with one of the given roles through. Must be
+// wrapped by JWTAuth so the caller's role is known
+func (h *Handler) RequireRole(original func(w http.ResponseWriter, r *http.Request), roles ...string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		callerRole, _ := r.Context().Value("role").(string)
+		for _, role := range roles {
+			if callerRole == role {
+				original(w, r)
+				return
+			}
+		}
+		log.Info(fmt.Sprintf("unsuccessful request, reason: role %q not permitted,status code: %d", callerRole, http.StatusForbidden))
+		w.WriteHeader(http.StatusForbidden)
+		h.encodeJsonResponse(&w, Response{Message: "You do not have permission to access this resource"})
+	}
+}
+
+func (h *Handler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.AdminService.ListAccounts(r.Context())
+	if err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: accounts})
+}
+
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	callerRole, _ := r.Context().Value("role").(string)
+	if err := h.AdminService.SuspendUser(r.Context(), callerRole, id); err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "user successfully suspended"})
+}
+
+func (h *Handler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	callerRole, _ := r.Context().Value("role").(string)
+	if err := h.AdminService.ReactivateUser(r.Context(), callerRole, id); err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "user successfully reactivated"})
+}
+
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	var roleRequest SetUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&roleRequest); err != nil {
+		h.writeAdminError(w, errs.BadRequestError{Message: err.Error()})
+		return
+	}
+	if err := validator.New().Struct(roleRequest); err != nil {
+		h.writeAdminError(w, errs.BadRequestError{Message: err.Error()})
+		return
+	}
+	if err := h.AdminService.SetUserRole(r.Context(), id, roleRequest.Role); err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "user role successfully updated"})
+}
+
+func (h *Handler) RemovePlant(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := h.AdminService.RemovePlant(r.Context(), id); err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "plant successfully removed"})
+}
+
+func (h *Handler) RemovePlantImage(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	var imageRequest RemovePlantImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&imageRequest); err != nil {
+		h.writeAdminError(w, errs.BadRequestError{Message: err.Error()})
+		return
+	}
+	if err := validator.New().Struct(imageRequest); err != nil {
+		h.writeAdminError(w, errs.BadRequestError{Message: err.Error()})
+		return
+	}
+	if err := h.AdminService.RemovePlantImage(r.Context(), id, imageRequest.Uri); err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "image successfully removed"})
+}
+
+// ReapBlobs - removes orphaned and long soft deleted blobs now and reports what
+// was removed. ?dryRun=true only reports
+func (h *Handler) ReapBlobs(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if rawDryRun := r.URL.Query().Get("dryRun"); rawDryRun != "" {
+		parsed, err := strconv.ParseBool(rawDryRun)
+		if err != nil {
+			h.writeAdminError(w, errs.BadRequestError{Message: "dryRun must be true or false"})
+			return
+		}
+		dryRun = parsed
+	}
+	report, err := h.AdminService.ReapBlobs(r.Context(), dryRun)
+	if err != nil {
+		h.writeAdminError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: report})
+}
+
+func (h *Handler) writeAdminError(w http.ResponseWriter, err error) {
+	var forbiddenError errs.ForbiddenError
+	var noEntityError *errs.NoEntityError
+	var badRequestError errs.BadRequestError
+	switch {
+	case errors.As(err, &forbiddenError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusForbidden))
+		w.WriteHeader(http.StatusForbidden)
+		h.encodeJsonResponse(&w, Response{Message: "You do not have permission to access this resource"})
+	case errors.As(err, &noEntityError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+		w.WriteHeader(http.StatusNotFound)
+		h.encodeJsonResponse(&w, Response{Message: noEntityError.Message})
+	case errors.As(err, &badRequestError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: badRequestError.Message})
+	default:
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+	}
+}
@@ -2,31 +2,60 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/auth"
+	"io"
 	"net/http"
 	"strings"
 )
 
 type AuthService interface {
 	VerifyIDToken(ctx context.Context, token string) (*auth.AuthToken, error)
+	Login(ctx context.Context, email string, password string) (*auth.Session, error)
+	Refresh(ctx context.Context, refreshToken string) (*auth.Session, error)
+	Logout(ctx context.Context, sessionToken string, refreshToken string) error
+	LogoutEverywhere(ctx context.Context, userId string) error
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// bearerToken - returns the token-string from an "Authorization: Bearer token-string" header
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header["Authorization"]
+	if authHeader == nil {
+		return "", errors.New("no authorization header")
+	}
+	authHeaderParts := strings.Split(authHeader[0], " ")
+	if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
+		return "", errors.New("invalid authorization header format")
+	}
+	return authHeaderParts[1], nil
 }
 
 func (h *Handler) JWTAuth(original func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header["Authorization"]
-		if authHeader == nil {
-			http.Error(w, "not authorized", http.StatusUnauthorized)
-			return
-		}
-		// Bearer token-string
-		authHeaderParts := strings.Split(authHeader[0], " ")
-		if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
-			log.Error("invalid authorization header format")
+		sessionToken, err := bearerToken(r)
+		if err != nil {
+			log.Error(err)
 			http.Error(w, "not authorized", http.StatusUnauthorized)
 			return
 		}
-		token, err := h.AuthService.VerifyIDToken(r.Context(), authHeaderParts[1])
+		token, err := h.AuthService.VerifyIDToken(r.Context(), sessionToken)
 		if err != nil {
 			log.Errorf("unable to verify session token: %v", err)
 			http.Error(w, "not authorized", http.StatusUnauthorized)
@@ -34,7 +63,134 @@ func (h *Handler) JWTAuth(original func(w http.ResponseWriter, r *http.Request))
 		}
 		userId := token.Claims["user_id"]
 		newCtx := context.WithValue(r.Context(), "userId", userId)
+		newCtx = context.WithValue(newCtx, "role", token.Claims[auth.RoleClaim])
 		reqWithContext := r.WithContext(newCtx)
 		original(w, reqWithContext)
 	}
 }
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var loginRequest LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&loginRequest); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include an email and password"})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(loginRequest); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include an email and password"})
+		return
+	}
+	session, err := h.AuthService.Login(r.Context(), loginRequest.Email, loginRequest.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.InvalidCredentialsError):
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusUnauthorized))
+			w.WriteHeader(http.StatusUnauthorized)
+			h.encodeJsonResponse(&w, Response{Message: "Invalid email or password"})
+			return
+		case errors.Is(err, auth.LoginNotSupportedError):
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotImplemented))
+			w.WriteHeader(http.StatusNotImplemented)
+			h.encodeJsonResponse(&w, Response{Message: "Password login is not available on this server"})
+			return
+		default:
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+			w.WriteHeader(http.StatusInternalServerError)
+			h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+			return
+		}
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: session})
+}
+
+func (h *Handler) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	var refreshRequest RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&refreshRequest); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a refresh token"})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(refreshRequest); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a refresh token"})
+		return
+	}
+	session, err := h.AuthService.Refresh(r.Context(), refreshRequest.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.InvalidRefreshTokenError):
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusUnauthorized))
+			w.WriteHeader(http.StatusUnauthorized)
+			h.encodeJsonResponse(&w, Response{Message: "Invalid refresh token, please log in again"})
+			return
+		case errors.Is(err, auth.RefreshNotSupportedError):
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotImplemented))
+			w.WriteHeader(http.StatusNotImplemented)
+			h.encodeJsonResponse(&w, Response{Message: "Refresh tokens are not available on this server"})
+			return
+		default:
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+			w.WriteHeader(http.StatusInternalServerError)
+			h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+			return
+		}
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: session})
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionToken, err := bearerToken(r)
+	if err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusUnauthorized))
+		w.WriteHeader(http.StatusUnauthorized)
+		h.encodeJsonResponse(&w, Response{Message: "not authorized"})
+		return
+	}
+	// The body is optional, a client without a refresh token can send nothing
+	var logoutRequest LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&logoutRequest); err != nil && !errors.Is(err, io.EOF) {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request body"})
+		return
+	}
+	if err := h.AuthService.Logout(r.Context(), sessionToken, logoutRequest.RefreshToken); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "successfully logged out"})
+}
+
+func (h *Handler) LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("userId").(string)
+	if userId == "" {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", "no user id in context", http.StatusUnauthorized))
+		w.WriteHeader(http.StatusUnauthorized)
+		h.encodeJsonResponse(&w, Response{Message: "not authorized"})
+		return
+	}
+	if err := h.AuthService.LogoutEverywhere(r.Context(), userId); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "successfully logged out of all sessions"})
+}
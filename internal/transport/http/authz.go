@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"net/http"
+)
+
+type AuthzService interface {
+	Authorize(ctx context.Context, userId string, resource authz.Resource, action authz.Action) error
+}
+
+// Authorize - checks the caller may perform the action on the resource identified
+// by the {id} route variable. Must be wrapped by JWTAuth so the caller is known
+func (h *Handler) Authorize(kind authz.Kind, action authz.Action, original func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := h.idFromPath(w, r)
+		if !ok {
+			return
+		}
+		if !h.authorizeRequest(w, r, authz.Resource{Kind: kind, Id: id}, action) {
+			return
+		}
+		original(w, r)
+	}
+}
+
+// authorizeRequest - hook for handlers whose resource id is in the request body
+// rather than the path. Writes the error response and returns false when denied
+func (h *Handler) authorizeRequest(w http.ResponseWriter, r *http.Request, resource authz.Resource, action authz.Action) bool {
+	userId, _ := r.Context().Value("userId").(string)
+	err := h.AuthzService.Authorize(r.Context(), userId, resource, action)
+	if err == nil {
+		return true
+	}
+	var forbiddenError errs.ForbiddenError
+	var noEntityError *errs.NoEntityError
+	switch {
+	case errors.As(err, &forbiddenError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusForbidden))
+		w.WriteHeader(http.StatusForbidden)
+		h.encodeJsonResponse(&w, Response{Message: "You do not have permission to access this resource"})
+	case errors.As(err, &noEntityError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+		w.WriteHeader(http.StatusNotFound)
+		h.encodeJsonResponse(&w, Response{Message: fmt.Sprintf("No %s found with id %s", resource.Kind, resource.Id)})
+	default:
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+	}
+	return false
+}
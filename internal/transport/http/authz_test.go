@@ -0,0 +1,55 @@
+//go:build integration
+
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeAuthzService struct {
+	err error
+}
+
+func (f fakeAuthzService) Authorize(ctx context.Context, userId string, resource authz.Resource, action authz.Action) error {
+	return f.err
+}
+
+func TestAuthorizeMiddleware(t *testing.T) {
+	validId := "7f1f2d1e-8c1b-4a4e-9d55-6a3f0b0e6c11"
+	tests := []struct {
+		name       string
+		id         string
+		authzErr   error
+		statusCode int
+	}{
+		{name: "allowed", id: validId, statusCode: http.StatusOK},
+		{name: "forbidden", id: validId, authzErr: errs.ForbiddenError{Message: "nope"}, statusCode: http.StatusForbidden},
+		{name: "not found", id: validId, authzErr: &errs.NoEntityError{Message: "gone"}, statusCode: http.StatusNotFound},
+		{name: "store failure", id: validId, authzErr: errors.New("db down"), statusCode: http.StatusInternalServerError},
+		{name: "invalid id", id: "not-a-uuid", statusCode: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{AuthzService: fakeAuthzService{err: tc.authzErr}}
+			next := func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/plant/"+tc.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+			req = req.WithContext(context.WithValue(req.Context(), "userId", "caller-id"))
+			rec := httptest.NewRecorder()
+
+			h.Authorize(authz.KindPlant, authz.ActionWrite, next)(rec, req)
+
+			assert.Equal(t, tc.statusCode, rec.Code)
+		})
+	}
+}
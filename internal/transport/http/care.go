@@ -3,22 +3,30 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/care"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
 	"net/http"
 	"time"
 )
 
 type CareService interface {
-	GetAllUsersCareLogs(ctx context.Context, userId string) ([]care.LogEntry, error)
-	GetCareLogsEntries(ctx context.Context, plantId string) ([]care.LogEntry, error)
+	GetAllUsersCareLogs(ctx context.Context, userId string, page pagination.Params) (pagination.Page[care.LogEntry], error)
+	GetCareLogsEntries(ctx context.Context, plantId string, page pagination.Params) (pagination.Page[care.LogEntry], error)
 	AddCareLogEntry(ctx context.Context, entry care.LogEntry) (*care.LogEntry, error)
 	DeleteCareLogEntry(ctx context.Context, logEntryId string) error
 	UpdateCareLogEntry(ctx context.Context, logEntryId string, entry care.LogEntry) (*care.LogEntry, error)
+	GetCareSchedule(ctx context.Context, plantId string) (*care.Schedule, error)
+	GetCareSchedulesByUserId(ctx context.Context, userId string) ([]care.Schedule, error)
+	SetCareSchedule(ctx context.Context, schedule care.Schedule) (*care.Schedule, error)
+	DeleteCareSchedule(ctx context.Context, plantId string) error
 }
 
 type CareLogEntryRequest struct {
@@ -29,6 +37,12 @@ type CareLogEntryRequest struct {
 	WasFertilized bool   `json:"wasFertilized"`
 }
 
+type CareScheduleRequest struct {
+	PlantId                 string `json:"plantId" validate:"required,uuid"`
+	WateringIntervalDays    int    `json:"wateringIntervalDays" validate:"gte=0"`
+	FertilizingIntervalDays int    `json:"fertilizingIntervalDays" validate:"gte=0"`
+}
+
 func convertRequestToLogEntry(request CareLogEntryRequest) care.LogEntry {
 	return care.LogEntry{
 		PlantId:       request.PlantId,
@@ -48,16 +62,18 @@ func (h *Handler) GetAllUsersCareLogs(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include care log id"})
 		return
 	}
-	entries, err := h.CareService.GetAllUsersCareLogs(r.Context(), id)
+	page, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+	entries, err := h.CareService.GetAllUsersCareLogs(r.Context(), id, page)
 	if err != nil {
 		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
 		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
 		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, Response{Content: entries})
+	h.writeConditional(w, r, Response{Content: entries.Items, NextCursor: entries.NextCursor})
 	return
 }
 
@@ -70,16 +86,18 @@ func (h *Handler) GetCareLogsEntries(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include care log id"})
 		return
 	}
-	entries, err := h.CareService.GetCareLogsEntries(r.Context(), id)
+	page, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+	entries, err := h.CareService.GetCareLogsEntries(r.Context(), id, page)
 	if err != nil {
 		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
 		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
 		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, Response{Content: entries})
+	h.writeConditional(w, r, Response{Content: entries.Items, NextCursor: entries.NextCursor})
 	return
 }
 
@@ -97,6 +115,15 @@ func (h *Handler) AddCareLogEntry(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, Response{Message: "Invalid Date"})
 		return
 	}
+	if _, err := uuid.Parse(logEntryRequest.PlantId); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a plant id"})
+		return
+	}
+	if !h.authorizeRequest(w, r, authz.Resource{Kind: authz.KindPlant, Id: logEntryRequest.PlantId}, authz.ActionWrite) {
+		return
+	}
 	logEntry := convertRequestToLogEntry(logEntryRequest)
 	insertedEntry, err := h.CareService.AddCareLogEntry(r.Context(), logEntry)
 	if err != nil {
@@ -105,9 +132,7 @@ func (h *Handler) AddCareLogEntry(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
 		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, Response{Content: insertedEntry})
+	h.writeVersioned(w, r, Response{Content: insertedEntry}, insertedEntry.Id, insertedEntry.UpdatedAt)
 	return
 }
 
@@ -135,17 +160,35 @@ func (h *Handler) UpdateCareLogEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	entry := convertRequestToLogEntry(logEntryRequest)
+	version, ok := h.ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+	entry.UpdatedAt = version
 	updatedEntry, err := h.CareService.UpdateCareLogEntry(r.Context(), id, entry)
 	if err != nil {
+		h.writeCareLogEntryError(w, err)
+		return
+	}
+	h.writeVersioned(w, r, Response{Content: updatedEntry}, updatedEntry.Id, updatedEntry.UpdatedAt)
+	return
+}
+
+func (h *Handler) writeCareLogEntryError(w http.ResponseWriter, err error) {
+	var noEntityError *errs.NoEntityError
+	var preconditionFailedError errs.PreconditionFailedError
+	switch {
+	case errors.As(err, &noEntityError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+		w.WriteHeader(http.StatusNotFound)
+		h.encodeJsonResponse(&w, Response{Message: "No care log entry found"})
+	case errors.As(err, &preconditionFailedError):
+		h.writePreconditionFailed(w, err)
+	default:
 		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
 		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
-		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, Response{Content: updatedEntry})
-	return
 }
 
 func (h *Handler) DeleteCareLogEntry(w http.ResponseWriter, r *http.Request) {
@@ -168,3 +211,111 @@ func (h *Handler) DeleteCareLogEntry(w http.ResponseWriter, r *http.Request) {
 	h.encodeJsonResponse(&w, Response{Content: "entry successfully deleted"})
 	return
 }
+
+func (h *Handler) SetCareSchedule(w http.ResponseWriter, r *http.Request) {
+	var scheduleRequest CareScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&scheduleRequest); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(scheduleRequest); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a plant id and non-negative intervals"})
+		return
+	}
+	if !h.authorizeRequest(w, r, authz.Resource{Kind: authz.KindPlant, Id: scheduleRequest.PlantId}, authz.ActionWrite) {
+		return
+	}
+	schedule := care.Schedule{
+		PlantId:                 scheduleRequest.PlantId,
+		WateringIntervalDays:    scheduleRequest.WateringIntervalDays,
+		FertilizingIntervalDays: scheduleRequest.FertilizingIntervalDays,
+	}
+	savedSchedule, err := h.CareService.SetCareSchedule(r.Context(), schedule)
+	if err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: savedSchedule})
+	return
+}
+
+func (h *Handler) GetCareSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a plant id"})
+		return
+	}
+	schedule, err := h.CareService.GetCareSchedule(r.Context(), id)
+	if err != nil {
+		var noEntityError *errs.NoEntityError
+		if errors.As(err, &noEntityError) {
+			log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+			w.WriteHeader(http.StatusNotFound)
+			h.encodeJsonResponse(&w, Response{Message: "No care schedule has been set for this plant"})
+			return
+		}
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: schedule})
+	return
+}
+
+func (h *Handler) GetCareSchedulesByUserId(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a user id"})
+		return
+	}
+	schedules, err := h.CareService.GetCareSchedulesByUserId(r.Context(), id)
+	if err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: schedules})
+	return
+}
+
+func (h *Handler) DeleteCareSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a plant id"})
+		return
+	}
+	if err := h.CareService.DeleteCareSchedule(r.Context(), id); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: "schedule successfully deleted"})
+	return
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encodeResponse - the same bytes encodeJsonResponse writes, so a response's
+// ETag can be computed before it is sent
+func encodeResponse(res Response) ([]byte, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(res); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// computeETag - a strong ETag, the hash of the exact body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches - whether the If-Match or If-None-Match header names etag. The
+// weak comparison of If-None-Match ignores W/ prefixes, the strong one of
+// If-Match never matches a weak ETag
+func etagMatches(header string, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// versionETag - the ETag of a single plant, user or care log entry, its id, the
+// last_update_date it was read at and the hash of the body. If-Match only
+// compares the last_update_date, the hash changes the ETag when data that has
+// no last_update_date of its own changes, e.g. follower counts or the owner's
+// profile, or when the caller is shown a different representation
+func versionETag(id string, updatedAt time.Time, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%s.%d.%s"`, id, updatedAt.UnixNano(), hex.EncodeToString(sum[:8]))
+}
+
+// writeConditional - writes res with the hash of its body as its ETag, for
+// lists, which have no version of their own
+func (h *Handler) writeConditional(w http.ResponseWriter, r *http.Request, res Response) {
+	body, err := encodeResponse(res)
+	if err != nil {
+		panic(err)
+	}
+	h.writeWithETag(w, r, body, computeETag(body))
+}
+
+// writeVersioned - writes res, the representation of a single resource, with
+// its versionETag. The representation may depend on the caller, so responses
+// vary by Authorization
+func (h *Handler) writeVersioned(w http.ResponseWriter, r *http.Request, res Response, id string, updatedAt time.Time) {
+	body, err := encodeResponse(res)
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Add("Vary", "Authorization")
+	h.writeWithETag(w, r, body, versionETag(id, updatedAt, body))
+}
+
+// writeWithETag - a GET whose If-None-Match already names etag gets a 304
+// without a body
+func (h *Handler) writeWithETag(w http.ResponseWriter, r *http.Request, body []byte, etag string) {
+	w.Header().Set("ETag", etag)
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatches(ifNoneMatch, etag, true) {
+		log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusNotModified))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Errorf("writing the response body failed: %v", err)
+	}
+}
+
+// ifMatchVersion - the last_update_date the If-Match header names for the
+// resource with the given id, which the store's conditional update compares
+// with the stored one. It is zero, and the write unconditional, without the
+// header or with If-Match: *. Writes the 412 and returns false when the header
+// names no version of the resource
+func (h *Handler) ifMatchVersion(w http.ResponseWriter, r *http.Request, id string) (time.Time, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return time.Time{}, true
+	}
+	prefix := `"` + id + "."
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if !strings.HasPrefix(candidate, prefix) || !strings.HasSuffix(candidate, `"`) {
+			continue
+		}
+		version, _, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(candidate, prefix), `"`), ".")
+		nanos, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			continue
+		}
+		return time.Unix(0, nanos).UTC(), true
+	}
+	h.writePreconditionFailed(w, fmt.Errorf("If-Match %s names no version of %s", ifMatch, id))
+	return time.Time{}, false
+}
+
+func (h *Handler) writePreconditionFailed(w http.ResponseWriter, err error) {
+	log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusPreconditionFailed))
+	w.WriteHeader(http.StatusPreconditionFailed)
+	h.encodeJsonResponse(&w, Response{Message: "The resource was changed since it was read, please read it again before updating it"})
+}
@@ -0,0 +1,96 @@
+//go:build integration
+
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	h := &Handler{}
+	res := Response{Content: map[string]string{"plantId": "some-plant"}}
+	first := httptest.NewRecorder()
+	h.writeConditional(first, httptest.NewRequest(http.MethodGet, "/", nil), res)
+	etag := first.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.NotEmpty(t, first.Body.String())
+	assert.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+
+	t.Run("test If-None-Match", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			method      string
+			ifNoneMatch string
+			statusCode  int
+		}{
+			{name: "matching", method: http.MethodGet, ifNoneMatch: etag, statusCode: http.StatusNotModified},
+			{name: "weak", method: http.MethodGet, ifNoneMatch: "W/" + etag, statusCode: http.StatusNotModified},
+			{name: "in a list", method: http.MethodGet, ifNoneMatch: `"other", ` + etag, statusCode: http.StatusNotModified},
+			{name: "any", method: http.MethodGet, ifNoneMatch: "*", statusCode: http.StatusNotModified},
+			{name: "stale", method: http.MethodGet, ifNoneMatch: `"other"`, statusCode: http.StatusOK},
+			{name: "not a read", method: http.MethodPut, ifNoneMatch: etag, statusCode: http.StatusOK},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				r := httptest.NewRequest(tc.method, "/", nil)
+				r.Header.Set("If-None-Match", tc.ifNoneMatch)
+				w := httptest.NewRecorder()
+				h.writeConditional(w, r, res)
+				assert.Equal(t, tc.statusCode, w.Code)
+				assert.Equal(t, etag, w.Header().Get("ETag"))
+				if tc.statusCode == http.StatusNotModified {
+					assert.Empty(t, w.Body.String())
+				} else {
+					assert.Equal(t, first.Body.String(), w.Body.String())
+				}
+			})
+		}
+	})
+
+	t.Run("test If-Match", func(t *testing.T) {
+		updatedAt := time.Date(2023, 4, 28, 10, 30, 0, 123456000, time.UTC)
+		versioned := httptest.NewRecorder()
+		h.writeVersioned(versioned, httptest.NewRequest(http.MethodGet, "/", nil), res, "some-plant", updatedAt)
+		version := versioned.Header().Get("ETag")
+		assert.Equal(t, "Authorization", versioned.Header().Get("Vary"))
+		//Data without a version of its own, e.g. follower counts, still changes the ETag
+		other := httptest.NewRecorder()
+		h.writeVersioned(other, httptest.NewRequest(http.MethodGet, "/", nil), Response{Content: map[string]int{"followerCount": 1}}, "some-plant", updatedAt)
+		otherVersion := other.Header().Get("ETag")
+		assert.NotEqual(t, version, otherVersion)
+		tests := []struct {
+			name    string
+			ifMatch string
+			allowed bool
+			version time.Time
+		}{
+			{name: "absent", allowed: true},
+			{name: "any", ifMatch: "*", allowed: true},
+			{name: "matching", ifMatch: version, allowed: true, version: updatedAt},
+			{name: "in a list", ifMatch: `"other", ` + version, allowed: true, version: updatedAt},
+			{name: "another representation", ifMatch: otherVersion, allowed: true, version: updatedAt},
+			{name: "weak", ifMatch: "W/" + version},
+			{name: "another resource", ifMatch: `"other-plant.1"`},
+			{name: "not a version", ifMatch: etag},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodPut, "/", nil)
+				if tc.ifMatch != "" {
+					r.Header.Set("If-Match", tc.ifMatch)
+				}
+				w := httptest.NewRecorder()
+				got, allowed := h.ifMatchVersion(w, r, "some-plant")
+				assert.Equal(t, tc.allowed, allowed)
+				assert.True(t, tc.version.Equal(got))
+				if !tc.allowed {
+					assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/feed"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"net/http"
+)
+
+type FeedService interface {
+	GetFeed(ctx context.Context, userId string, page pagination.Params) (pagination.Page[feed.Item], error)
+}
+
+// GetFeed - one page of the caller's feed, see pageParams for the query params
+func (h *Handler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("userId").(string)
+	page, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.FeedService.GetFeed(r.Context(), userId, page)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "unable to get feed"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: items.Items, NextCursor: items.NextCursor})
+}
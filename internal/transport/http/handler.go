@@ -10,6 +10,11 @@ import (
 	"github.com/gorilla/mux"
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/blob"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
 	"image"
 	"io"
 	"io/ioutil"
@@ -18,25 +23,40 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 )
 
 type Response struct {
-	Content any    `json:"content"`
-	Message string `json:"message"`
+	Content    any    `json:"content"`
+	Message    string `json:"message"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type Handler struct {
 	Router *mux.Router
 
+	AdminService  AdminService
 	AuthService   AuthService
+	AuthzService  AuthzService
 	CareService   CareService
+	FeedService   FeedService
 	HealthService HealthService
+	MediaService  MediaService
 	PlantService  PlantService
+	UploadService UploadService
 	UserService   UserService
 	Server        *http.Server
+	Uploads       *upload.Validator
+
+	shutdownHooks []func(ctx context.Context) error
 }
 
+// multipartOverhead - allowance for the parts of a multipart body that are not
+// the uploaded file itself
+const multipartOverhead = 1 << 20
+
 // NewHandler - returns a pointer to a http handler
 // Need to give the handler all the different services
 func NewHandler(
@@ -44,6 +64,11 @@ func NewHandler(
 	userService UserService,
 	careService CareService,
 	authService AuthService,
+	authzService AuthzService,
+	adminService AdminService,
+	feedService FeedService,
+	mediaService MediaService,
+	uploadService UploadService,
 	healthService HealthService) *Handler {
 
 	//Create the http handler
@@ -52,7 +77,13 @@ func NewHandler(
 		UserService:   userService,
 		CareService:   careService,
 		AuthService:   authService,
+		AuthzService:  authzService,
+		AdminService:  adminService,
+		FeedService:   feedService,
+		MediaService:  mediaService,
+		UploadService: uploadService,
 		HealthService: healthService,
+		Uploads:       upload.NewValidator(),
 	}
 
 	h.Router = mux.NewRouter()
@@ -69,34 +100,78 @@ func NewHandler(
 	return h
 }
 
+// mapRoutes - every route behind JWTAuth either has its {id} checked by Authorize,
+// checks the id in its request body with authorizeRequest, is restricted to
+// moderators with RequireRole, only acts on the caller, or only reads what any
+// signed in user may read
 func (h *Handler) mapRoutes() {
 	h.Router.HandleFunc("/alive", h.healthCheck).Methods(http.MethodGet)
+	h.Router.HandleFunc(blob.MediaRoutePrefix+"{key}", h.GetMedia).Methods(http.MethodGet, http.MethodHead)
+	h.Router.HandleFunc(blob.MediaRoutePrefix+"{key}", h.PutMedia).Methods(http.MethodPut)
+	// Auth Endpoints
+	h.Router.HandleFunc("/api/v1/auth/login", h.Login).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/auth/refresh", h.RefreshSession).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/auth/logout", h.JWTAuth(h.Logout)).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/auth/logout-all", h.JWTAuth(h.LogoutEverywhere)).Methods(http.MethodPost)
 	// Plant Endpoints
 	h.Router.HandleFunc("/api/v1/plant", h.JWTAuth(h.AddPlant)).Methods(http.MethodPost)
 	h.Router.HandleFunc("/api/v1/plant/image", h.JWTAuth(h.AddPlantImage)).Methods(http.MethodPost)
-	h.Router.HandleFunc("/api/v1/plant/{id}", h.JWTAuth(h.GetPlant)).Methods(http.MethodGet)
-	h.Router.HandleFunc("/api/v1/plant/user/{id}", h.JWTAuth(h.GetPlantsByUserId)).Methods(http.MethodGet)
-	h.Router.HandleFunc("/api/v1/plant/{id}", h.JWTAuth(h.UpdatePlant)).Methods(http.MethodPut)
-	h.Router.HandleFunc("/api/v1/plant/{id}", h.JWTAuth(h.DeletePlant)).Methods(http.MethodDelete)
-	h.Router.HandleFunc("/api/v1/plant/image/plant-id/{id}", h.JWTAuth(h.AddImageToPlant)).Methods(http.MethodPost)
-	h.Router.HandleFunc("/api/v1/plant/image/plant-id/{id}", h.JWTAuth(h.DeletePlantImage)).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/plant/search", h.JWTAuth(h.SearchPlants)).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/plant/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionRead, h.GetPlant))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/plant/user/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.GetPlantsByUserId))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/plant/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.UpdatePlant))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/plant/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.DeletePlant))).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/plant/image/plant-id/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.AddImageToPlant))).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/plant/image/plant-id/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.DeletePlantImage))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/plant/{id}/images/primary", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.SetPrimaryPlantImage))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/plant/{id}/images/order", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.ReorderPlantImages))).Methods(http.MethodPut)
 	// User Endpoints
 	h.Router.HandleFunc("/api/v1/user", h.CreateUser).Methods(http.MethodPost)
-	h.Router.HandleFunc("/api/v1/user/{id}", h.JWTAuth(h.GetUser)).Methods(http.MethodGet)
-	h.Router.HandleFunc("/api/v1/user/id/{id}", h.JWTAuth(h.GetUserById)).Methods(http.MethodGet)
-	h.Router.HandleFunc("/api/v1/user/id/{id}", h.JWTAuth(h.UpdateUser)).Methods(http.MethodPut)
-	h.Router.HandleFunc("/api/v1/user/id/{id}/image", h.JWTAuth(h.UpdateUserProfileImage)).Methods(http.MethodPost)
-	h.Router.HandleFunc("/api/v1/user/id/{id}", h.JWTAuth(h.DeleteUser)).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/user/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.GetUser))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/user/id/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.GetUserById))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/user/id/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionWrite, h.UpdateUser))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/user/id/{id}/image", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionWrite, h.UpdateUserProfileImage))).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/user/id/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionWrite, h.DeleteUser))).Methods(http.MethodDelete)
 	h.Router.HandleFunc("/api/v1/user/username-check/is-taken", h.CheckIfUsernameIsTaken).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/user/id/{id}/follow", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.FollowUser))).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/user/id/{id}/follow", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.UnfollowUser))).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/user/id/{id}/followers", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.GetFollowers))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/user/id/{id}/following", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionRead, h.GetFollowing))).Methods(http.MethodGet)
+	//Plant Care Schedule Endpoints
+	h.Router.HandleFunc("/api/v1/plant-care/schedule", h.JWTAuth(h.SetCareSchedule)).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/plant-care/schedule/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionReadPrivate, h.GetCareSchedule))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/plant-care/schedule/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionWrite, h.DeleteCareSchedule))).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/plant-care/schedule/user/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionReadPrivate, h.GetCareSchedulesByUserId))).Methods(http.MethodGet)
 	//Plant Care Log Endpoints
 	h.Router.HandleFunc("/api/v1/plant-care", h.JWTAuth(h.AddCareLogEntry)).Methods(http.MethodPost)
-	h.Router.HandleFunc("/api/v1/plant-care/{id}", h.JWTAuth(h.GetCareLogsEntries)).Methods(http.MethodGet)
-	h.Router.HandleFunc("/api/v1/plant-care/{id}", h.JWTAuth(h.UpdateCareLogEntry)).Methods(http.MethodPut)
-	h.Router.HandleFunc("/api/v1/plant-care/{id}", h.JWTAuth(h.DeleteCareLogEntry)).Methods(http.MethodDelete)
-	h.Router.HandleFunc("/api/v1/plant-care/user/{id}", h.JWTAuth(h.GetAllUsersCareLogs)).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/plant-care/{id}", h.JWTAuth(h.Authorize(authz.KindPlant, authz.ActionReadPrivate, h.GetCareLogsEntries))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/plant-care/{id}", h.JWTAuth(h.Authorize(authz.KindCareLogEntry, authz.ActionWrite, h.UpdateCareLogEntry))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/plant-care/{id}", h.JWTAuth(h.Authorize(authz.KindCareLogEntry, authz.ActionWrite, h.DeleteCareLogEntry))).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/plant-care/user/{id}", h.JWTAuth(h.Authorize(authz.KindUser, authz.ActionReadPrivate, h.GetAllUsersCareLogs))).Methods(http.MethodGet)
+	// Upload Endpoints
+	h.Router.HandleFunc("/api/v1/uploads", h.JWTAuth(h.CreateUpload)).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/uploads/{id}/finalize", h.JWTAuth(h.FinalizeUpload)).Methods(http.MethodPost)
+	// Feed Endpoints
+	h.Router.HandleFunc("/api/v1/feed", h.JWTAuth(h.GetFeed)).Methods(http.MethodGet)
+	//Admin Endpoints
+	moderators := []string{user.RoleModerator, user.RoleAdmin}
+	h.Router.HandleFunc("/api/v1/admin/users", h.JWTAuth(h.RequireRole(h.ListAccounts, moderators...))).Methods(http.MethodGet)
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/suspend", h.JWTAuth(h.RequireRole(h.SuspendUser, moderators...))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/reactivate", h.JWTAuth(h.RequireRole(h.ReactivateUser, moderators...))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/role", h.JWTAuth(h.RequireRole(h.SetUserRole, user.RoleAdmin))).Methods(http.MethodPut)
+	h.Router.HandleFunc("/api/v1/admin/plants/{id}", h.JWTAuth(h.RequireRole(h.RemovePlant, moderators...))).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/admin/plants/{id}/images", h.JWTAuth(h.RequireRole(h.RemovePlantImage, moderators...))).Methods(http.MethodDelete)
+	h.Router.HandleFunc("/api/v1/admin/blobs/reap", h.JWTAuth(h.RequireRole(h.ReapBlobs, user.RoleAdmin))).Methods(http.MethodPost)
+	h.Router.HandleFunc("/api/v1/admin/cache/stats", h.JWTAuth(h.RequireRole(h.GetCacheStats, user.RoleAdmin))).Methods(http.MethodGet)
 
 }
 
+// OnShutdown - runs hook once the server has stopped taking requests, within
+// the same deadline. Hooks run in the order they were added
+func (h *Handler) OnShutdown(hook func(ctx context.Context) error) {
+	h.shutdownHooks = append(h.shutdownHooks, hook)
+}
+
 func (h *Handler) Serve() error {
 	go func() {
 		if err := h.Server.ListenAndServe(); err != nil {
@@ -105,17 +180,47 @@ func (h *Handler) Serve() error {
 	}()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	h.Server.Shutdown(ctx)
+	for _, hook := range h.shutdownHooks {
+		if err := hook(ctx); err != nil {
+			log.Error(err.Error())
+		}
+	}
 
 	log.Info("shut down gracefully")
 	return nil
 }
 
+// pageParams - reads the optional limit and cursor query params of a list
+// endpoint. Writes a bad request response and returns false if either is invalid
+func (h *Handler) pageParams(w http.ResponseWriter, r *http.Request) (pagination.Params, bool) {
+	query := r.URL.Query()
+	limit := 0
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 {
+			log.Info(fmt.Sprintf("unsuccessful request, reason: invalid limit %q,status code: %d", rawLimit, http.StatusBadRequest))
+			w.WriteHeader(http.StatusBadRequest)
+			h.encodeJsonResponse(&w, Response{Message: "limit must be a positive integer"})
+			return pagination.Params{}, false
+		}
+		limit = parsed
+	}
+	page, err := pagination.NewParams(limit, query.Get("cursor"))
+	if err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "invalid cursor"})
+		return pagination.Params{}, false
+	}
+	return page, true
+}
+
 // ParseUrlQueryParams a function to parse url query params. The function accepts a URL and a slice of map keys that
 //are expected in the query parameters. This function returns map that is safe to use with all expected keys in it/**
 func (h *Handler) ParseUrlQueryParams(url *url.URL, paramMapKeys ...string) (map[string]string, error) {
@@ -132,48 +237,38 @@ func (h *Handler) ParseUrlQueryParams(url *url.URL, paramMapKeys ...string) (map
 	return mapValues, nil
 }
 
-func (h *Handler) ParseFilesFromMultiPartFormData(formData *multipart.Form, numberOfFiles int) ([]string, error) {
+// ParseFilesFromMultiPartFormData - saves the files image0 to imageN-1 through
+// the upload validator. The caller must Cleanup every returned file
+func (h *Handler) ParseFilesFromMultiPartFormData(formData *multipart.Form, numberOfFiles int) ([]*upload.File, error) {
 	log.Infof("number of files: %d", numberOfFiles)
-	var fileNames []string
+	var files []*upload.File
+	cleanup := func() {
+		for _, file := range files {
+			file.Cleanup()
+		}
+	}
 	for index := 0; index < numberOfFiles; index++ {
 		fileName := fmt.Sprintf("image%d", index)
-		files, ok := formData.File[fileName] // grab the filenames
-		// loop through the files one by one
-		err := func() error {
-			if !ok {
-				return errors.New(fmt.Sprintf("file not found: %s", fileName))
-			}
-			//Check if slice is empty
-			if len(files) < 1 {
-				return errors.New(fmt.Sprintf("unable to parse file supplied: %s", fileName))
-			}
-			file, err := files[0].Open()
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-			year, month, day := time.Now().Date()
-			hour := time.Now().Hour()
-			minute := time.Now().Minute()
-			newFileName := fmt.Sprintf("/tmp/%d-%d-%d-T-%d-%d-%s", year, month, day, hour, minute, files[0].Filename)
-			fmt.Println(newFileName)
-			out, err := os.Create(newFileName)
+		headers, ok := formData.File[fileName] // grab the filenames
+		if !ok || len(headers) < 1 {
+			cleanup()
+			return nil, errors.New(fmt.Sprintf("file not found: %s", fileName))
+		}
+		file, err := func() (*upload.File, error) {
+			src, err := headers[0].Open()
 			if err != nil {
-				return errors.New("unable to create the file for writing")
-			}
-			defer out.Close()
-			// file not files[i] !
-			if _, err := io.Copy(out, file); err != nil {
-				return err
+				return nil, err
 			}
-			fileNames = append(fileNames, newFileName)
-			return nil
+			defer src.Close()
+			return h.Uploads.Save(src, headers[0].Filename)
 		}()
 		if err != nil {
+			cleanup()
 			return nil, err
 		}
+		files = append(files, file)
 	}
-	return fileNames, nil
+	return files, nil
 }
 
 func (h *Handler) ParseImagesFromRequestBody(request *http.Request, numImages int) ([]image.Image, error) {
@@ -202,6 +297,9 @@ func (h *Handler) ParseImagesFromRequestBody(request *http.Request, numImages in
 		if err != nil {
 			return images, err
 		}
+		if err := h.Uploads.CheckImageBytes(img); err != nil {
+			return images, err
+		}
 
 		// Decode the image
 		imgReader := bytes.NewReader(img)
@@ -217,32 +315,70 @@ func (h *Handler) ParseImagesFromRequestBody(request *http.Request, numImages in
 	return images, nil
 }
 
-func ParseImageFromRequestBody(r *http.Request) (string, error) {
-	// parse the multipart form in the request
-	if err := r.ParseMultipartForm(1024); err != nil {
-		return "", err
-	}
-
-	// get the file from the form
-	file, _, err := r.FormFile("image")
+// receiveImage - streams the "image" part of a multipart body through the upload
+// validator. Writes the error response and returns false when the upload is
+// rejected. The caller must Cleanup the returned file
+func (h *Handler) receiveImage(w http.ResponseWriter, r *http.Request) (*upload.File, bool) {
+	// Leave room for the multipart boundaries and headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, h.Uploads.MaxBytes+multipartOverhead)
+	reader, err := r.MultipartReader()
 	if err != nil {
-		return "", err
+		h.writeUploadError(w, err)
+		return nil, false
 	}
-	defer file.Close()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			h.writeUploadError(w, upload.InvalidImageError{Message: "no image was found in the request"})
+			return nil, false
+		}
+		if err != nil {
+			h.writeUploadError(w, err)
+			return nil, false
+		}
+		if part.FormName() != "image" {
+			part.Close()
+			continue
+		}
+		file, err := h.Uploads.Save(part, part.FileName())
+		part.Close()
+		if err != nil {
+			h.writeUploadError(w, err)
+			return nil, false
+		}
+		return file, true
+	}
+}
 
-	// read the file into a buffer
-	var buf bytes.Buffer
-	io.Copy(&buf, file)
+func (h *Handler) writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge upload.TooLargeError
+	var unsupported upload.UnsupportedTypeError
+	var invalid upload.InvalidImageError
+	status := http.StatusBadRequest
+	message := "Invalid upload, please provide an image"
+	switch {
+	case errors.As(err, &tooLarge):
+		status, message = http.StatusRequestEntityTooLarge, tooLarge.Error()
+	case errors.As(err, &unsupported):
+		status, message = http.StatusUnsupportedMediaType, "Unsupported image type, please upload a jpeg, png, gif or webp"
+	case errors.As(err, &invalid):
+		message = invalid.Message
+	}
+	log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), status))
+	w.WriteHeader(status)
+	h.encodeJsonResponse(&w, Response{Message: message})
+}
 
-	year, month, day := time.Now().Date()
-	hour := time.Now().Hour()
-	minute := time.Now().Minute()
-	// write the buffer to a file
-	fileName := fmt.Sprintf("/tmp/%d-%d-%d-T-%d-%d-%s.jpeg", year, month, day, hour, minute, uuid.NewV4().String())
-	if err = ioutil.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
-		return "", err
+// idFromPath - returns the {id} route variable, writing a 400 when it is not a uuid
+func (h *Handler) idFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.FromString(id); err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: fmt.Sprintf("invalid id %s", id)})
+		return "", false
 	}
-	return fileName, nil
+	return id, true
 }
 
 func (h *Handler) encodeJsonResponse(w *http.ResponseWriter, res Response) {
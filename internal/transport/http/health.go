@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
 	"net/http"
 )
 
 type HealthService interface {
 	CheckDbHealth(ctx context.Context) error
+	CacheStats() cache.Stats
 }
 
 func (h *Handler) healthCheck(w http.ResponseWriter, r *http.Request) {
@@ -25,3 +27,10 @@ func (h *Handler) healthCheck(w http.ResponseWriter, r *http.Request) {
 	h.encodeJsonResponse(&w, Response{Message: "Service alive. Database connection is good. Cache connection is good"})
 	return
 }
+
+// GetCacheStats - the cache hits and misses since startup
+func (h *Handler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: h.HealthService.CacheStats()})
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+type MediaService interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error)
+	AcceptPresignedPut(ctx context.Context, key string, query url.Values, contentType string, body io.Reader) error
+}
+
+// GetMedia - serves blobs kept by the local blob backend. Public, like the S3
+// urls it stands in for
+func (h *Handler) GetMedia(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	file, modTime, err := h.MediaService.Open(r.Context(), key)
+	if err != nil {
+		var noEntityError *errs.NoEntityError
+		var badRequestError errs.BadRequestError
+		switch {
+		case errors.As(err, &noEntityError), errors.As(err, &badRequestError):
+			log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+			w.WriteHeader(http.StatusNotFound)
+			h.encodeJsonResponse(&w, Response{Message: "not found"})
+		default:
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+			w.WriteHeader(http.StatusInternalServerError)
+			h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		}
+		return
+	}
+	defer file.Close()
+	// JSONMiddleware has already set a content type, ServeContent only sniffs one if unset
+	w.Header().Del("Content-Type")
+	if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, key, modTime, file)
+}
+
+// PutMedia - receives uploads sent to presigned urls handed out by the local
+// blob backend. The signature in the query string stands in for a session
+func (h *Handler) PutMedia(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	if r.ContentLength > h.Uploads.MaxBytes {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: content length %d,status code: %d", r.ContentLength, http.StatusRequestEntityTooLarge))
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		h.encodeJsonResponse(&w, Response{Message: fmt.Sprintf("upload is larger than %d bytes", h.Uploads.MaxBytes)})
+		return
+	}
+	// A body without a length is cut off one byte past the limit, which
+	// finalizing the upload then rejects as too large
+	body := io.LimitReader(r.Body, h.Uploads.MaxBytes+1)
+	err := h.MediaService.AcceptPresignedPut(r.Context(), key, r.URL.Query(), r.Header.Get("Content-Type"), body)
+	if err != nil {
+		var noEntityError *errs.NoEntityError
+		var badRequestError errs.BadRequestError
+		var forbiddenError errs.ForbiddenError
+		switch {
+		case errors.As(err, &forbiddenError):
+			log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusForbidden))
+			w.WriteHeader(http.StatusForbidden)
+			h.encodeJsonResponse(&w, Response{Message: forbiddenError.Message})
+		case errors.As(err, &noEntityError), errors.As(err, &badRequestError):
+			log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+			w.WriteHeader(http.StatusNotFound)
+			h.encodeJsonResponse(&w, Response{Message: "not found"})
+		default:
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+			w.WriteHeader(http.StatusInternalServerError)
+			h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		}
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "upload received"})
+}
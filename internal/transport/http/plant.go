@@ -3,26 +3,32 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
 	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/pagination"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
 	"net/http"
 )
 
 type PlantService interface {
 	AddPlant(ctx context.Context, p plant.Plant, images []string) (*plant.Plant, error)
-	AddPlantImage(ctx context.Context, filePath string) (string, error)
-	AddPlantImageWithId(ctx context.Context, plantId string, filePath string) (*plant.Plant, string, error)
+	AddPlantImage(ctx context.Context, filePath string) (*plant.ImageUrls, error)
+	AddPlantImageWithId(ctx context.Context, plantId string, filePath string) (*plant.Plant, *plant.ImageUrls, error)
 	GetPlant(ctx context.Context, id string) (*plant.Plant, error)
-	GetPlantsByUserId(ctx context.Context, id string) ([]plant.Plant, error)
+	GetPlantsByUserId(ctx context.Context, id string, page pagination.Params) (pagination.Page[plant.Plant], error)
 	UpdatePlant(ctx context.Context, id string, p plant.Plant, imagesToDelete []string) (*plant.Plant, error)
 	DeletePlant(ctx context.Context, id string) error
 	DeletePlantImage(ctx context.Context, plantId string, uri string) error
+	SearchPlants(ctx context.Context, query plant.SearchQuery) ([]plant.SearchResult, error)
+	SetPrimaryPlantImage(ctx context.Context, plantId string, image string) (*plant.Plant, error)
+	ReorderPlantImages(ctx context.Context, plantId string, images []string) (*plant.Plant, error)
 }
 
 type response struct {
@@ -31,10 +37,11 @@ type response struct {
 
 type NewPlantRequest struct {
 	CommonName     string   `json:"commonName" validate:"required"`
-	UserId         string   `json:"userId" validate:"required"`
+	UserId         string   `json:"userId" validate:"required,uuid"`
 	Images         []string `json:"images"`
 	ScientificName string   `json:"scientificName"`
 	Toxicity       string   `json:"toxicity"`
+	SearchTerms    []string `json:"searchTerms"`
 }
 
 type UpdatePlantRequest struct {
@@ -44,6 +51,7 @@ type UpdatePlantRequest struct {
 	ImagesToDelete []string `json:"imagesToDelete"`
 	ScientificName string   `json:"scientificName"`
 	Toxicity       string   `json:"toxicity"`
+	SearchTerms    []string `json:"searchTerms"`
 }
 
 func (h *Handler) AddPlant(w http.ResponseWriter, r *http.Request) {
@@ -65,12 +73,16 @@ func (h *Handler) AddPlant(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, res)
 		return
 	}
+	if !h.authorizeRequest(w, r, authz.Resource{Kind: authz.KindUser, Id: pr.UserId}, authz.ActionWrite) {
+		return
+	}
 	p := plant.Plant{
 		CommonName:     pr.CommonName,
 		ScientificName: pr.ScientificName,
 		Images:         pr.Images,
 		UserId:         pr.UserId,
 		Toxicity:       pr.Toxicity,
+		SearchTerms:    pr.SearchTerms,
 	}
 	newPlant, err := h.PlantService.AddPlant(r.Context(), p, p.Images)
 	if err != nil {
@@ -80,6 +92,10 @@ func (h *Handler) AddPlant(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusBadRequest)
 			h.encodeJsonResponse(&w, Response{Message: e.Message})
 			return
+		case errs.BadRequestError:
+			w.WriteHeader(http.StatusBadRequest)
+			h.encodeJsonResponse(&w, Response{Message: e.Message})
+			return
 		default:
 			w.WriteHeader(http.StatusInternalServerError)
 			h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
@@ -96,10 +112,11 @@ func (h *Handler) AddPlant(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+type getPlantResponse struct {
+	Plant plant.Plant `json:"plant"`
+}
+
 func (h *Handler) GetPlant(w http.ResponseWriter, r *http.Request) {
-	type getPlantResponse struct {
-		Plant plant.Plant `json:"plant"`
-	}
 	vars := mux.Vars(r)
 	id := vars["id"]
 	_, err := uuid.Parse(id)
@@ -116,12 +133,7 @@ func (h *Handler) GetPlant(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
 		return
 	}
-	res := Response{
-		Content: getPlantResponse{Plant: *p},
-	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, res)
+	h.writeVersioned(w, r, Response{Content: getPlantResponse{Plant: *p}}, p.PlantId, p.UpdatedAt)
 	return
 }
 
@@ -131,7 +143,11 @@ func (h *Handler) GetPlantsByUserId(w http.ResponseWriter, r *http.Request) {
 	}
 	vars := mux.Vars(r)
 	id := vars["id"]
-	plantList, err := h.PlantService.GetPlantsByUserId(r.Context(), id)
+	page, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+	plantList, err := h.PlantService.GetPlantsByUserId(r.Context(), id, page)
 	if err != nil {
 		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -139,12 +155,62 @@ func (h *Handler) GetPlantsByUserId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	res := Response{
-		Content: response{Plants: plantList},
+		Content:    response{Plants: plantList.Items},
+		NextCursor: plantList.NextCursor,
+	}
+	h.writeConditional(w, r, res)
+	return
+}
+
+// SearchPlants - full text search over plant names and search terms. Takes the
+// search text in q, and optional owner (a user id), toxicity and limit params
+func (h *Handler) SearchPlants(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Plants []plant.SearchResult `json:"plants"`
+	}
+	params := r.URL.Query()
+	query := plant.SearchQuery{
+		Text:     params.Get("q"),
+		OwnerId:  params.Get("owner"),
+		Toxicity: params.Get("toxicity"),
+	}
+	if query.OwnerId != "" {
+		if _, err := uuid.Parse(query.OwnerId); err != nil {
+			log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+			w.WriteHeader(http.StatusBadRequest)
+			h.encodeJsonResponse(&w, Response{Message: "Invalid owner, please provide a valid user id"})
+			return
+		}
+	}
+	// Results are ordered by rank, which a created_at/id cursor can not page
+	if params.Get("cursor") != "" {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", "cursor on search", http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "search results are not paged, use limit instead of cursor"})
+		return
+	}
+	page, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+	query.Limit = page.Limit
+	results, err := h.PlantService.SearchPlants(r.Context(), query)
+	if err != nil {
+		var badRequestError errs.BadRequestError
+		if errors.As(err, &badRequestError) {
+			log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", badRequestError.Message, http.StatusBadRequest))
+			w.WriteHeader(http.StatusBadRequest)
+			h.encodeJsonResponse(&w, Response{Message: badRequestError.Message})
+			return
+		}
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		return
 	}
 	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
 	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, res)
-	return
+	h.encodeJsonResponse(&w, Response{Content: response{Plants: results}})
 }
 
 func (h *Handler) UpdatePlant(w http.ResponseWriter, r *http.Request) {
@@ -165,17 +231,19 @@ func (h *Handler) UpdatePlant(w http.ResponseWriter, r *http.Request) {
 		Toxicity:       up.Toxicity,
 		UserId:         up.UserId,
 		Images:         up.Images,
+		SearchTerms:    up.SearchTerms,
+	}
+	version, ok := h.ifMatchVersion(w, r, id)
+	if !ok {
+		return
 	}
-	_, err := h.PlantService.UpdatePlant(r.Context(), id, updatedPlant, imagesToDelete)
+	updatedPlant.UpdatedAt = version
+	p, err := h.PlantService.UpdatePlant(r.Context(), id, updatedPlant, imagesToDelete)
 	if err != nil {
-		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
-		w.WriteHeader(http.StatusInternalServerError)
-		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+		h.writePlantError(w, err, "An unexpected error occurred")
 		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, Response{Message: "Plant successfully updated"})
+	h.writeVersioned(w, r, Response{Content: getPlantResponse{Plant: *p}, Message: "Plant successfully updated"}, p.PlantId, p.UpdatedAt)
 	return
 }
 
@@ -212,38 +280,35 @@ func (h *Handler) AddImageToPlant(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	filePath, err := ParseImageFromRequestBody(r)
-	if err != nil {
-		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
-		w.WriteHeader(http.StatusInternalServerError)
-		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not add plant image"})
+	uploaded, ok := h.receiveImage(w, r)
+	if !ok {
 		return
 	}
-	updatedPlant, fileUri, err := h.PlantService.AddPlantImageWithId(r.Context(), id, filePath)
+	defer uploaded.Cleanup()
+	filePath := uploaded.Path
+	updatedPlant, imageUrls, err := h.PlantService.AddPlantImageWithId(r.Context(), id, filePath)
 	if err != nil {
-		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
-		w.WriteHeader(http.StatusInternalServerError)
-		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not add plant image"})
+		h.writePlantError(w, err, "Unexpected error, could not add plant image")
 		return
 	}
 	content := struct {
-		Plant plant.Plant `json:"plant"`
-		Uri   string      `json:"imageUrl"`
-	}{Plant: *updatedPlant, Uri: fileUri}
+		Plant     plant.Plant     `json:"plant"`
+		Uri       string          `json:"imageUrl"`
+		ImageUrls plant.ImageUrls `json:"imageUrls"`
+	}{Plant: *updatedPlant, Uri: imageUrls.Url, ImageUrls: *imageUrls}
 	w.WriteHeader(http.StatusOK)
 	h.encodeJsonResponse(&w, Response{Content: content})
 	return
 }
 
 func (h *Handler) AddPlantImage(w http.ResponseWriter, r *http.Request) {
-	filePath, err := ParseImageFromRequestBody(r)
-	if err != nil {
-		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
-		w.WriteHeader(http.StatusInternalServerError)
-		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not add plant image"})
+	uploaded, ok := h.receiveImage(w, r)
+	if !ok {
 		return
 	}
-	fileUri, err := h.PlantService.AddPlantImage(r.Context(), filePath)
+	defer uploaded.Cleanup()
+	filePath := uploaded.Path
+	imageUrls, err := h.PlantService.AddPlantImage(r.Context(), filePath)
 	if err != nil {
 		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -251,8 +316,9 @@ func (h *Handler) AddPlantImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	content := struct {
-		Uri string `json:"imageUrl"`
-	}{Uri: fileUri}
+		Uri       string          `json:"imageUrl"`
+		ImageUrls plant.ImageUrls `json:"imageUrls"`
+	}{Uri: imageUrls.Url, ImageUrls: *imageUrls}
 	w.WriteHeader(http.StatusOK)
 	h.encodeJsonResponse(&w, Response{Content: content})
 	return
@@ -287,3 +353,89 @@ func (h *Handler) DeletePlantImage(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	h.encodeJsonResponse(&w, Response{Content: "successfully deleted"})
 }
+
+type SetPrimaryPlantImageRequest struct {
+	Image string `json:"image" validate:"required"`
+}
+
+type ReorderPlantImagesRequest struct {
+	Images []string `json:"images" validate:"required,min=1"`
+}
+
+func (h *Handler) SetPrimaryPlantImage(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	var primaryRequest SetPrimaryPlantImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&primaryRequest); err != nil {
+		h.writePlantError(w, errs.BadRequestError{Message: "Invalid request body"}, "")
+		return
+	}
+	if err := validator.New().Struct(primaryRequest); err != nil {
+		h.writePlantError(w, errs.BadRequestError{Message: "Invalid request, please provide an image"}, "")
+		return
+	}
+	p, err := h.PlantService.SetPrimaryPlantImage(r.Context(), id, primaryRequest.Image)
+	if err != nil {
+		h.writePlantError(w, err, "Unexpected error, could not set the primary image")
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: struct {
+		Plant plant.Plant `json:"plant"`
+	}{Plant: *p}})
+}
+
+// ReorderPlantImages - the body lists every image of the plant in its new
+// order, the first becomes the primary image
+func (h *Handler) ReorderPlantImages(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	var reorderRequest ReorderPlantImagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&reorderRequest); err != nil {
+		h.writePlantError(w, errs.BadRequestError{Message: "Invalid request body"}, "")
+		return
+	}
+	if err := validator.New().Struct(reorderRequest); err != nil {
+		h.writePlantError(w, errs.BadRequestError{Message: "Invalid request, please provide the plant's images"}, "")
+		return
+	}
+	p, err := h.PlantService.ReorderPlantImages(r.Context(), id, reorderRequest.Images)
+	if err != nil {
+		h.writePlantError(w, err, "Unexpected error, could not reorder the plant's images")
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: struct {
+		Plant plant.Plant `json:"plant"`
+	}{Plant: *p}})
+}
+
+// writePlantError - bad requests and missing plants get their own status, anything
+// else is reported with the given message
+func (h *Handler) writePlantError(w http.ResponseWriter, err error, message string) {
+	var badRequestError errs.BadRequestError
+	var noEntityError *errs.NoEntityError
+	var preconditionFailedError errs.PreconditionFailedError
+	switch {
+	case errors.As(err, &badRequestError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: badRequestError.Message})
+	case errors.As(err, &noEntityError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+		w.WriteHeader(http.StatusNotFound)
+		h.encodeJsonResponse(&w, Response{Message: "No plant found"})
+	case errors.As(err, &preconditionFailedError):
+		h.writePreconditionFailed(w, err)
+	default:
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: message})
+	}
+}
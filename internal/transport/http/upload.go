@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/authz"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/blob"
+	errs "gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/upload"
+	"net/http"
+)
+
+type UploadService interface {
+	CreateUpload(ctx context.Context, userId string, target string, targetId string, contentType string) (*upload.Ticket, error)
+	FinalizeUpload(ctx context.Context, userId string, uploadId string) (*upload.Result, error)
+}
+
+// CreateUploadRequest - targetId is a plant id for plant uploads and defaults to
+// the caller for profile uploads
+type CreateUploadRequest struct {
+	Target      string `json:"target"`
+	TargetId    string `json:"targetId"`
+	ContentType string `json:"contentType"`
+}
+
+// CreateUpload - starts a direct upload, returning a presigned url the client
+// PUTs the image to before calling FinalizeUpload
+func (h *Handler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	var createRequest CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&createRequest); err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request body"})
+		return
+	}
+	userId, _ := r.Context().Value("userId").(string)
+	var resource authz.Resource
+	switch createRequest.Target {
+	case upload.TargetPlant:
+		resource = authz.Resource{Kind: authz.KindPlant, Id: createRequest.TargetId}
+	case upload.TargetProfile:
+		if createRequest.TargetId == "" {
+			createRequest.TargetId = userId
+		}
+		resource = authz.Resource{Kind: authz.KindUser, Id: createRequest.TargetId}
+	default:
+		log.Info(fmt.Sprintf("unsuccessful request, reason: invalid target %q,status code: %d", createRequest.Target, http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, target must be plant or profile"})
+		return
+	}
+	if _, err := uuid.Parse(createRequest.TargetId); err != nil {
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: "Invalid request, please include a valid target id"})
+		return
+	}
+	if !h.authorizeRequest(w, r, resource, authz.ActionWrite) {
+		return
+	}
+	ticket, err := h.UploadService.CreateUpload(r.Context(), userId, createRequest.Target, createRequest.TargetId, createRequest.ContentType)
+	if err != nil {
+		h.writeDirectUploadError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusCreated))
+	w.WriteHeader(http.StatusCreated)
+	h.encodeJsonResponse(&w, Response{Content: ticket})
+}
+
+// FinalizeUpload - processes an image the caller uploaded to a presigned url and
+// attaches it to the plant or profile the upload was created for
+func (h *Handler) FinalizeUpload(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.idFromPath(w, r)
+	if !ok {
+		return
+	}
+	userId, _ := r.Context().Value("userId").(string)
+	result, err := h.UploadService.FinalizeUpload(r.Context(), userId, id)
+	if err != nil {
+		h.writeDirectUploadError(w, err)
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: result})
+}
+
+func (h *Handler) writeDirectUploadError(w http.ResponseWriter, err error) {
+	var noEntityError *errs.NoEntityError
+	var badRequestError errs.BadRequestError
+	var notSupported blob.DirectUploadNotSupportedError
+	var tooLarge upload.TooLargeError
+	var unsupported upload.UnsupportedTypeError
+	var invalid upload.InvalidImageError
+	switch {
+	case errors.As(err, &tooLarge), errors.As(err, &unsupported), errors.As(err, &invalid):
+		h.writeUploadError(w, err)
+	case errors.As(err, &noEntityError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotFound))
+		w.WriteHeader(http.StatusNotFound)
+		h.encodeJsonResponse(&w, Response{Message: "No upload found"})
+	case errors.As(err, &badRequestError):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", badRequestError.Message, http.StatusBadRequest))
+		w.WriteHeader(http.StatusBadRequest)
+		h.encodeJsonResponse(&w, Response{Message: badRequestError.Message})
+	case errors.As(err, &notSupported):
+		log.Info(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusNotImplemented))
+		w.WriteHeader(http.StatusNotImplemented)
+		h.encodeJsonResponse(&w, Response{Message: "Direct uploads are not supported, please upload the image to the api"})
+	default:
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "An unexpected error occurred"})
+	}
+}
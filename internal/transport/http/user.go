@@ -3,11 +3,13 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
 	"net/http"
@@ -19,8 +21,17 @@ type UserService interface {
 	AddUser(ctx context.Context, u user.NewUserRequest) (*user.User, error)
 	DeleteUser(ctx context.Context, id string) error
 	UpdateUser(ctx context.Context, id string, u user.UpdateUserRequest) (*user.User, error)
-	UpdateUserProfileImage(ctx context.Context, filePath string, id string) (string, error)
+	UpdateUserProfileImage(ctx context.Context, filePath string, id string) (*imaging.ImageUrls, error)
 	CheckIfUsernameIsTaken(ctx context.Context, username string) (bool, error)
+	FollowUser(ctx context.Context, followerId string, followeeId string) error
+	UnfollowUser(ctx context.Context, followerId string, followeeId string) error
+	GetFollowers(ctx context.Context, userId string) ([]user.Profile, error)
+	GetFollowing(ctx context.Context, userId string) ([]user.Profile, error)
+}
+
+type followListResponse struct {
+	Users []user.Profile `json:"users"`
+	Count int            `json:"count"`
 }
 
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -80,7 +91,7 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	res := Response{Content: usr, Message: "account successfully created"}
+	res := Response{Content: userRepresentation(r, usr), Message: "account successfully created"}
 	w.WriteHeader(http.StatusOK)
 	h.encodeJsonResponse(&w, res)
 	return
@@ -103,13 +114,20 @@ func (h *Handler) GetUserById(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, res)
 		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	res := Response{Content: usr, Message: "account successfully created"}
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, res)
+	h.writeVersioned(w, r, Response{Content: userRepresentation(r, usr), Message: "account successfully created"}, usr.Id, usr.UpdatedAt)
 	return
 }
 
+// userRepresentation - only the user themselves sees their email and role,
+// everyone else gets the public profile
+func userRepresentation(r *http.Request, usr *user.User) interface{} {
+	callerId, _ := r.Context().Value("userId").(string)
+	if callerId == usr.Id {
+		return usr
+	}
+	return usr.Public()
+}
+
 func (h *Handler) UpdateUserProfileImage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -119,14 +137,13 @@ func (h *Handler) UpdateUserProfileImage(w http.ResponseWriter, r *http.Request)
 		h.encodeJsonResponse(&w, Response{Message: fmt.Sprintf("invalid id %s", id)})
 		return
 	}
-	filePath, err := ParseImageFromRequestBody(r)
-	if err != nil {
-		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
-		w.WriteHeader(http.StatusInternalServerError)
-		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not update user profile image"})
+	uploaded, ok := h.receiveImage(w, r)
+	if !ok {
 		return
 	}
-	fileUri, err := h.UserService.UpdateUserProfileImage(r.Context(), filePath, id)
+	defer uploaded.Cleanup()
+	filePath := uploaded.Path
+	imageUrls, err := h.UserService.UpdateUserProfileImage(r.Context(), filePath, id)
 	if err != nil {
 		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -134,8 +151,9 @@ func (h *Handler) UpdateUserProfileImage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	content := struct {
-		uri string
-	}{uri: fileUri}
+		Uri       string            `json:"imageUrl"`
+		ImageUrls imaging.ImageUrls `json:"imageUrls"`
+	}{Uri: imageUrls.Url, ImageUrls: *imageUrls}
 	h.encodeJsonResponse(&w, Response{Content: content})
 	return
 }
@@ -157,16 +175,24 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		h.encodeJsonResponse(&w, res)
 		return
 	}
+	version, ok := h.ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+	usr.UpdatedAt = version
 	usrUpdated, err := h.UserService.UpdateUser(r.Context(), id, usr)
 	if err != nil {
+		var preconditionFailedError nectar_errors.PreconditionFailedError
+		if errors.As(err, &preconditionFailedError) {
+			h.writePreconditionFailed(w, err)
+			return
+		}
 		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
 		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not get user info"})
 		return
 	}
-	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
-	w.WriteHeader(http.StatusOK)
-	h.encodeJsonResponse(&w, Response{Content: usrUpdated, Message: "user data successfully updated"})
+	h.writeVersioned(w, r, Response{Content: usrUpdated, Message: "user data successfully updated"}, usrUpdated.Id, usrUpdated.UpdatedAt)
 	return
 }
 
@@ -228,3 +254,63 @@ func (h *Handler) CheckIfUsernameIsTaken(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	h.encodeJsonResponse(&w, Response{Content: res})
 }
+
+func (h *Handler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeId := mux.Vars(r)["id"]
+	followerId, _ := r.Context().Value("userId").(string)
+	if err := h.UserService.FollowUser(r.Context(), followerId, followeeId); err != nil {
+		if e, ok := err.(nectar_errors.BadRequestError); ok {
+			log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusBadRequest))
+			w.WriteHeader(http.StatusBadRequest)
+			h.encodeJsonResponse(&w, Response{Message: e.Message})
+			return
+		}
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not follow user"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "successfully followed user"})
+}
+
+func (h *Handler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeId := mux.Vars(r)["id"]
+	followerId, _ := r.Context().Value("userId").(string)
+	if err := h.UserService.UnfollowUser(r.Context(), followerId, followeeId); err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not unfollow user"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Message: "successfully unfollowed user"})
+}
+
+func (h *Handler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	followers, err := h.UserService.GetFollowers(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not get followers"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: followListResponse{Users: followers, Count: len(followers)}})
+}
+
+func (h *Handler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	following, err := h.UserService.GetFollowing(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		log.Errorf(fmt.Sprintf("unsuccessful request, reason: %s,status code: %d", err.Error(), http.StatusInternalServerError))
+		w.WriteHeader(http.StatusInternalServerError)
+		h.encodeJsonResponse(&w, Response{Message: "Unexpected error, could not get followed users"})
+		return
+	}
+	log.Info(fmt.Sprintf("successfully handled request, status code: %d", http.StatusOK))
+	w.WriteHeader(http.StatusOK)
+	h.encodeJsonResponse(&w, Response{Content: followListResponse{Users: following, Count: len(following)}})
+}
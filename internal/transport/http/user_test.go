@@ -0,0 +1,52 @@
+//go:build integration
+
+package http
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/user"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeUserService struct {
+	UserService
+	user user.User
+}
+
+func (f fakeUserService) GetUserById(ctx context.Context, id string) (*user.User, error) {
+	u := f.user
+	return &u, nil
+}
+
+func TestGetUserById(t *testing.T) {
+	userId := "7f1f2d1e-8c1b-4a4e-9d55-6a3f0b0e6c11"
+	h := &Handler{UserService: fakeUserService{user: user.User{Id: userId, Email: "kevin@email.com", Username: "kevin", Role: user.RoleAdmin}}}
+	tests := []struct {
+		name      string
+		callerId  string
+		showEmail bool
+	}{
+		{name: "the user themselves sees their email", callerId: userId, showEmail: true},
+		{name: "another user sees the public profile", callerId: "caller-id", showEmail: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/user/id/"+userId, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": userId})
+			req = req.WithContext(context.WithValue(req.Context(), "userId", tc.callerId))
+			rec := httptest.NewRecorder()
+
+			h.GetUserById(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"username":"kevin"`)
+			assert.Equal(t, tc.showEmail, strings.Contains(rec.Body.String(), "kevin@email.com"))
+			assert.Equal(t, tc.showEmail, strings.Contains(rec.Body.String(), `"role"`))
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/plant"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	TargetPlant   = "plant"
+	TargetProfile = "profile"
+
+	defaultUrlTTL = 15 * time.Minute
+)
+
+// Pending - an upload that was handed a presigned url and has not been
+// finalized yet
+type Pending struct {
+	Id          string
+	UserId      string
+	ObjectKey   string
+	ContentType string
+	Target      string
+	TargetId    string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	FinalizedAt *time.Time
+}
+
+// Ticket - what a client needs to PUT the image straight to the blob store
+type Ticket struct {
+	UploadId  string            `json:"uploadId"`
+	Url       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	MaxBytes  int64             `json:"maxBytes"`
+}
+
+// Result - the processed image and, for plant uploads, the updated plant
+type Result struct {
+	ImageUrls imaging.ImageUrls `json:"imageUrls"`
+	Plant     *plant.Plant      `json:"plant,omitempty"`
+}
+
+type Store interface {
+	AddPendingUpload(ctx context.Context, pending Pending) error
+	GetPendingUpload(ctx context.Context, id string) (*Pending, error)
+	ClaimPendingUpload(ctx context.Context, id string) (bool, error)
+	ReleasePendingUpload(ctx context.Context, id string) error
+}
+
+type BlobStore interface {
+	PresignPut(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type PlantImageService interface {
+	AddPlantImageWithId(ctx context.Context, plantId string, uri string) (*plant.Plant, *plant.ImageUrls, error)
+}
+
+type ProfileImageService interface {
+	UpdateUserProfileImage(ctx context.Context, uri string, userId string) (*imaging.ImageUrls, error)
+}
+
+type Service struct {
+	Store         Store
+	BlobStore     BlobStore
+	Validator     *Validator
+	PlantImages   PlantImageService
+	ProfileImages ProfileImageService
+	UrlTTL        time.Duration
+}
+
+// NewService - UPLOAD_URL_TTL (a duration, default 15m) is how long a presigned
+// url stays valid
+func NewService(store Store, blobStore BlobStore, validator *Validator, plantImages PlantImageService, profileImages ProfileImageService) *Service {
+	ttl, err := time.ParseDuration(os.Getenv("UPLOAD_URL_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultUrlTTL
+	}
+	return &Service{
+		Store:         store,
+		BlobStore:     blobStore,
+		Validator:     validator,
+		PlantImages:   plantImages,
+		ProfileImages: profileImages,
+		UrlTTL:        ttl,
+	}
+}
+
+// CreateUpload - records a pending upload for the target and returns a
+// presigned url the client PUTs the image to. The caller must already be
+// allowed to change the target
+func (s *Service) CreateUpload(ctx context.Context, userId string, target string, targetId string, contentType string) (*Ticket, error) {
+	tag := "upload.CreateUpload"
+	if target != TargetPlant && target != TargetProfile {
+		return nil, nectar_errors.BadRequestError{Message: "target must be plant or profile"}
+	}
+	extension, ok := AllowedTypes[contentType]
+	if !ok {
+		return nil, UnsupportedTypeError{ContentType: contentType}
+	}
+	id := uuid.NewV4().String()
+	pending := Pending{
+		Id:          id,
+		UserId:      userId,
+		ObjectKey:   "upload-" + id + extension,
+		ContentType: contentType,
+		Target:      target,
+		TargetId:    targetId,
+		ExpiresAt:   time.Now().Add(s.UrlTTL),
+	}
+	signedUrl, err := s.BlobStore.PresignPut(ctx, pending.ObjectKey, contentType, s.UrlTTL)
+	if err != nil {
+		return nil, fmt.Errorf("BlobStore.PresignPut in %s failed for %w", tag, err)
+	}
+	if err := s.Store.AddPendingUpload(ctx, pending); err != nil {
+		return nil, fmt.Errorf("Store.AddPendingUpload in %s failed for %v", tag, err)
+	}
+	return &Ticket{
+		UploadId:  id,
+		Url:       signedUrl,
+		Method:    "PUT",
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: pending.ExpiresAt,
+		MaxBytes:  s.Validator.MaxBytes,
+	}, nil
+}
+
+// FinalizeUpload - checks the object the client uploaded, runs the same
+// validation and processing as a multipart upload and attaches the result to
+// the target. The pending upload is claimed first so it is only processed once,
+// and released again if processing fails so the client can retry
+func (s *Service) FinalizeUpload(ctx context.Context, userId string, uploadId string) (*Result, error) {
+	tag := "upload.FinalizeUpload"
+	pending, err := s.Store.GetPendingUpload(ctx, uploadId)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetPendingUpload in %s failed for %w", tag, err)
+	}
+	if pending.UserId != userId {
+		return nil, &nectar_errors.NoEntityError{Message: fmt.Sprintf("no upload with id: %s", uploadId)}
+	}
+	if pending.FinalizedAt != nil {
+		return nil, nectar_errors.BadRequestError{Message: "upload has already been finalized"}
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, nectar_errors.BadRequestError{Message: "upload has expired, please start a new one"}
+	}
+	claimed, err := s.Store.ClaimPendingUpload(ctx, uploadId)
+	if err != nil {
+		return nil, fmt.Errorf("Store.ClaimPendingUpload in %s failed for %v", tag, err)
+	}
+	if !claimed {
+		return nil, nectar_errors.BadRequestError{Message: "upload has already been finalized"}
+	}
+	result, err := s.process(ctx, pending)
+	if err != nil {
+		if releaseErr := s.Store.ReleasePendingUpload(ctx, uploadId); releaseErr != nil {
+			log.Errorf("Store.ReleasePendingUpload in %s failed for %v", tag, releaseErr)
+		}
+		return nil, err
+	}
+	// The variants are stored under their own keys, the original is no longer needed
+	if err := s.BlobStore.Delete(ctx, pending.ObjectKey); err != nil {
+		log.Errorf("BlobStore.Delete in %s failed for %v", tag, err)
+	}
+	return result, nil
+}
+
+func (s *Service) process(ctx context.Context, pending *Pending) (*Result, error) {
+	tag := "upload.process"
+	object, size, err := s.BlobStore.Get(ctx, pending.ObjectKey)
+	if err != nil {
+		var noEntityError *nectar_errors.NoEntityError
+		if errors.As(err, &noEntityError) {
+			return nil, nectar_errors.BadRequestError{Message: "nothing has been uploaded yet"}
+		}
+		return nil, fmt.Errorf("BlobStore.Get in %s failed for %v", tag, err)
+	}
+	defer object.Close()
+	if size > s.Validator.MaxBytes {
+		return nil, TooLargeError{MaxBytes: s.Validator.MaxBytes}
+	}
+	file, err := s.Validator.Save(object, pending.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Cleanup()
+
+	switch pending.Target {
+	case TargetPlant:
+		p, urls, err := s.PlantImages.AddPlantImageWithId(ctx, pending.TargetId, file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("PlantImages.AddPlantImageWithId in %s failed for %w", tag, err)
+		}
+		return &Result{ImageUrls: *urls, Plant: p}, nil
+	case TargetProfile:
+		urls, err := s.ProfileImages.UpdateUserProfileImage(ctx, file.Path, pending.TargetId)
+		if err != nil {
+			return nil, fmt.Errorf("ProfileImages.UpdateUserProfileImage in %s failed for %v", tag, err)
+		}
+		return &Result{ImageUrls: *urls}, nil
+	}
+	return nil, fmt.Errorf("unknown upload target %s in %s", pending.Target, tag)
+}
@@ -0,0 +1,220 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultMaxBytes     = 10 << 20
+	defaultMaxPixels    = 40_000_000
+	defaultMaxDimension = 12_000
+	maxNameLength       = 64
+	sniffLength         = 512
+)
+
+// AllowedTypes - the sniffed content types accepted, mapped to the extension
+// the temp file is given
+var AllowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type TooLargeError struct {
+	MaxBytes int64
+}
+
+func (e TooLargeError) Error() string {
+	return fmt.Sprintf("upload is larger than %d bytes", e.MaxBytes)
+}
+
+type UnsupportedTypeError struct {
+	ContentType string
+}
+
+func (e UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported upload type %s", e.ContentType)
+}
+
+type InvalidImageError struct {
+	Message string
+}
+
+func (e InvalidImageError) Error() string {
+	return e.Message
+}
+
+// File - an upload that passed validation, kept in a temp file until Cleanup
+type File struct {
+	Path        string
+	Name        string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+}
+
+// Cleanup - removes the temp file, safe to call more than once
+func (f *File) Cleanup() {
+	if f == nil {
+		return
+	}
+	if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+		log.Error(err)
+	}
+}
+
+type Validator struct {
+	Dir          string
+	MaxBytes     int64
+	MaxPixels    int
+	MaxDimension int
+}
+
+// NewValidator - UPLOAD_MAX_BYTES (default 10MiB), UPLOAD_MAX_PIXELS (default
+// 40 megapixels), UPLOAD_MAX_DIMENSION (default 12000) and UPLOAD_TMP_DIR
+// (default the os temp dir) bound what is accepted and where it is kept
+func NewValidator() *Validator {
+	return &Validator{
+		Dir:          os.Getenv("UPLOAD_TMP_DIR"),
+		MaxBytes:     int64(envInt("UPLOAD_MAX_BYTES", defaultMaxBytes)),
+		MaxPixels:    envInt("UPLOAD_MAX_PIXELS", defaultMaxPixels),
+		MaxDimension: envInt("UPLOAD_MAX_DIMENSION", defaultMaxDimension),
+	}
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Save - streams body to a temp file, never holding more than the sniffed
+// prefix in memory. The file is removed again if any check fails
+func (v *Validator) Save(body io.Reader, clientName string) (*File, error) {
+	tag := "upload.Save"
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return nil, InvalidImageError{Message: "upload is empty"}
+		}
+		return nil, fmt.Errorf("io.ReadFull in %s failed for %v", tag, err)
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	extension, ok := AllowedTypes[contentType]
+	if !ok {
+		return nil, UnsupportedTypeError{ContentType: contentType}
+	}
+
+	name := SanitizeName(clientName)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	out, err := os.CreateTemp(v.Dir, stem+"-*"+extension)
+	if err != nil {
+		return nil, fmt.Errorf("os.CreateTemp in %s failed for %v", tag, err)
+	}
+	file := &File{Path: out.Name(), Name: name, ContentType: contentType}
+	// Read one byte past the limit so an upload of exactly MaxBytes is allowed
+	written, err := io.Copy(out, io.LimitReader(io.MultiReader(bytes.NewReader(head), body), v.MaxBytes+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		file.Cleanup()
+		return nil, fmt.Errorf("io.Copy in %s failed for %v", tag, err)
+	}
+	if written > v.MaxBytes {
+		file.Cleanup()
+		return nil, TooLargeError{MaxBytes: v.MaxBytes}
+	}
+	file.Size = written
+	if err := v.checkDimensions(file); err != nil {
+		file.Cleanup()
+		return nil, err
+	}
+	return file, nil
+}
+
+// checkDimensions - reads only the image header, a small file can still claim
+// dimensions that would take gigabytes to decode
+func (v *Validator) checkDimensions(file *File) error {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return fmt.Errorf("os.Open in upload.checkDimensions failed for %v", err)
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return InvalidImageError{Message: "upload is not a readable image"}
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return InvalidImageError{Message: "image has no pixels"}
+	}
+	if config.Width > v.MaxDimension || config.Height > v.MaxDimension || config.Width*config.Height > v.MaxPixels {
+		return InvalidImageError{Message: fmt.Sprintf("image dimensions %dx%d are too large", config.Width, config.Height)}
+	}
+	file.Width, file.Height = config.Width, config.Height
+	return nil
+}
+
+// CheckImageBytes - the same checks as Save for images that arrive already in
+// memory, e.g. base64 in a json body
+func (v *Validator) CheckImageBytes(data []byte) error {
+	if int64(len(data)) > v.MaxBytes {
+		return TooLargeError{MaxBytes: v.MaxBytes}
+	}
+	if _, ok := AllowedTypes[http.DetectContentType(data)]; !ok {
+		return UnsupportedTypeError{ContentType: http.DetectContentType(data)}
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return InvalidImageError{Message: "upload is not a readable image"}
+	}
+	if config.Width > v.MaxDimension || config.Height > v.MaxDimension || config.Width*config.Height > v.MaxPixels {
+		return InvalidImageError{Message: fmt.Sprintf("image dimensions %dx%d are too large", config.Width, config.Height)}
+	}
+	return nil
+}
+
+// SanitizeName - keeps only the base name of a client supplied file name,
+// limited to letters, digits, dots, dashes and underscores
+func SanitizeName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		}
+	}
+	sanitized := strings.TrimLeft(b.String(), ".")
+	if len(sanitized) > maxNameLength {
+		sanitized = sanitized[len(sanitized)-maxNameLength:]
+		sanitized = strings.TrimLeft(sanitized, ".")
+	}
+	if sanitized == "" {
+		return "upload"
+	}
+	return sanitized
+}
@@ -0,0 +1,79 @@
+//go:build integration
+
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func testPng(t *testing.T, width int, height int) []byte {
+	encoded := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(encoded, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return encoded.Bytes()
+}
+
+// claimDimensions - rewrites the IHDR chunk of a png to claim other dimensions
+// without adding any pixel data, the shape of a decompression bomb
+func claimDimensions(raw []byte, width uint32, height uint32) []byte {
+	patched := append([]byte{}, raw...)
+	// 8 byte signature, 4 byte length, then "IHDR" and its 13 bytes of data
+	binary.BigEndian.PutUint32(patched[16:20], width)
+	binary.BigEndian.PutUint32(patched[20:24], height)
+	binary.BigEndian.PutUint32(patched[29:33], crc32.ChecksumIEEE(patched[12:29]))
+	return patched
+}
+
+func TestValidator(t *testing.T) {
+	validator := &Validator{Dir: t.TempDir(), MaxBytes: 64 << 10, MaxPixels: 1_000_000, MaxDimension: 2000}
+
+	t.Run("test a valid image is saved under a sanitized name", func(t *testing.T) {
+		file, err := validator.Save(bytes.NewReader(testPng(t, 10, 10)), "../../etc/My Plant!.jpeg")
+		assert.NoError(t, err)
+		defer file.Cleanup()
+		assert.Equal(t, "image/png", file.ContentType)
+		assert.Equal(t, "My_Plant.jpeg", file.Name)
+		assert.Equal(t, ".png", file.Path[len(file.Path)-4:])
+		assert.Equal(t, 10, file.Width)
+
+		file.Cleanup()
+		_, err = os.Stat(file.Path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("test uploads over the size limit are rejected", func(t *testing.T) {
+		body := append(testPng(t, 10, 10), make([]byte, 64<<10)...)
+		_, err := validator.Save(bytes.NewReader(body), "big.png")
+		assert.ErrorAs(t, err, &TooLargeError{})
+	})
+
+	t.Run("test content that is not a whitelisted image is rejected", func(t *testing.T) {
+		_, err := validator.Save(bytes.NewReader([]byte("<html><script>alert(1)</script></html>")), "plant.jpeg")
+		assert.ErrorAs(t, err, &UnsupportedTypeError{})
+	})
+
+	t.Run("test decompression bombs are rejected before decoding", func(t *testing.T) {
+		_, err := validator.Save(bytes.NewReader(claimDimensions(testPng(t, 10, 10), 50000, 50000)), "bomb.png")
+		assert.ErrorAs(t, err, &InvalidImageError{})
+		assert.ErrorAs(t, validator.CheckImageBytes(claimDimensions(testPng(t, 10, 10), 1500, 1500)), &InvalidImageError{})
+	})
+
+	t.Run("test rejected uploads leave no temp files behind", func(t *testing.T) {
+		entries, err := os.ReadDir(validator.Dir)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("test sanitize name", func(t *testing.T) {
+		assert.Equal(t, "upload", SanitizeName("../.."))
+		assert.Equal(t, "passwd", SanitizeName("/etc/passwd"))
+		assert.Equal(t, "evil.png", SanitizeName("C:\\\\Users\\\\evil.png"))
+		assert.Equal(t, "hidden", SanitizeName(".hidden"))
+	})
+}
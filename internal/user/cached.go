@@ -0,0 +1,68 @@
+package user
+
+import (
+	"context"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/cache"
+	"os"
+	"time"
+)
+
+const defaultCacheTTL = 5 * time.Minute
+
+// PlantCache - the cached plants show their owner's username and profile image
+type PlantCache interface {
+	InvalidateOwner(ctx context.Context, userId string)
+}
+
+// CachedStore - a Store reading users through the cache. Writes made through
+// it invalidate the user, and the user's plants when the profile changes.
+// Writes made elsewhere, e.g. an admin changing a role, must call Invalidate.
+// Follows are not cached
+type CachedStore struct {
+	Store
+	Plants PlantCache
+	users  *cache.Entities[User]
+}
+
+// NewCachedStore - USER_CACHE_TTL (default 5m) is how long a user is cached
+func NewCachedStore(store Store, c cache.KeyValue, plants PlantCache) *CachedStore {
+	ttl, err := time.ParseDuration(os.Getenv("USER_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &CachedStore{Store: store, Plants: plants, users: cache.NewEntities[User](c, "user", ttl)}
+}
+
+// GetUser - both reads load the same row so they share the cached user
+func (s *CachedStore) GetUser(ctx context.Context, id string) (*User, error) {
+	return s.users.Get(ctx, id, s.Store.GetUser)
+}
+
+func (s *CachedStore) GetUserById(ctx context.Context, id string) (*User, error) {
+	return s.users.Get(ctx, id, s.Store.GetUserById)
+}
+
+func (s *CachedStore) UpdateUser(ctx context.Context, id string, u User) (*User, error) {
+	defer s.invalidateProfile(ctx, id)
+	return s.Store.UpdateUser(ctx, id, u)
+}
+
+func (s *CachedStore) UpdateUserProfileImage(ctx context.Context, uri string, id string) (string, error) {
+	defer s.invalidateProfile(ctx, id)
+	return s.Store.UpdateUserProfileImage(ctx, uri, id)
+}
+
+func (s *CachedStore) DeleteUser(ctx context.Context, id string) error {
+	defer s.invalidateProfile(ctx, id)
+	return s.Store.DeleteUser(ctx, id)
+}
+
+// Invalidate - forgets the cached user, after a write whether or not it failed
+func (s *CachedStore) Invalidate(userId string) {
+	s.users.Invalidate(userId)
+}
+
+func (s *CachedStore) invalidateProfile(ctx context.Context, userId string) {
+	s.Invalidate(userId)
+	s.Plants.InvalidateOwner(ctx, userId)
+}
@@ -0,0 +1,40 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
+)
+
+// Profile - the public subset of a user shown in follower and following lists
+type Profile struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	ImageUrl string `json:"image_url"`
+}
+
+func (s *Service) FollowUser(ctx context.Context, followerId string, followeeId string) error {
+	if followerId == followeeId {
+		return nectar_errors.BadRequestError{Message: "users cannot follow themselves"}
+	}
+	if err := s.Store.FollowUser(ctx, followerId, followeeId); err != nil {
+		return fmt.Errorf("Store.FollowUser in user.FollowUser failed for %v", err)
+	}
+	return nil
+}
+
+func (s *Service) UnfollowUser(ctx context.Context, followerId string, followeeId string) error {
+	if err := s.Store.UnfollowUser(ctx, followerId, followeeId); err != nil {
+		return fmt.Errorf("Store.UnfollowUser in user.UnfollowUser failed for %v", err)
+	}
+	return nil
+}
+
+func (s *Service) GetFollowers(ctx context.Context, userId string) ([]Profile, error) {
+	return s.Store.GetFollowers(ctx, userId)
+}
+
+func (s *Service) GetFollowing(ctx context.Context, userId string) ([]Profile, error) {
+	return s.Store.GetFollowing(ctx, userId)
+}
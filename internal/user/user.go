@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	uuid "github.com/satori/go.uuid"
+	"gitlab.com/kevinmorales/nectar-rest-api/internal/imaging"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/nectar_errors"
 	"gitlab.com/kevinmorales/nectar-rest-api/internal/validation"
+	"time"
 )
 
 type NewUserRequest struct {
@@ -21,16 +23,61 @@ type UpdateUserRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required"`
 	ImageUrl string `json:"imageUrl" validate:"required"`
+	// UpdatedAt - when set, the update only applies if the user was not
+	// changed since then
+	UpdatedAt time.Time `json:"-"`
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// IsValidRole - reports whether role is one of the roles stored in nectar_users
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
 }
 
 type User struct {
-	Id         string   `json:"id"`
-	PlantCount uint     `json:"plantCount"`
-	Name       string   `json:"name"`
-	Email      string   `json:"email"`
-	Username   string   `json:"username"`
-	ImageUrl   string   `json:"image_url"`
-	Following  []string `json:"following"`
+	Id             string    `json:"id"`
+	PlantCount     uint      `json:"plantCount"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	Username       string    `json:"username"`
+	ImageUrl       string    `json:"image_url"`
+	Role           string    `json:"role"`
+	Following      []string  `json:"following"`
+	FollowerCount  uint      `json:"followerCount"`
+	FollowingCount uint      `json:"followingCount"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// PublicUser - what other users may see of a user, without the email and role
+type PublicUser struct {
+	Id             string    `json:"id"`
+	PlantCount     uint      `json:"plantCount"`
+	Name           string    `json:"name"`
+	Username       string    `json:"username"`
+	ImageUrl       string    `json:"image_url"`
+	Following      []string  `json:"following"`
+	FollowerCount  uint      `json:"followerCount"`
+	FollowingCount uint      `json:"followingCount"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (u *User) Public() PublicUser {
+	return PublicUser{
+		Id:             u.Id,
+		PlantCount:     u.PlantCount,
+		Name:           u.Name,
+		Username:       u.Username,
+		ImageUrl:       u.ImageUrl,
+		Following:      u.Following,
+		FollowerCount:  u.FollowerCount,
+		FollowingCount: u.FollowingCount,
+		UpdatedAt:      u.UpdatedAt,
+	}
 }
 
 type Store interface {
@@ -40,7 +87,14 @@ type Store interface {
 	DeleteUser(ctx context.Context, id string) error
 	UpdateUser(ctx context.Context, id string, u User) (*User, error)
 	UpdateUserProfileImage(ctx context.Context, uri string, id string) (resultUri string, err error)
+	AddImageVariants(ctx context.Context, urls imaging.ImageUrls) error
 	CheckIfUsernameIsTaken(ctx context.Context, username string) (isUserNameTaken bool, err error)
+	FollowUser(ctx context.Context, followerId string, followeeId string) error
+	UnfollowUser(ctx context.Context, followerId string, followeeId string) error
+	GetFollowers(ctx context.Context, userId string) ([]Profile, error)
+	GetFollowing(ctx context.Context, userId string) ([]Profile, error)
+	GetFollowingIds(ctx context.Context, userId string) ([]string, error)
+	GetFollowCounts(ctx context.Context, userId string) (followerCount uint, followingCount uint, err error)
 }
 
 type AuthClient interface {
@@ -48,27 +102,33 @@ type AuthClient interface {
 }
 
 type Service struct {
-	Store        Store
-	AuthClient   AuthClient
-	BlobStore    BlobStore
-	MessageQueue MessageQueue
+	Store          Store
+	AuthClient     AuthClient
+	BlobStore      BlobStore
+	ImageProcessor ImageProcessor
+	MessageQueue   MessageQueue
 }
 
 type MessageQueue interface {
-	PushToQueue(ctx context.Context, topic string, message []byte) error
+	PushToQueue(ctx context.Context, topic string, key string, message []byte) error
 }
 
 type BlobStore interface {
 	UploadToBlobStore(fileList []string, ctx context.Context) (resultUris []string, err error)
 }
 
+type ImageProcessor interface {
+	UploadVariants(ctx context.Context, srcPath string, blobStore imaging.BlobStore) (*imaging.ImageUrls, error)
+}
+
 // NewService - returns a pointer to a new user service
-func NewService(store Store, authClient AuthClient, blobStore BlobStore, messageQueue MessageQueue) *Service {
+func NewService(store Store, authClient AuthClient, blobStore BlobStore, imageProcessor ImageProcessor, messageQueue MessageQueue) *Service {
 	return &Service{
-		Store:        store,
-		AuthClient:   authClient,
-		BlobStore:    blobStore,
-		MessageQueue: messageQueue,
+		Store:          store,
+		AuthClient:     authClient,
+		BlobStore:      blobStore,
+		ImageProcessor: imageProcessor,
+		MessageQueue:   messageQueue,
 	}
 }
 
@@ -82,7 +142,17 @@ func (s *Service) GetUserById(ctx context.Context, id string) (*User, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Store.GetUserByAuthId in %s failed for %v", tag, err)
 	}
-	u.Following = []string{}
+	following, err := s.Store.GetFollowingIds(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetFollowingIds in %s failed for %v", tag, err)
+	}
+	followerCount, followingCount, err := s.Store.GetFollowCounts(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Store.GetFollowCounts in %s failed for %v", tag, err)
+	}
+	u.Following = following
+	u.FollowerCount = followerCount
+	u.FollowingCount = followingCount
 	return u, nil
 }
 
@@ -100,6 +170,7 @@ func (s *Service) AddUser(ctx context.Context, u NewUserRequest) (*User, error)
 		Name:     u.Name,
 		Email:    u.Email,
 		Username: u.Username,
+		Role:     RoleUser,
 	}
 	nu, err := s.Store.AddUser(ctx, newUser)
 	if err != nil {
@@ -116,22 +187,30 @@ func (s *Service) DeleteUser(ctx context.Context, id string) error {
 }
 func (s *Service) UpdateUser(ctx context.Context, id string, usr UpdateUserRequest) (*User, error) {
 	u := User{
-		Id:       id,
-		Username: usr.Username,
-		Name:     usr.Name,
-		ImageUrl: usr.ImageUrl,
-		Email:    usr.Email,
+		Id:        id,
+		Username:  usr.Username,
+		Name:      usr.Name,
+		ImageUrl:  usr.ImageUrl,
+		Email:     usr.Email,
+		UpdatedAt: usr.UpdatedAt,
 	}
 	return s.Store.UpdateUser(ctx, id, u)
 }
 
-func (s *Service) UpdateUserProfileImage(ctx context.Context, uri string, userId string) (string, error) {
-	resultUris, err := s.BlobStore.UploadToBlobStore([]string{uri}, ctx)
+// UpdateUserProfileImage - the profile image column keeps the full size url,
+// the other sizes are found through the image variants
+func (s *Service) UpdateUserProfileImage(ctx context.Context, uri string, userId string) (*imaging.ImageUrls, error) {
+	urls, err := s.ImageProcessor.UploadVariants(ctx, uri, s.BlobStore)
 	if err != nil {
-		return "", fmt.Errorf("blob.UploadToBlobStore in user.UpdateUserProfileImage failed for %v", err)
+		return nil, fmt.Errorf("imaging.UploadVariants in user.UpdateUserProfileImage failed for %v", err)
+	}
+	if err := s.Store.AddImageVariants(ctx, *urls); err != nil {
+		return nil, fmt.Errorf("Store.AddImageVariants in user.UpdateUserProfileImage failed for %v", err)
+	}
+	if _, err := s.Store.UpdateUserProfileImage(ctx, urls.Url, userId); err != nil {
+		return nil, err
 	}
-	resultUri := resultUris[0]
-	return s.Store.UpdateUserProfileImage(ctx, resultUri, userId)
+	return urls, nil
 }
 
 func (s *Service) CheckIfUsernameIsTaken(ctx context.Context, username string) (bool, error) {
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS care_schedule;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS public.care_schedule (
+    id uuid NOT NULL DEFAULT uuid_generate_v4() PRIMARY KEY,
+    plant_id uuid NOT NULL UNIQUE,
+    watering_interval_days int NOT NULL DEFAULT 0,
+    fertilizing_interval_days int NOT NULL DEFAULT 0,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    last_update_date timestamp DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS care_reminder;
\ No newline at end of file
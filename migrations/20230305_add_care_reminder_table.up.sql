@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS public.care_reminder (
+    plant_id uuid NOT NULL,
+    care_type text NOT NULL,
+    due_date date NOT NULL,
+    sent_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (plant_id, care_type, due_date)
+);
@@ -0,0 +1 @@
+ALTER TABLE user_credentials DROP CONSTRAINT "unique_user_credentials_user_id";
\ No newline at end of file
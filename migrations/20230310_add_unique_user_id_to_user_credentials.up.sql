@@ -0,0 +1 @@
+ALTER TABLE user_credentials ADD CONSTRAINT "unique_user_credentials_user_id" UNIQUE(user_id);
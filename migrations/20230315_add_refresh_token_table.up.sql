@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS public.refresh_token (
+    id uuid NOT NULL DEFAULT uuid_generate_v4() PRIMARY KEY,
+    user_id uuid NOT NULL,
+    token_digest text NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_user_id_idx ON refresh_token (user_id);
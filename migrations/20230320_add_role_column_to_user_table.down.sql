@@ -0,0 +1,3 @@
+ALTER TABLE nectar_users
+DROP CONSTRAINT "valid_user_role",
+DROP COLUMN role;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE nectar_users
+ADD COLUMN role text NOT NULL DEFAULT 'user',
+ADD CONSTRAINT "valid_user_role" CHECK (role IN ('user', 'moderator', 'admin'));
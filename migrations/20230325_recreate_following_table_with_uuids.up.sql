@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS following;
+
+CREATE TABLE IF NOT EXISTS public.following (
+    follower_id uuid NOT NULL,
+    followee_id uuid NOT NULL,
+    started_following_date timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT "cannot_follow_self" CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS following_followee_id_idx ON following (followee_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS plant_search_vector_idx;
+
+ALTER TABLE plant
+DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE plant_search_terms
+DROP CONSTRAINT IF EXISTS plant_search_terms_pkey;
@@ -0,0 +1,20 @@
+DELETE FROM plant_search_terms a
+USING plant_search_terms b
+WHERE a.ctid < b.ctid
+AND a.plant_id = b.plant_id
+AND a.term = b.term;
+
+ALTER TABLE plant_search_terms
+ADD CONSTRAINT plant_search_terms_pkey PRIMARY KEY (plant_id, term);
+
+ALTER TABLE plant
+ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+UPDATE plant SET search_vector =
+    setweight(to_tsvector('english', coalesce(common_name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(scientific_name, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce((
+        SELECT string_agg(term, ' ') FROM plant_search_terms WHERE plant_search_terms.plant_id = plant.id
+    ), '')), 'C');
+
+CREATE INDEX IF NOT EXISTS plant_search_vector_idx ON plant USING GIN (search_vector);
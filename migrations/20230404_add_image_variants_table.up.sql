@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS public.image_variants (
+    image text PRIMARY KEY NOT NULL,
+    variants jsonb NOT NULL,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP
+);
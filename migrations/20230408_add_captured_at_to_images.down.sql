@@ -0,0 +1,5 @@
+ALTER TABLE plant_images
+DROP COLUMN IF EXISTS captured_at;
+
+ALTER TABLE image_variants
+DROP COLUMN IF EXISTS captured_at;
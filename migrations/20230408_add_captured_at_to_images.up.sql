@@ -0,0 +1,5 @@
+ALTER TABLE image_variants
+ADD COLUMN IF NOT EXISTS captured_at timestamptz;
+
+ALTER TABLE plant_images
+ADD COLUMN IF NOT EXISTS captured_at timestamptz;
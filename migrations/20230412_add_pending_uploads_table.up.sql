@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS pending_uploads (
+    id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    object_key text NOT NULL UNIQUE,
+    content_type text NOT NULL,
+    target text NOT NULL,
+    target_id uuid NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NOT NULL,
+    finalized_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS pending_uploads_expires_at_idx ON pending_uploads (expires_at) WHERE finalized_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS nectar_users_profile_image_idx;
+
+DROP INDEX IF EXISTS plant_images_image_idx;
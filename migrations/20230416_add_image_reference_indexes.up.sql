@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS plant_images_image_idx ON plant_images (image);
+
+CREATE INDEX IF NOT EXISTS nectar_users_profile_image_idx ON nectar_users (profile_image);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS plant_images_plant_id_position_idx;
+
+ALTER TABLE plant_images
+DROP COLUMN IF EXISTS position;
@@ -0,0 +1,12 @@
+ALTER TABLE plant_images
+ADD COLUMN IF NOT EXISTS position integer NOT NULL DEFAULT 0;
+
+UPDATE plant_images
+SET position = ordered.position,
+    is_primary_image = ordered.position = 0
+FROM (SELECT id, row_number() OVER (PARTITION BY plant_id ORDER BY is_primary_image DESC, id) - 1 AS position
+      FROM plant_images
+      WHERE deletion_date > CURRENT_TIMESTAMP) ordered
+WHERE plant_images.id = ordered.id;
+
+CREATE INDEX IF NOT EXISTS plant_images_plant_id_position_idx ON plant_images (plant_id, position);
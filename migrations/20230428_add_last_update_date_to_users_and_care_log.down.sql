@@ -0,0 +1,4 @@
+ALTER TABLE nectar_users
+    DROP COLUMN IF EXISTS last_update_date;
+ALTER TABLE care_log
+    DROP COLUMN IF EXISTS last_update_date;
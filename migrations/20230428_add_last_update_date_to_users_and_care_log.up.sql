@@ -0,0 +1,4 @@
+ALTER TABLE nectar_users
+    ADD COLUMN IF NOT EXISTS last_update_date timestamp DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE care_log
+    ADD COLUMN IF NOT EXISTS last_update_date timestamp DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS public.revoked_session (
+    token_digest text NOT NULL PRIMARY KEY,
+    user_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS revoked_session_expires_at_idx ON revoked_session (expires_at);
+
+CREATE TABLE IF NOT EXISTS public.user_session_revocation (
+    user_id uuid NOT NULL PRIMARY KEY,
+    revoked_at timestamptz NOT NULL
+);